@@ -0,0 +1,175 @@
+package keg
+
+import (
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Absorb copies the nodes of the other keg with the IDs of the filter
+// entries (or every node in its index other than the zero node if
+// filter is nil) into this one with new IDs (see Dex.Next) in the order
+// of their old IDs and returns the old to new ID map. Every file of
+// each node is copied with its modification time. Links between the
+// nodes copied (see NodeLinkExp) are rewritten to the new IDs (outside
+// fenced code blocks) but links to nodes not copied are left alone and
+// returned (along with the map) as Errors of UnabsorbedLink warnings
+// since they now link to whatever node has that ID in this keg. The new
+// entries (with titles from the other index) are added to both dex
+// files (see WriteDex) and appended to the changes file as created (see
+// AppendChange). Returns NodeNotFound if a node of the filter is
+// not in the other keg. The dex lock of this keg is held throughout
+// (see LockDex).
+func (k *Keg) Absorb(other *Keg, filter Dex) (map[int]int, error) {
+	if filepath.Clean(other.Local.Path) == filepath.Clean(k.Local.Path) {
+		return nil, fmt.Errorf("cannot absorb keg into itself: %v", k.Local.Path)
+	}
+	theirs, err := other.Dex()
+	if theirs == nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = theirs.WithoutZero()
+	}
+	index := theirs.Map()
+	var ids []int
+	seen := map[int]bool{}
+	for _, e := range filter {
+		if !isDir(other.Path(e.N)) {
+			return nil, NodeNotFound{e.N}
+		}
+		if !seen[e.N] {
+			seen[e.N] = true
+			ids = append(ids, e.N)
+		}
+	}
+	sort.Ints(ids)
+
+	moved := map[int]int{}
+	var warnings Errors
+	err = withDexLock(k.Local.Path, func() error {
+		dex, err := k.Dex()
+		if dex == nil {
+			return err
+		}
+		next := dex.Next()
+		for _, id := range ids {
+			for isDir(k.Path(next)) {
+				next++
+			}
+			moved[id] = next
+			next++
+		}
+		for _, id := range ids {
+			to := k.Path(moved[id])
+			if err := copyTree(other.Path(id), to); err != nil {
+				return err
+			}
+			unabsorbed, err := absorbLinks(to, moved)
+			if err != nil {
+				return err
+			}
+			for _, l := range unabsorbed {
+				warnings = append(warnings, UnabsorbedLink{From: moved[id], Line: l.Line, To: l.To})
+			}
+			e, err := scanNode(to)
+			if err != nil {
+				return err
+			}
+			if theirs, has := index[id]; has {
+				e.T = theirs.T
+			}
+			e.N = moved[id]
+			dex = dex.Upsert(e)
+		}
+		if err := WriteDex(k.Local.Path, dex); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := appendChange(k.Local.Path, *dex.Get(moved[id]), `created`); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(warnings) > 0 {
+		return moved, warnings
+	}
+	return moved, nil
+}
+
+// absorbLinks rewrites the links of the README.md in the node directory
+// dir to the nodes in moved (see renumberLinks) keeping its modification
+// time and returns the links to nodes not in moved.
+func absorbLinks(dir string, moved map[int]int) ([]Link, error) {
+	readme := filepath.Join(dir, `README.md`)
+	info, err := os.Stat(readme)
+	if err != nil {
+		if errors.Is(err, iofs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	byt, err := os.ReadFile(readme)
+	if err != nil {
+		return nil, err
+	}
+	links, err := ParseKegLinks(strings.NewReader(string(byt)))
+	if err != nil {
+		return nil, err
+	}
+	var unabsorbed []Link
+	for _, l := range links {
+		if _, has := moved[l.To]; l.Keg == "" && !has {
+			unabsorbed = append(unabsorbed, l)
+		}
+	}
+	text, n := renumberLinks(string(byt), moved)
+	if n == 0 {
+		return unabsorbed, nil
+	}
+	if err := writeFileAtomic(readme, text); err != nil {
+		return nil, err
+	}
+	return unabsorbed, os.Chtimes(readme, info.ModTime(), info.ModTime())
+}
+
+// copyTree copies every file (and directory) within from into the new
+// directory to keeping the modification time of each file. Returns an
+// error if to already exists.
+func copyTree(from, to string) error {
+	if err := os.Mkdir(to, 0755); err != nil {
+		return err
+	}
+	return filepath.WalkDir(from, func(p string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, p)
+		if err != nil || rel == `.` {
+			return err
+		}
+		dst := filepath.Join(to, rel)
+		if d.IsDir() {
+			return os.MkdirAll(dst, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := copyFile(p, dst); err != nil {
+			return err
+		}
+		return os.Chtimes(dst, info.ModTime(), info.ModTime())
+	})
+}
@@ -0,0 +1,75 @@
+package keg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestKeg_Absorb(t *testing.T) {
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mine, theirs := t.TempDir(), t.TempDir()
+	mkNode(t, mine, `0`, "# Zero\n", old)
+	mkNode(t, mine, `1`, "# Mine\n", old)
+	mkNode(t, theirs, `0`, "# Their Zero\n", old)
+	mkNode(t, theirs, `1`, "# Their One\n\nSee [two](/2) and [three](../3).\n", old)
+	mkNode(t, theirs, `2`, "# Their Two\n\nBack to [one](/1).\n", old)
+	mkNode(t, theirs, `3`, "# Their Three\n", old)
+	csv := filepath.Join(theirs, `2`, `data.csv`)
+	if err := os.WriteFile(csv, []byte("a,b\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(csv, old, old); err != nil {
+		t.Fatal(err)
+	}
+	for _, root := range []string{mine, theirs} {
+		if _, err := keg.UpdateDex(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	k, _ := keg.Open(mine)
+	other, _ := keg.Open(theirs)
+	filter, _ := other.Dex()
+	filter = filter.Remove(0).Remove(3)
+
+	moved, err := k.Absorb(other, filter)
+	if want := map[int]int{1: 2, 2: 3}; !reflect.DeepEqual(moved, want) {
+		t.Errorf("got %v want %v", moved, want)
+	}
+	var warn keg.UnabsorbedLink
+	if !errors.As(err, &warn) || warn != (keg.UnabsorbedLink{From: 2, Line: 3, To: 3}) {
+		t.Errorf("expected unabsorbed link to 3 reported, got %v", err)
+	}
+
+	for id, want := range map[int]string{
+		2: "# Their One\n\nSee [two](/3) and [three](../3).\n",
+		3: "# Their Two\n\nBack to [one](/2).\n",
+	} {
+		byt, _ := os.ReadFile(filepath.Join(k.Path(id), `README.md`))
+		if string(byt) != want {
+			t.Errorf("%v: got %q want %q", id, byt, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(k.Path(3), `data.csv`)); err != nil {
+		t.Errorf("expected attachment copied: %v", err)
+	}
+	dex, _ := k.Dex()
+	if e := dex.Get(3); e == nil || e.T != `Their Two` || !e.U.Equal(old) {
+		t.Errorf("unexpected dex entry: %v", e)
+	}
+
+	want := []string{`created 2 Their One`, `created 3 Their Two`}
+	if got := readChanges(t, mine); !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %v want %v", got, want)
+	}
+
+	var notfound keg.NodeNotFound
+	if _, err := k.Absorb(other, keg.Dex{{N: 9}}); !errors.As(err, &notfound) {
+		t.Errorf("expected NodeNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,319 @@
+package keg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Archive writes the entire keg (but for dotfiles and dotdirs, like .git)
+// to w as a tar.gz (format "tar.gz", "tgz", or "") or zip ("zip")
+// archive with paths relative to the keg directory. The result is
+// deterministic: entries are in lexical order, ownership is dropped,
+// permissions are normalized (0755 and 0644), and every modification
+// time is the time the node was last updated in the index (see Dex)
+// with the newest of them used for files outside of node directories.
+// See ImportArchive.
+func (k *Keg) Archive(w io.Writer, format string) error {
+	switch format {
+	case ``, `tar.gz`, `tgz`, `zip`:
+	default:
+		return fmt.Errorf("unsupported archive format: %q", format)
+	}
+	dex, err := k.Dex()
+	if dex == nil {
+		return err
+	}
+	index := dex.Map()
+	var stamp time.Time
+	if latest := dex.ByLatest(); len(latest) > 0 {
+		stamp = latest[0].U
+	} else if k.Info != nil {
+		stamp = k.Info.Updated
+	}
+	root := k.Local.Path
+	var entries []archived
+	err = filepath.WalkDir(root, func(p string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), `.`) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil // symlinks and such are not portable
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		e := archived{Name: rel, Dir: d.IsDir(), Mod: stamp, Mode: 0644}
+		if id, is := nodeName(strings.SplitN(rel, `/`, 2)[0]); is && index[id] != nil {
+			e.Mod = index[id].U
+		}
+		if e.Dir {
+			e.Mode = 0755
+		} else {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if info.Mode()&0100 != 0 {
+				e.Mode = 0755
+			}
+			if e.Data, err = os.ReadFile(p); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if format == `zip` {
+		return writeZip(w, entries)
+	}
+	return writeTarGz(w, entries)
+}
+
+// archived is a single file or directory within an archive of a keg.
+type archived struct {
+	Name string // slash separated and relative to keg directory
+	Dir  bool
+	Mode iofs.FileMode
+	Mod  time.Time
+	Data []byte
+}
+
+// nodeName returns the node ID if name is a canonical node directory
+// name (see ScanDir).
+func nodeName(name string) (int, bool) {
+	id, err := strconv.Atoi(name)
+	return id, err == nil && id >= 0 && strconv.Itoa(id) == name
+}
+
+func writeTarGz(w io.Writer, entries []archived) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		h := &tar.Header{
+			Name:    e.Name,
+			Mode:    int64(e.Mode),
+			ModTime: e.Mod.UTC().Truncate(time.Second),
+			Size:    int64(len(e.Data)),
+		}
+		if e.Dir {
+			h.Typeflag, h.Name = tar.TypeDir, e.Name+`/`
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			return err
+		}
+		if _, err := tw.Write(e.Data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeZip(w io.Writer, entries []archived) error {
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		h := &zip.FileHeader{
+			Name:     e.Name,
+			Method:   zip.Deflate,
+			Modified: e.Mod.UTC().Truncate(time.Second),
+		}
+		h.SetMode(e.Mode)
+		if e.Dir {
+			h.Name, h.Method = e.Name+`/`, zip.Store
+			h.SetMode(iofs.ModeDir | e.Mode)
+		}
+		f, err := zw.CreateHeader(h)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(e.Data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// ImportArchive extracts the keg archive (see Archive) read from r (the
+// format is detected) into destPath, which must not exist or be empty,
+// and returns it opened (see Open). The entire archive is validated
+// before anything is written: it must contain a valid keg info file
+// (see ParseKegInfo) and at least one node directory, and every entry
+// must be a plain file or directory with a relative path that stays
+// within destPath. Links and other special entries are refused.
+// Modification times are restored.
+func ImportArchive(r io.Reader, destPath string) (*Keg, error) {
+	byt, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var entries []archived
+	switch {
+	case bytes.HasPrefix(byt, []byte{0x1f, 0x8b}):
+		entries, err = readTarGz(bytes.NewReader(byt))
+	case bytes.HasPrefix(byt, []byte("PK\x03\x04")):
+		entries, err = readZip(byt)
+	default:
+		err = fmt.Errorf("unrecognized format")
+	}
+	if err == nil {
+		entries, err = validArchive(entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid keg archive: %w", err)
+	}
+	if files, err := os.ReadDir(destPath); err == nil && len(files) > 0 {
+		return nil, fmt.Errorf("destination not empty: %v", destPath)
+	}
+	var dirs []archived
+	for _, e := range entries {
+		p := filepath.Join(destPath, filepath.FromSlash(e.Name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return nil, err
+		}
+		if e.Dir {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				return nil, err
+			}
+			dirs = append(dirs, e)
+			continue
+		}
+		if err := os.WriteFile(p, e.Data, e.Mode.Perm()|0600); err != nil {
+			return nil, err
+		}
+		if err := os.Chtimes(p, e.Mod, e.Mod); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range dirs { // after their content is written
+		p := filepath.Join(destPath, filepath.FromSlash(e.Name))
+		if err := os.Chtimes(p, e.Mod, e.Mod); err != nil {
+			return nil, err
+		}
+	}
+	return Open(destPath)
+}
+
+func readTarGz(r io.Reader) ([]archived, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	var entries []archived
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		e := archived{Name: h.Name, Mode: iofs.FileMode(h.Mode).Perm(), Mod: h.ModTime}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			e.Dir = true
+		case tar.TypeReg:
+			if e.Data, err = io.ReadAll(tr); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("not a file or directory: %q", h.Name)
+		}
+		entries = append(entries, e)
+	}
+}
+
+func readZip(byt []byte) ([]archived, error) {
+	zr, err := zip.NewReader(bytes.NewReader(byt), int64(len(byt)))
+	if err != nil {
+		return nil, err
+	}
+	var entries []archived
+	for _, f := range zr.File {
+		mode := f.Mode()
+		e := archived{Name: f.Name, Mode: mode.Perm(), Mod: f.Modified, Dir: mode.IsDir()}
+		if !mode.IsDir() && !mode.IsRegular() {
+			return nil, fmt.Errorf("not a file or directory: %q", f.Name)
+		}
+		if !e.Dir {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			e.Data, err = io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// validArchive returns the entries with every name cleaned (and any
+// entry for the top directory itself dropped) or an error if any could
+// escape the destination or if the entries are not those of a keg (see
+// ImportArchive).
+func validArchive(entries []archived) ([]archived, error) {
+	var info []byte
+	var nodes int
+	valid := make([]archived, 0, len(entries))
+	for _, e := range entries {
+		name := path.Clean(strings.TrimSuffix(e.Name, `/`))
+		if name == `.` && e.Dir {
+			continue // ./ from tar -C
+		}
+		if e.Name == "" || path.IsAbs(name) || name == `..` || name == `.` ||
+			strings.HasPrefix(name, `../`) || strings.Contains(e.Name, `\`) ||
+			filepath.VolumeName(name) != "" {
+			return nil, fmt.Errorf("unsafe path: %q", e.Name)
+		}
+		e.Name = name
+		valid = append(valid, e)
+		top := strings.SplitN(name, `/`, 2)[0]
+		if _, is := nodeName(top); is {
+			nodes++
+		}
+		if name == `keg` && !e.Dir {
+			info = e.Data
+		}
+	}
+	if info == nil {
+		return nil, fmt.Errorf("missing keg info file")
+	}
+	if _, err := ParseKegInfo(bytes.NewReader(info)); err != nil {
+		return nil, err
+	}
+	if nodes == 0 {
+		return nil, fmt.Errorf("no node directories")
+	}
+	return valid, nil
+}
@@ -0,0 +1,132 @@
+package keg_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestKeg_Archive(t *testing.T) {
+	root := t.TempDir()
+	if _, err := keg.InitKegWith(root, `Archived`, keg.InitOpts{Creator: `me`}); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Zero\n", old)
+	dir := mkNode(t, root, `1`, "# One\n", old.Add(time.Hour))
+	if err := os.WriteFile(filepath.Join(dir, `data.csv`), []byte("a,b\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, `.git`), 0700); err != nil {
+		t.Fatal(err)
+	}
+	dex, err := keg.UpdateDex(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := keg.Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{`tar.gz`, `zip`} {
+		var one, two bytes.Buffer
+		if err := k.Archive(&one, format); err != nil {
+			t.Fatal(err)
+		}
+		if err := k.Archive(&two, format); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(one.Bytes(), two.Bytes()) {
+			t.Errorf("%v: archive not deterministic", format)
+		}
+
+		dest := filepath.Join(t.TempDir(), `restored`)
+		got, err := keg.ImportArchive(&one, dest)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if got.Info.Title != `Archived` {
+			t.Errorf("%v: unexpected info: %v", format, got.Info)
+		}
+		csv, err := os.ReadFile(filepath.Join(dest, `1`, `data.csv`))
+		if err != nil || string(csv) != "a,b\n" {
+			t.Errorf("%v: unexpected attachment: %q (%v)", format, csv, err)
+		}
+		if info, err := os.Stat(filepath.Join(dest, `1`, `data.csv`)); err != nil ||
+			!info.ModTime().Equal(dex.Get(1).U.Truncate(time.Second)) {
+			t.Errorf("%v: mtime not from dex: %v", format, info.ModTime())
+		}
+		if _, err := os.Stat(filepath.Join(dest, `.git`)); !os.IsNotExist(err) {
+			t.Errorf("%v: dotdir archived", format)
+		}
+		if got, _ := keg.ScanDir(dest); len(got) != 2 {
+			t.Errorf("%v: unexpected nodes restored: %v", format, got)
+		}
+	}
+
+	if err := k.Archive(&bytes.Buffer{}, `rar`); err == nil {
+		t.Error("expected unsupported format error")
+	}
+}
+
+// tarGz returns a tar.gz archive of the files (name and content) given.
+func tarGz(t *testing.T, files ...string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for i := 0; i+1 < len(files); i += 2 {
+		h := &tar.Header{Name: files[i], Mode: 0644, Size: int64(len(files[i+1]))}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(files[i+1]))
+	}
+	tw.Close()
+	gz.Close()
+	return &buf
+}
+
+func TestImportArchive_invalid(t *testing.T) {
+	info := "updated: 2022-12-10 06:10:04Z\n"
+	for _, c := range []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{`traversal`, []string{`keg`, info, `0/README.md`, "# Zero\n", `../evil`, `x`}, `unsafe path`},
+		{`absolute`, []string{`keg`, info, `/etc/evil`, `x`, `0/README.md`, "# Zero\n"}, `unsafe path`},
+		{`no info`, []string{`0/README.md`, "# Zero\n"}, `missing keg info`},
+		{`bad info`, []string{`keg`, "title: x\n", `0/README.md`, "# Zero\n"}, `missing updated`},
+		{`no nodes`, []string{`keg`, info, `notes.txt`, `x`}, `no node directories`},
+	} {
+		parent := t.TempDir()
+		dest := filepath.Join(parent, `dest`)
+		_, err := keg.ImportArchive(tarGz(t, c.files...), dest)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%v: expected %q error, got %v", c.name, c.want, err)
+		}
+		if files, _ := os.ReadDir(parent); len(files) != 0 {
+			t.Errorf("%v: files written: %v", c.name, files)
+		}
+	}
+
+	ok := tarGz(t, `./keg`, info, `./0/README.md`, "# Zero\n")
+	dest := t.TempDir()
+	os.WriteFile(filepath.Join(dest, `existing`), nil, 0600)
+	if _, err := keg.ImportArchive(bytes.NewReader(ok.Bytes()), dest); err == nil {
+		t.Error("expected refusal to import into non-empty directory")
+	}
+	k, err := keg.ImportArchive(ok, filepath.Join(dest, `new`))
+	if err != nil || k.Info == nil {
+		t.Errorf("./ prefixed archive not imported: %v", err)
+	}
+}
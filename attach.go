@@ -0,0 +1,126 @@
+package keg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attachment is a single file within a node directory other than the
+// README.md and meta files (see Node.Attachments).
+type Attachment struct {
+	Name    string    // relative to the node directory (slash separated)
+	Size    int64     // in bytes
+	ModTime time.Time // last changed
+}
+
+// Attachments returns every file of the node (see Files) other than its
+// meta file (see Meta) with its size and last change.
+func (n *Node) Attachments() ([]Attachment, error) {
+	files, err := n.Files()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Attachment, 0, len(files))
+	for _, name := range files {
+		if name == `meta` || isEditorTemp(filepath.Base(name)) {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(n.Dir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, Attachment{Name: name, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return list, nil
+}
+
+// Attach copies the file at src into the node directory and returns the
+// name it was given: the same as the src file unless already taken (or
+// README.md or meta) in which case -1, -2, and so on is added before
+// the extension. The node is then updated in both dex files (see
+// touchDex).
+func (n *Node) Attach(src string) (string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("not a regular file: %v", src)
+	}
+	base := filepath.Base(src)
+	ext := filepath.Ext(base)
+	name := base
+	for i := 1; ; i++ {
+		_, err := os.Lstat(filepath.Join(n.Dir, name))
+		if os.IsNotExist(err) && name != `README.md` && name != `meta` {
+			break
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		name = strings.TrimSuffix(base, ext) + `-` + strconv.Itoa(i) + ext
+	}
+	if err := copyFile(src, filepath.Join(n.Dir, name)); err != nil {
+		return "", err
+	}
+	return name, n.touchDex()
+}
+
+// DetachAttachment removes the attachment with name (see Attachments)
+// from the node directory and updates the node in both dex files (see
+// touchDex). The README.md and meta files are not attachments.
+func (n *Node) DetachAttachment(name string) error {
+	clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(name)))
+	if clean == `README.md` || clean == `meta` || clean == `.` ||
+		clean == `..` || strings.HasPrefix(clean, `../`) || filepath.IsAbs(name) {
+		return fmt.Errorf("not an attachment: %v", name)
+	}
+	path := filepath.Join(n.Dir, filepath.FromSlash(clean))
+	if info, err := os.Stat(path); err != nil {
+		return err
+	} else if info.IsDir() {
+		return fmt.Errorf("not an attachment: %v", name)
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return n.touchDex()
+}
+
+// touchDex calls touchDexAt with the current time.
+func (n *Node) touchDex() error {
+	return n.touchDexAt(time.Now().UTC().Truncate(time.Second))
+}
+
+// touchDexAt sets the updated time of the node in both dex files of the
+// keg containing it (the parent of Dir) to u (keeping the title) and
+// Updated to match and appends the change (see AppendChange). Nodes not
+// yet indexed (or in a keg without any dex files) are left that way but
+// Updated is still set. The dex lock is held throughout (see LockDex).
+func (n *Node) touchDexAt(u time.Time) error {
+	n.Updated = u
+	kegpath := filepath.Dir(n.Dir)
+	return withDexLock(kegpath, func() error {
+		dex, err := ReadDex(kegpath)
+		if dex == nil {
+			return err
+		}
+		if errors.Is(err, ErrDexRebuilt) {
+			return nil // no index to update
+		}
+		e := dex.Get(n.ID)
+		if e == nil {
+			return nil
+		}
+		touched := DexEntry{U: u, T: e.T, N: n.ID}
+		if err := WriteDex(kegpath, dex.Upsert(touched)); err != nil {
+			return err
+		}
+		return appendChange(kegpath, touched, `updated`)
+	})
+}
@@ -0,0 +1,66 @@
+package keg_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestNode_Attach(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `1`, "# One\n\nSee ![pic](pic.png).\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), `pic.png`)
+	if err := os.WriteFile(src, []byte(`png`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	k, _ := keg.Open(root)
+	node, _ := k.Node(1)
+	if err := node.SetMeta(`source`, `camera`); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for i := 0; i < 2; i++ {
+		name, err := node.Attach(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if want := []string{`pic.png`, `pic-1.png`}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v want %v", names, want)
+	}
+	dex, _ := k.Dex()
+	if e := dex.Get(1); e == nil || !e.U.After(old) || e.T != `One` {
+		t.Errorf("expected updated time bumped in dex: %v", e)
+	}
+
+	list, err := node.Attachments()
+	if err != nil || len(list) != 2 || list[0].Name != `pic-1.png` || list[1].Size != 3 {
+		t.Errorf("unexpected attachments: %+v (%v)", list, err)
+	}
+	if hits, _ := k.Grep(`png`); len(hits) != 1 || hits[0].File != `README.md` {
+		t.Errorf("expected attachments excluded from grep: %v", hits)
+	}
+
+	if err := node.DetachAttachment(`pic-1.png`); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.DetachAttachment(`meta`); err == nil {
+		t.Error("expected meta not to be an attachment")
+	}
+	if err := node.DetachAttachment(`../1/README.md`); err == nil {
+		t.Error("expected path outside node refused")
+	}
+	if list, _ := node.Attachments(); len(list) != 1 || list[0].Name != `pic.png` {
+		t.Errorf("unexpected attachments after detach: %+v", list)
+	}
+}
@@ -0,0 +1,107 @@
+package keg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Change is a single line of the dex/changes.md file (see AppendChange).
+type Change struct {
+	DexEntry        // U is when the change was made
+	Action   string // created, updated, deleted, and so on
+}
+
+// YearlyChanges starts a new changes file every year (named
+// changes-2006.md for the year of the change) rather than appending
+// every change to the same dex/changes.md file (see AppendChange).
+var YearlyChanges bool
+
+// ChangeExp matches a single line of the changes file (with or
+// without the leading "* ") capturing the time, action, title, and
+// node ID. See AppendChange and LatestDexEntryExp.
+var ChangeExp = regexp.MustCompile(
+	`^(?:\* )?(\d\d\d\d-\d\d-\d\d(?:[ T]\d\d:\d\d(?::\d\d)?(?:Z|[+-]\d\d:\d\d))?) (\S+) \[(.*)\]\(/(\d+)\)$`,
+)
+
+// ChangesFile returns the name of the changes file (within the dex
+// directory) that a change made at the time of e would be appended to
+// (see YearlyChanges).
+func ChangesFile(e DexEntry) string {
+	if YearlyChanges {
+		return `changes-` + strconv.Itoa(e.U.UTC().Year()) + `.md`
+	}
+	return `changes.md`
+}
+
+// AppendChange appends a line for the change with action to the node of
+// the entry (at the time of the entry) to the changes file in the dex
+// directory of the keg (see ChangesFile) in the same format as DiffMD
+// (* 2023-01-14 15:04:05Z created [Title](/N)). MakeNode, UpdateNode,
+// DeleteNode, ArchiveNode, RestoreNode, RenumberNode, Absorb, Sync,
+// Node.Touch, and the Node methods that change attachments do so
+// automatically.
+func (k *Keg) AppendChange(e DexEntry, action string) error {
+	return appendChange(k.Local.Path, e, action)
+}
+
+func appendChange(kegpath string, e DexEntry, action string) error {
+	if action == "" || strings.ContainsAny(action, " \t\r\n") {
+		return fmt.Errorf("invalid change action: %q", action)
+	}
+	dexdir := filepath.Join(kegpath, `dex`)
+	if err := os.MkdirAll(dexdir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dexdir, ChangesFile(e)),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "* %v %v [%v](/%v)\n",
+		e.U.UTC().Format(IsoDateFmt), action, EscapeLinkText(e.T), e.N)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ParseChanges parses the changes file format (see AppendChange) in the
+// order read. Blank lines are skipped. Returns BadDexLine for the first
+// line that cannot be parsed.
+func ParseChanges(r io.Reader) ([]Change, error) {
+	var changes []Change
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSuffix(s.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		f := ChangeExp.FindStringSubmatch(text)
+		if f == nil {
+			return nil, BadDexLine{`changes.md`, line, nil}
+		}
+		t, err := ParseTime(f[1])
+		if err != nil {
+			return nil, BadDexLine{`changes.md`, line, err}
+		}
+		id, err := strconv.Atoi(f[4])
+		if err != nil {
+			return nil, BadDexLine{`changes.md`, line, err}
+		}
+		changes = append(changes, Change{
+			DexEntry: DexEntry{U: t, T: UnescapeLinkText(f[3]), N: id},
+			Action:   f[2],
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
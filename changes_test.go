@@ -0,0 +1,106 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleParseChanges() {
+	in := "* 2023-01-14 15:04:05Z created [Title](/3)\n\n" +
+		"2023-01-15 10:00:00Z deleted [With \\[brackets\\]](/3)\n"
+	changes, err := keg.ParseChanges(strings.NewReader(in))
+	for _, c := range changes {
+		fmt.Println(c.U.Format(keg.IsoDateFmt), c.Action, c.T, c.N)
+	}
+	fmt.Println(err)
+	// Output:
+	// 2023-01-14 15:04:05Z created Title 3
+	// 2023-01-15 10:00:00Z deleted With [brackets] 3
+	// <nil>
+}
+
+// readChanges returns every change (see ParseChanges) appended to the
+// dex/changes.md file of the keg at root as "action N Title" strings.
+func readChanges(t *testing.T, root string) []string {
+	t.Helper()
+	f, err := os.Open(filepath.Join(root, `dex`, `changes.md`))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		t.Fatal(err)
+	}
+	defer f.Close()
+	changes, err := keg.ParseChanges(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []string
+	for _, c := range changes {
+		list = append(list, fmt.Sprintf("%v %v %v", c.Action, c.N, c.T))
+	}
+	return list
+}
+
+func TestKeg_AppendChange(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Zero\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	k, _ := keg.Open(root)
+
+	node, err := k.MakeNode(`One`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), `data.csv`)
+	if err := os.WriteFile(src, []byte("a,b\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Attach(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.DeleteNode(node.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(root, `dex`, `changes.md`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	changes, err := keg.ParseChanges(f)
+	var actions []string
+	for _, c := range changes {
+		if c.N != 1 || c.T != `One` {
+			t.Errorf("unexpected change: %+v", c)
+		}
+		actions = append(actions, c.Action)
+	}
+	if want := []string{`created`, `updated`, `deleted`}; err != nil || !reflect.DeepEqual(actions, want) {
+		t.Errorf("got %v (%v) want %v", actions, err, want)
+	}
+
+	keg.YearlyChanges = true
+	t.Cleanup(func() { keg.YearlyChanges = false })
+	e := keg.DexEntry{U: old, T: `Old`, N: 7}
+	if err := k.AppendChange(e, `imported`); err != nil {
+		t.Fatal(err)
+	}
+	byt, _ := os.ReadFile(filepath.Join(root, `dex`, `changes-2022.md`))
+	if string(byt) != "* 2022-12-10 06:10:04Z imported [Old](/7)\n" {
+		t.Errorf("unexpected yearly changes file: %q", byt)
+	}
+	if err := k.AppendChange(e, `two words`); err == nil {
+		t.Error("expected invalid action refused")
+	}
+}
@@ -0,0 +1,299 @@
+package keg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckReport contains every problem found by Keg.Check. Each list is
+// sorted by node ID and empty (never nil) when there are no such
+// problems so that the JSON is always the same shape.
+type CheckReport struct {
+	NoDex         bool            `json:"nodex"`         // no dex files at all
+	MissingReadme []int           `json:"missingReadme"` // node dirs without README.md
+	NoTitle       []int           `json:"noTitle"`       // first line not a "# " title
+	MissingDir    []int           `json:"missingDir"`    // indexed but no node dir
+	Unindexed     []int           `json:"unindexed"`     // node dir not in the index
+	Duplicates    []int           `json:"duplicates"`    // more than once in the index
+	TitleMismatch []TitleMismatch `json:"titleMismatch"` // index title not README title
+	Stale         []int           `json:"stale"`         // changed since indexed
+	BrokenLinks   []BrokenLink    `json:"brokenLinks"`   // see Keg.BrokenLinks
+	BadMeta       []int           `json:"badMeta"`       // meta file not YAML (see Node.Meta)
+	Fixes         []string        `json:"fixes"`         // what AutoFix did (if anything)
+}
+
+// TitleMismatch is a node whose title in the index is not the same
+// as the title in its README.md.
+type TitleMismatch struct {
+	N      int    `json:"n"`
+	Index  string `json:"index"`
+	Readme string `json:"readme"`
+}
+
+// OK returns true if no problems were found.
+func (r CheckReport) OK() bool {
+	return !r.NoDex && len(r.MissingReadme)+len(r.NoTitle)+len(r.MissingDir)+
+		len(r.Unindexed)+len(r.Duplicates)+len(r.TitleMismatch)+len(r.Stale)+
+		len(r.BrokenLinks)+len(r.BadMeta) == 0
+}
+
+// String returns a human-readable summary of the report with one line
+// per kind of problem found (or "no problems found") followed by any
+// fixes made.
+func (r CheckReport) String() string {
+	var buf strings.Builder
+	ids := func(label string, list []int) {
+		if len(list) == 0 {
+			return
+		}
+		s := make([]string, 0, len(list))
+		for _, id := range list {
+			s = append(s, strconv.Itoa(id))
+		}
+		buf.WriteString(label + `: ` + strings.Join(s, `, `) + "\n")
+	}
+	if r.NoDex {
+		buf.WriteString("no dex files (dex/nodes.tsv or dex/latest.md)\n")
+	}
+	ids(`missing README.md`, r.MissingReadme)
+	ids(`no title line`, r.NoTitle)
+	ids(`indexed without directory`, r.MissingDir)
+	ids(`not indexed`, r.Unindexed)
+	ids(`duplicate index entries`, r.Duplicates)
+	for _, m := range r.TitleMismatch {
+		fmt.Fprintf(&buf, "title mismatch: %v: %q (index) != %q (README.md)\n",
+			m.N, m.Index, m.Readme)
+	}
+	ids(`changed since indexed`, r.Stale)
+	for _, b := range r.BrokenLinks {
+		buf.WriteString(b.String() + "\n")
+	}
+	ids(`invalid meta`, r.BadMeta)
+	if r.OK() {
+		buf.WriteString("no problems found\n")
+	}
+	for _, f := range r.Fixes {
+		buf.WriteString(`fixed: ` + f + "\n")
+	}
+	return buf.String()
+}
+
+// Problem is a single problem of a CheckReport (see Problems). N is -1
+// for problems with the keg as a whole (no-dex).
+type Problem struct {
+	Code   string `json:"code"`             // see ProblemLabels
+	N      int    `json:"n"`                // node ID
+	Detail string `json:"detail,omitempty"` // titles, link line, and so on
+}
+
+// String fulfills the fmt.Stringer interface as a single greppable line
+// with the code first followed by the node ID and any detail.
+func (p Problem) String() string {
+	s := p.Code
+	if p.N >= 0 {
+		s += ` ` + strconv.Itoa(p.N)
+	}
+	if p.Detail != "" {
+		s += ` ` + p.Detail
+	}
+	return s
+}
+
+// ProblemLabels maps the short code of every kind of Problem to a
+// human-readable description of it.
+var ProblemLabels = map[string]string{
+	`no-dex`:         `no dex files (dex/nodes.tsv or dex/latest.md)`,
+	`missing-readme`: `missing README.md`,
+	`no-title`:       `no title line`,
+	`missing-dir`:    `indexed without directory`,
+	`unindexed`:      `not indexed`,
+	`duplicate`:      `duplicate index entries`,
+	`title-mismatch`: `title mismatch`,
+	`stale`:          `changed since indexed`,
+	`broken-link`:    `broken links`,
+	`bad-meta`:       `invalid meta`,
+}
+
+// Problems returns every problem of the report as a single list
+// grouped by kind in the same order as String (and by node ID within
+// each kind).
+func (r CheckReport) Problems() []Problem {
+	list := []Problem{}
+	ids := func(code string, ids []int) {
+		for _, id := range ids {
+			list = append(list, Problem{Code: code, N: id})
+		}
+	}
+	if r.NoDex {
+		list = append(list, Problem{Code: `no-dex`, N: -1})
+	}
+	ids(`missing-readme`, r.MissingReadme)
+	ids(`no-title`, r.NoTitle)
+	ids(`missing-dir`, r.MissingDir)
+	ids(`unindexed`, r.Unindexed)
+	ids(`duplicate`, r.Duplicates)
+	for _, m := range r.TitleMismatch {
+		list = append(list, Problem{Code: `title-mismatch`, N: m.N,
+			Detail: fmt.Sprintf("%q (index) != %q (README.md)", m.Index, m.Readme)})
+	}
+	ids(`stale`, r.Stale)
+	for _, b := range r.BrokenLinks {
+		to := strconv.Itoa(b.To)
+		if b.Keg != "" {
+			to = `keg:` + b.Keg + `/` + to
+		}
+		list = append(list, Problem{Code: `broken-link`, N: b.From,
+			Detail: fmt.Sprintf("line %v to %v", b.Line, to)})
+	}
+	ids(`bad-meta`, r.BadMeta)
+	return list
+}
+
+// CheckOpts contains the options for CheckWith.
+type CheckOpts struct {
+	AutoFix bool // add missing title lines and rebuild the dex
+}
+
+// Check returns CheckWith using the default options (only reporting).
+func (k *Keg) Check() (CheckReport, error) { return k.CheckWith(CheckOpts{}) }
+
+// CheckWith checks the integrity of the keg reporting every problem it
+// knows how to detect (see CheckReport). With opts.AutoFix any README.md
+// without a title line gets one (from the index) added to the top and
+// the dex is rebuilt (see UpdateDex). The report returned is the one
+// from checking again after fixing and lists the fixes made. Content is
+// never removed. An error is only returned if unable to check at all.
+func (k *Keg) CheckWith(opts CheckOpts) (CheckReport, error) {
+	r, dex, err := k.check()
+	if err != nil || !opts.AutoFix {
+		return r, err
+	}
+	var fixes []string
+	index := dex.Map()
+	for _, id := range r.NoTitle {
+		e, has := index[id]
+		if !has || strings.TrimSpace(e.T) == "" {
+			continue
+		}
+		readme := filepath.Join(k.Path(id), `README.md`)
+		byt, err := os.ReadFile(readme)
+		if err != nil {
+			return r, err
+		}
+		if err := writeFileAtomic(readme, "# "+e.T+"\n\n"+string(byt)); err != nil {
+			return r, err
+		}
+		fixes = append(fixes, fmt.Sprintf("added title line to %v", id))
+	}
+	if !r.OK() {
+		if _, err := UpdateDex(k.Local.Path); err != nil {
+			if _, is := err.(Errors); !is {
+				return r, err
+			}
+		}
+		fixes = append(fixes, `rebuilt dex`)
+	}
+	r, _, err = k.check()
+	r.Fixes = append(r.Fixes, fixes...)
+	return r, err
+}
+
+// check returns the report along with the index it was based on.
+func (k *Keg) check() (CheckReport, Dex, error) {
+	r := CheckReport{
+		MissingReadme: []int{}, NoTitle: []int{}, MissingDir: []int{},
+		Unindexed: []int{}, Duplicates: []int{}, TitleMismatch: []TitleMismatch{},
+		Stale: []int{}, BrokenLinks: []BrokenLink{}, BadMeta: []int{}, Fixes: []string{},
+	}
+	dex, err := k.Dex()
+	if dex == nil {
+		return r, nil, err
+	}
+	r.NoDex = errors.Is(err, ErrDexRebuilt)
+	r.Duplicates = append(r.Duplicates, dex.Duplicates()...)
+	index := dex.Map()
+
+	_, broken, err := k.linkReport()
+	if err != nil {
+		return r, nil, err
+	}
+	r.BrokenLinks = append(r.BrokenLinks, broken...)
+
+	dirs, _, _ := NodePaths(k.Local.Path)
+	seen := map[int]bool{}
+	for _, d := range dirs {
+		name := filepath.Base(d.Path)
+		id, err := strconv.Atoi(name)
+		if err != nil || strconv.Itoa(id) != name {
+			continue
+		}
+		seen[id] = true
+		if _, err := (&Node{ID: id, Dir: d.Path}).Meta(); err != nil {
+			r.BadMeta = append(r.BadMeta, id)
+		}
+		readme := filepath.Join(d.Path, `README.md`)
+		title, hastitle, err := firstLineTitle(readme)
+		if os.IsNotExist(err) {
+			r.MissingReadme = append(r.MissingReadme, id)
+			continue
+		}
+		if err != nil {
+			return r, nil, err
+		}
+		if !hastitle {
+			r.NoTitle = append(r.NoTitle, id)
+		}
+		e, indexed := index[id]
+		if !indexed {
+			if !r.NoDex {
+				r.Unindexed = append(r.Unindexed, id)
+			}
+			continue
+		}
+		if hastitle && title != e.T {
+			r.TitleMismatch = append(r.TitleMismatch,
+				TitleMismatch{N: id, Index: e.T, Readme: title})
+		}
+		changed, err := NodeChanged(d.Path)
+		if err != nil {
+			return r, nil, err
+		}
+		if changed.Truncate(time.Second).After(e.U) {
+			r.Stale = append(r.Stale, id)
+		}
+	}
+	for _, e := range dex.ByID() {
+		if !seen[e.N] && (len(r.MissingDir) == 0 || r.MissingDir[len(r.MissingDir)-1] != e.N) {
+			r.MissingDir = append(r.MissingDir, e.N)
+		}
+	}
+	sort.Ints(r.Duplicates)
+	return r, dex, nil
+}
+
+// firstLineTitle returns the title from the first line of the
+// README.md at path (ignoring any byte order mark) and whether it is
+// a "# " title line at all.
+func firstLineTitle(path string) (string, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	if !s.Scan() {
+		return "", false, s.Err()
+	}
+	line := strings.TrimRight(strings.TrimPrefix(s.Text(), "\uFEFF"), "\r")
+	if !strings.HasPrefix(line, `# `) || strings.TrimSpace(line[2:]) == "" {
+		return "", false, nil
+	}
+	return strings.TrimSpace(line[2:]), true, nil
+}
@@ -0,0 +1,109 @@
+package keg_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleKeg_Check() {
+	k, _ := keg.Open(`testdata/samplekeg`)
+	r, err := k.Check()
+	fmt.Println(err, r.NoDex, len(r.MissingReadme), len(r.BrokenLinks))
+	// Output:
+	// <nil> false 0 0
+}
+
+func TestKeg_Check(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Zero\n", old)
+	mkNode(t, root, `1`, "# One\n\n[Gone](/9)\n", old)
+	mkNode(t, root, `2`, "# Two\n", old)
+	mkNode(t, root, `3`, "# Three\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	tsv := "0\t2022-12-10 06:10:04Z\tZero\n" +
+		"1\t2022-12-10 06:10:04Z\tOne\n" +
+		"1\t2022-12-10 06:10:04Z\tOne\n" +
+		"2\t2022-12-10 06:10:04Z\tOld Two\n" +
+		"3\t2022-12-10 06:10:04Z\tThree\n" +
+		"7\t2022-12-10 06:10:04Z\tSeven\n"
+	if err := os.WriteFile(filepath.Join(root, `dex`, `nodes.tsv`), []byte(tsv), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mkNode(t, root, `3`, "No title line\n", old.Add(time.Hour))
+	mkNode(t, root, `4`, "# Four\n", old)
+	mkNode(t, root, `5`, "", old)
+	k, _ := keg.Open(root)
+
+	r, err := k.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `missing README.md: 5
+no title line: 3
+indexed without directory: 7
+not indexed: 4
+duplicate index entries: 1
+title mismatch: 2: "Old Two" (index) != "Two" (README.md)
+changed since indexed: 3
+1:3: broken link to 9
+`
+	if r.OK() || r.String() != want {
+		t.Errorf("got:\n%v\nwant:\n%v", r, want)
+	}
+	byt, _ := json.Marshal(r)
+	wantjson := `{"nodex":false,"missingReadme":[5],"noTitle":[3],"missingDir":[7],` +
+		`"unindexed":[4],"duplicates":[1],"titleMismatch":[{"n":2,"index":"Old Two",` +
+		`"readme":"Two"}],"stale":[3],"brokenLinks":[{"from":1,"line":3,"to":9}],` +
+		`"badMeta":[],"fixes":[]}`
+	if string(byt) != wantjson {
+		t.Errorf("got:\n%s\nwant:\n%v", byt, wantjson)
+	}
+
+	var problems []string
+	for _, p := range r.Problems() {
+		problems = append(problems, p.String())
+	}
+	wantproblems := `missing-readme 5
+no-title 3
+missing-dir 7
+unindexed 4
+duplicate 1
+title-mismatch 2 "Old Two" (index) != "Two" (README.md)
+stale 3
+broken-link 1 line 3 to 9`
+	if got := strings.Join(problems, "\n"); got != wantproblems {
+		t.Errorf("got:\n%v\nwant:\n%v", got, wantproblems)
+	}
+	for _, p := range r.Problems() {
+		if keg.ProblemLabels[p.Code] == "" {
+			t.Errorf("no label for problem code %q", p.Code)
+		}
+	}
+
+	r, err = k.CheckWith(keg.CheckOpts{AutoFix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `missing README.md: 5
+1:3: broken link to 9
+fixed: added title line to 3
+fixed: rebuilt dex
+`
+	if r.String() != want {
+		t.Errorf("got:\n%v\nwant:\n%v", r, want)
+	}
+	readme, _ := os.ReadFile(filepath.Join(root, `3`, `README.md`))
+	if string(readme) != "# Three\n\nNo title line\n" {
+		t.Errorf("unexpected fixed README.md: %q", readme)
+	}
+}
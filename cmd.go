@@ -4,34 +4,58 @@
 package keg
 
 import (
+	"bufio"
+	"context"
 	_ "embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
+	"net"
+	"net/http"
 	"os"
-	"path"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/rwxrob/bonzai"
 	Z "github.com/rwxrob/bonzai/z"
 	"github.com/rwxrob/conf"
-	"github.com/rwxrob/fs"
 	"github.com/rwxrob/fs/file"
 	"github.com/rwxrob/help"
 	"github.com/rwxrob/term"
 	"github.com/rwxrob/vars"
 )
 
-func init() {
-	Z.Conf.SoftInit()
-	Z.Vars.SoftInit()
+// SoftInit creates the conf and vars files (see Z.Conf and Z.Vars) if
+// they do not exist yet. Importing this package never touches the
+// filesystem so that the model types can be used by any program. The
+// keg and kn commands call SoftInit before Cmd.Run and SetCurrent calls
+// it before setting the var. Programs composing Cmd into their own tree
+// should call it (or their own equivalent) before running it.
+func SoftInit() error {
+	if Z.Conf != nil {
+		if err := Z.Conf.SoftInit(); err != nil {
+			return err
+		}
+	}
+	if Z.Vars != nil {
+		return Z.Vars.SoftInit()
+	}
+	return nil
 }
 
 var Cmd = &Z.Cmd{
 	Name:      `keg`,
 	Aliases:   []string{`kn`},
 	Summary:   `manage knowledge exchange graphs (KEG)`,
-	Version:   `v0.1.0`,
+	Version:   `v0.2.0`,
 	Copyright: `Copyright 2022 Robert S Muhlestein`,
 	License:   `Apache-2.0`,
 	Site:      `rwxrob.tv`,
@@ -39,9 +63,9 @@ var Cmd = &Z.Cmd{
 	Issues:    `github.com/rwxrob/keg/issues`,
 
 	Commands: []*Z.Cmd{
-		editCmd, help.Cmd, conf.Cmd, vars.Cmd,
-		dexCmd, createCmd, currentCmd, dirCmd, deleteCmd,
-		latestCmd, titleCmd, initCmd,
+		help.Cmd, conf.Cmd, vars.Cmd, versionCmd,
+		editCmd, updateCmd, createCmd, currentCmd, switchCmd, dirCmd, deleteCmd,
+		latestCmd, titleCmd, listCmd, grepCmd, linkCmd, viewCmd, tagsCmd, randomCmd, checkCmd, publishCmd, serveCmd, syncCmd, initCmd,
 	},
 
 	Shortcuts: Z.ArgMap{
@@ -51,7 +75,7 @@ var Cmd = &Z.Cmd{
 	ConfVars: true,
 
 	Description: `
-		The {{aka}} command is for personal and public knowledge
+		The {{cmd .Name}} (or {{cmd "kn"}}) command is for personal and public knowledge
 		management as a Knowledge Exchange Graph (sometimes called "personal
 		knowledge graph" or "zettelkasten"). Using {{cmd .Name}} you can
 		create and share knowledge on the free, decentralized,
@@ -66,90 +90,148 @@ var Cmd = &Z.Cmd{
 		(while we work more on linting and validation within the {{cmd .Name}}
 		command) have a look at https://github.com/rwxrob/keg-spec
 
+		Run {{cmd "help"}} (the default when no command is given) for
+		a list of commands and {{cmd "version"}} for the installed version.
+
 		`,
 }
 
+var versionCmd = &Z.Cmd{
+	Name:     `version`,
+	Summary:  `print the version of the keg command`,
+	NoArgs:   true,
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Call: func(x *Z.Cmd, _ ...string) error {
+		term.Print(x.Caller.GetVersion())
+		return nil
+	},
+}
+
 var currentCmd = &Z.Cmd{
 	Name:     `current`,
-	Summary:  `show the current keg`,
+	Usage:    `(help|[NAME])`,
+	Summary:  `show (or set) the current keg`,
+	MaxArgs:  1,
+	Comp:     localNames{},
 	Commands: []*Z.Cmd{help.Cmd},
 
 	Description: `
-		The {{cmd .Name}} command displays the current keg by name, which is
+		The {{cmd .Name}} command displays the name and path (separated by
+		a tab) of the current keg used by every other command, which is
 		resolved as follows:
 
 		1. The {{pre "KEG_CURRENT"}} environment variable
-		2. The current working directory if {{pre "keg"}} file found
-		3. The {{pre "current"}} var setting (see {{cmd "var"}})
+		2. The {{pre "current"}} var setting (see {{cmd "var"}})
+		3. The keg containing the current working directory (if any)
 
-		Note that setting the var forces {{cmd .Name}} to always use that
-		setting until it is explicitly changed or temporarily overridden
-		with {{pre "KEG_CURRENT"}} environment variable.
+		Passing the NAME of a keg from the conf {{pre "map"}} sets the var
+		instead (the same as {{cmd "switch"}}).
 
-		It is often useful to have {{pre "current"}} set to the most
-		frequently used keg and then change into the working directory of
-		another, less updated, keg when needed.
+		Note that setting the var forces {{cmd .Name}} to always use that
+		setting (even from within another keg) until it is explicitly
+		changed or temporarily overridden with {{pre "KEG_CURRENT"}}
+		environment variable.
 
 	`,
 
 	Call: func(x *Z.Cmd, args ...string) error {
-		keg, err := current(x.Caller)
+		if len(args) > 0 {
+			return switchTo(x.Caller, args[0])
+		}
+		k, err := openKeg(x.Caller, "")
 		if err != nil {
 			return err
 		}
-		term.Print(keg.Name)
+		fmt.Printf("%v\t%v\n", k.Name, k.Local.Path)
 		return nil
 	},
 }
 
-var titleCmd = &Z.Cmd{
-	Name:     `titles`,
-	Aliases:  []string{`title`},
-	Summary:  `find titles containing keyword`,
+var switchCmd = &Z.Cmd{
+	Name:     `switch`,
+	Usage:    `(help|NAME)`,
+	Summary:  `set the current keg`,
+	NumArgs:  1,
+	Comp:     localNames{},
 	Commands: []*Z.Cmd{help.Cmd},
 
-	Call: func(x *Z.Cmd, args ...string) error {
-		if len(args) == 0 {
-			args = append(args, "")
-		}
-		keg, err := current(x.Caller)
-		if err != nil {
-			return err
-		}
-		str := strings.Join(args, " ")
-		var dex *Dex
-		dex, err = ReadDex(keg.Path)
-		if err != nil {
-			return err
-		}
-		if term.IsInteractive() {
-			Z.Page(dex.WithTitleText(str).Pretty())
-		} else {
-			fmt.Print(dex.WithTitleText(str).AsIncludes())
+	Description: `
+		The {{cmd .Name}} command sets the {{pre "current"}} var (see
+		{{cmd "current"}}) to the NAME of a keg in the conf {{pre "map"}}
+		so that every other command uses it from now on. The names
+		available are listed if NAME is not one of them.
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error { return switchTo(x.Caller, args[0]) },
+}
+
+// switchTo sets the current var (see SetCurrent) of the keg command x
+// to name listing the names available if it is not one of them.
+func switchTo(x *Z.Cmd, name string) error {
+	err := setCurrent(x.Path(`current`), name)
+	if errors.As(err, &LocalNotFound{}) {
+		locals, _ := Locals()
+		return fmt.Errorf("%w (available: %v)", err, names(locals))
+	}
+	return err
+}
+
+// localNames completes the names of the Locals.
+type localNames struct{}
+
+// Complete fulfills the bonzai.Completer interface.
+func (localNames) Complete(_ bonzai.Command, args ...string) []string {
+	locals, _ := Locals()
+	var list []string
+	for _, l := range locals {
+		if len(args) == 0 || strings.HasPrefix(l.Name, args[0]) {
+			list = append(list, l.Name)
 		}
-		return nil
-	},
+	}
+	return list
 }
 
 var dirCmd = &Z.Cmd{
 	Name:     `dir`,
 	Aliases:  []string{`d`},
-	MaxArgs:  1,
+	Usage:    `(help|[--keg NAME] [INTEGER_NODE_ID|TITLEWORD...])`,
 	Summary:  `print path to directory of current keg or node`,
 	Commands: []*Z.Cmd{help.Cmd},
 
+	Description: `
+		The {{cmd .Name}} command prints the full path to the directory of
+		the current keg (or the local keg named with {{pre "--keg"}}) or of
+		one of its nodes given its integer ID or words of its title (for
+		{{pre "cd $(keg dir 42)"}} and such). Nothing but the path is ever
+		printed (on a single line). If more than one title matches the
+		candidates are listed to standard error instead (never prompting)
+		and the exit code is 1.
+
+	`,
+
 	Call: func(x *Z.Cmd, args ...string) error {
-		keg, err := current(x.Caller)
+		flags, args, err := parseFlags(args, map[string]bool{`keg`: true})
 		if err != nil {
 			return err
 		}
-		if len(args) > 0 {
-			dex, _ := ReadDex(keg.Path)
-			choice := dex.ChooseWithTitleText(strings.Join(args, " "))
-			term.Print(filepath.Join(keg.Path, strconv.Itoa(choice.N)))
-		} else {
-			term.Print(keg.Path)
+		k, err := openKeg(x.Caller, flags[`keg`])
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			fmt.Println(k.Local.Path)
+			return nil
+		}
+		id, err := findNodeArg(k, args)
+		if err != nil {
+			if amb, is := err.(ErrAmbiguous); is {
+				fmt.Fprint(os.Stderr, amb.Hits.PrettyPlain())
+			}
+			return err
 		}
+		fmt.Println(k.Path(id))
 		return nil
 	},
 }
@@ -190,105 +272,347 @@ var deleteCmd = &Z.Cmd{
 	},
 }
 
+// current returns the Local of the CurrentKeg.
 func current(x *Z.Cmd) (*Local, error) {
-	var name, dir string
-
-	// if we have an env it beats config settings
-	name = os.Getenv(`KEG_CURRENT`)
-	dir, _ = x.C(`map.` + name)
-	if !(dir == "" || dir == "null") {
-		dir = fs.Tilde2Home(dir)
-		return &Local{Path: dir, Name: name}, nil
+	k, err := currentKeg(x.Path(`current`))
+	if err != nil {
+		return nil, err
 	}
+	return &k.Local, nil
+}
 
-	// check if current working directory has a keg
-	dir, _ = os.Getwd()
-	if fs.Exists(filepath.Join(dir, `keg`)) {
-		name = filepath.Base(dir)
-		return &Local{Path: dir, Name: name}, nil
-	}
+var updateCmd = &Z.Cmd{
+	Name:     `update`,
+	Aliases:  []string{`dex`},
+	Usage:    `(help|[--keg NAME|--all] [--check])`,
+	Summary:  `update dex/latest.md and dex/nodes.tsv`,
+	Commands: []*Z.Cmd{help.Cmd},
 
-	// check vars and conf
-	name, _ = x.Get(`current`)
-	if name != "" {
-		dir, _ = x.C(`map.` + name)
-		if !(dir == "" || dir == "null") {
-			dir = fs.Tilde2Home(dir)
-			return &Local{Path: dir, Name: name}, nil
-		}
-	}
+	Description: `
+		The {{cmd .Name}} command rebuilds the index files
+		({{pre "dex/nodes.tsv"}} and {{pre "dex/latest.md"}}) of the current
+		keg (or the local keg named with {{pre "--keg"}}) from its node
+		directories and prints how many entries changed compared to the
+		previous index ({{pre "3 updated, 1 new, 0 removed"}}). The updated
+		time in the keg info file is then set to that of the latest change.
+		Nodes that could not be read are reported (but do not stop the
+		update).
 
-	return nil, fmt.Errorf("no kegs found") // FIXME with better error
-}
+		With {{pre "--all"}} every keg in the conf {{pre "map"}} is updated
+		in turn and each summary is prefixed with its name and followed by
+		how long it took.
 
-var dexCmd = &Z.Cmd{
-	Name:     `dex`,
-	Commands: []*Z.Cmd{help.Cmd, dexUpdateCmd},
-	Summary:  `work with indexes`,
-}
+		With {{pre "--check"}} nothing is written. Instead every entry that
+		would change is printed (before the summary) and the exit code is
+		1 if there are any (for continuous integration).
+
+	`,
 
-var dexUpdateCmd = &Z.Cmd{
-	Name:     `update`,
-	Commands: []*Z.Cmd{help.Cmd},
-	Summary:  `update dex/latest.md and dex/nodes.tsv`,
 	Call: func(x *Z.Cmd, args ...string) error {
-		keg, err := current(x.Caller.Caller) // keg dex update
+		flags, args, err := parseFlags(args,
+			map[string]bool{`keg`: true, `all`: false, `check`: false})
 		if err != nil {
 			return err
 		}
-		return MakeDex(keg.Path)
+		if len(args) > 0 {
+			return x.UsageError()
+		}
+		_, all := flags[`all`]
+		_, check := flags[`check`]
+		var kegs []*Keg
+		if all {
+			locals, err := Locals()
+			if err != nil {
+				return err
+			}
+			for _, l := range locals {
+				if l.Missing {
+					log.Printf("skipping %v (missing): %v", l.Name, l.Path)
+					continue
+				}
+				k, err := openNamed(l.Name)
+				if err != nil {
+					return err
+				}
+				kegs = append(kegs, k)
+			}
+		} else {
+			k, err := openKeg(x.Caller, flags[`keg`])
+			if err != nil {
+				return err
+			}
+			kegs = append(kegs, k)
+		}
+		var stale int
+		for _, k := range kegs {
+			start := time.Now()
+			added, removed, changed, err := updateKeg(k, check)
+			if err != nil {
+				return err
+			}
+			if check {
+				fmt.Print(DiffMD(added, removed, changed))
+			}
+			stale += len(added) + len(removed) + len(changed)
+			summary := fmt.Sprintf("%v updated, %v new, %v removed",
+				len(changed), len(added), len(removed))
+			if all {
+				summary = fmt.Sprintf("%v: %v (%v)",
+					k.Name, summary, time.Since(start).Round(time.Millisecond))
+			}
+			fmt.Println(summary)
+		}
+		if check && stale > 0 {
+			return fmt.Errorf("index out of date: %v entries", stale)
+		}
+		return nil
 	},
 }
 
+// updateKeg updates the dex files of the keg (see UpdateDex) and the
+// updated time of its keg info file (see updateInfo), or only scans it
+// if check, and returns the Dex.Diff between the previous
+// dex/nodes.tsv file (if any) and the scan. Nodes that could not be read
+// are logged.
+func updateKeg(k *Keg, check bool) (added, removed, changed Dex, err error) {
+	old, err := readDexFile(k.Local.Path, `nodes.tsv`, parseDexTSV,
+		func(error) error { return nil })
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var cur Dex
+	if check {
+		cur, err = ScanDir(k.Local.Path)
+	} else {
+		cur, err = UpdateDex(k.Local.Path)
+	}
+	if warnings, is := err.(Errors); is {
+		for _, w := range warnings {
+			log.Printf("%v: %v", k.Name, w)
+		}
+	} else if err != nil {
+		return nil, nil, nil, err
+	}
+	if !check {
+		if err := updateInfo(k); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	for i := range cur {
+		cur[i].U = cur[i].U.Truncate(time.Second) // as written
+	}
+	added, removed, changed = old.Diff(cur)
+	return added, removed, changed, nil
+}
+
 var latestCmd = &Z.Cmd{
 	Name:     `latest`,
-	Aliases:  []string{`last`},
-	Summary:  `show last nodes changed (markdown)`,
+	Aliases:  []string{`last`, `l`},
+	Usage:    `(help|[--keg NAME] [--tsv|--md|--json|--include] [COUNT])`,
+	Summary:  `show last nodes changed`,
 	UseVars:  true,
 	Commands: []*Z.Cmd{help.Cmd, vars.Cmd},
 	Shortcuts: Z.ArgMap{
 		`default`: {`var`, `get`, `default`},
 		`set`:     {`var`, `set`},
 	},
+
+	Description: `
+		The {{cmd .Name}} command prints the COUNT nodes changed most
+		recently (most recent first) from the index of the current keg (or
+		the local keg named with {{pre "--keg"}}). The COUNT is the
+		{{pre "default"}} var (see {{cmd "set default"}}) when not passed
+		and 10 if that is not set either.
+
+		Each node is printed with its time, ID, and title (without color when
+		not to a terminal) unless one of {{pre "--tsv"}}, {{pre "--md"}},
+		{{pre "--json"}}, or {{pre "--include"}} is passed for the format of
+		the {{pre "dex/nodes.tsv"}} file, the {{pre "dex/latest.md"}} file,
+		JSON, or KEGML include links instead.
+
+	`,
+
 	Call: func(x *Z.Cmd, args ...string) error {
-		var err error
-		n := 1
-		if len(args) > 0 {
-			n, err = strconv.Atoi(args[0])
-			if err != nil {
+		flags, args, err := parseFlags(args, dexFlags(`keg`))
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			return x.UsageError()
+		}
+		n := 10
+		if def, _ := x.Get(`default`); def != "" {
+			if n, err = strconv.Atoi(def); err != nil {
 				return err
 			}
-		} else {
-			def, err := x.Get(`default`)
-			if err == nil && def != "" {
-				n, err = strconv.Atoi(def)
-				if err != nil {
-					return err
-				}
+		}
+		if len(args) > 0 {
+			if n, err = strconv.Atoi(args[0]); err != nil {
+				return err
 			}
 		}
-		keg, err := current(x.Caller)
+		if n < 1 {
+			return x.UsageError()
+		}
+		k, err := openKeg(x.Caller, flags[`keg`])
 		if err != nil {
 			return err
 		}
-		path := filepath.Join(keg.Path, `dex/latest.md`)
-		if !fs.Exists(path) {
-			return fmt.Errorf("dex/latest.md file does not exist")
+		dex, err := k.Dex()
+		if dex == nil {
+			return err
+		}
+		return printDex(dex.Last(n), flags)
+	},
+}
+
+// dexFlags returns the flags of printDex (for parseFlags) along with
+// the value flags named.
+func dexFlags(valued ...string) map[string]bool {
+	known := map[string]bool{`tsv`: false, `md`: false, `json`: false, `include`: false}
+	for _, name := range valued {
+		known[name] = true
+	}
+	return known
+}
+
+// printDex prints dexString.
+func printDex(dex Dex, flags map[string]string) error {
+	out, err := dexString(dex, flags)
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
+
+// dexString renders the Dex in the format of the flag (see dexFlags)
+// passed: TSV, MD, WriteJSON, AsIncludes, or Pretty (the default). Only
+// one format flag may be passed.
+func dexString(dex Dex, flags map[string]string) (string, error) {
+	var format string
+	for _, name := range []string{`tsv`, `md`, `json`, `include`} {
+		if _, has := flags[name]; has {
+			if format != "" {
+				return "", fmt.Errorf("only one of --%v and --%v allowed", format, name)
+			}
+			format = name
 		}
-		lines, err := file.Head(path, n)
+	}
+	switch format {
+	case `tsv`:
+		return dex.TSV(), nil
+	case `md`:
+		return dex.MD(), nil
+	case `json`:
+		return dex.buildString(dex.WriteJSON) + "\n", nil
+	case `include`:
+		return dex.AsIncludes(), nil
+	}
+	return dex.Pretty(), nil
+}
+
+// page prints out through the pager (see Z.Page) if it has more lines
+// than fit in the terminal (see term.WinSize) and simply prints it
+// otherwise (including whenever output is not to a terminal).
+func page(out string) error {
+	rows := int(term.WinSize.Row)
+	if !term.IsInteractive() || rows == 0 || strings.Count(out, "\n") < rows {
+		fmt.Print(out)
+		return nil
+	}
+	return Z.Page(out)
+}
+
+var titleCmd = &Z.Cmd{
+	Name:     `titles`,
+	Aliases:  []string{`title`},
+	Usage:    `(help|[--keg NAME] [KEYWORD...])`,
+	Summary:  `print titles (containing keyword) one per line`,
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Description: `
+		The {{cmd .Name}} command prints the title of every node of the
+		current keg (or the local keg named with {{pre "--keg"}}) one per
+		line in order of ID and nothing else (for use with {{exe "fzf"}} and
+		such). Only titles containing the KEYWORD (ignoring case) are
+		printed if passed (see {{cmd "list"}} for IDs and times).
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		flags, args, err := parseFlags(args, map[string]bool{`keg`: true})
 		if err != nil {
 			return err
 		}
-		dex, err := ParseDex(strings.Join(lines, "\n"))
+		k, err := openKeg(x.Caller, flags[`keg`])
 		if err != nil {
+			return err
+		}
+		dex, err := k.Dex()
+		if dex == nil {
+			return err
+		}
+		titles := dex.ByID().WithTitleText(strings.Join(args, " ")).Titles()
+		if len(titles) == 0 {
 			return nil
 		}
-		if term.IsInteractive() {
-			fmt.Print(dex.Pretty())
-		} else {
-			fmt.Print(dex.AsIncludes())
+		return page(strings.Join(titles, "\n") + "\n")
+	},
+}
+
+var listCmd = &Z.Cmd{
+	Name:     `list`,
+	Aliases:  []string{`ls`},
+	Usage:    `(help|[--keg NAME] [--sort latest|id|title] [--filter TEXT] [--tsv|--md|--json|--include])`,
+	Summary:  `print the index of nodes`,
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Description: `
+		The {{cmd .Name}} command prints every node in the index of the
+		current keg (or the local keg named with {{pre "--keg"}}) with its
+		time, ID, and title sorted by ID (or by {{pre "latest"}} change or
+		{{pre "title"}} given {{pre "--sort"}}). With {{pre "--filter"}}
+		only the nodes with titles containing the text (ignoring case) are
+		listed. The same format flags as {{cmd "latest"}} are supported.
+		Output longer than the terminal is paged (see {{pre "PAGER"}}).
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		flags, args, err := parseFlags(args, dexFlags(`keg`, `sort`, `filter`))
+		if err != nil {
+			return err
 		}
-		return nil
+		if len(args) > 0 {
+			return x.UsageError()
+		}
+		k, err := openKeg(x.Caller, flags[`keg`])
+		if err != nil {
+			return err
+		}
+		dex, err := k.Dex()
+		if dex == nil {
+			return err
+		}
+		switch flags[`sort`] {
+		case ``, `id`:
+			dex = dex.ByID()
+		case `latest`:
+			dex = dex.ByLatest()
+		case `title`:
+			dex = dex.ByTitle()
+		default:
+			return fmt.Errorf("unknown sort (latest, id, or title): %v", flags[`sort`])
+		}
+		if filter, has := flags[`filter`]; has {
+			dex = dex.WithTitleText(filter)
+		}
+		out, err := dexString(dex, flags)
+		if err != nil {
+			return err
+		}
+		return page(out)
 	},
 }
 
@@ -300,135 +624,1143 @@ var DefaultZeroNode string
 
 var initCmd = &Z.Cmd{
 	Name:     `init`,
-	Usage:    `(help)`,
-	Summary:  `initialize current working dir as new keg`,
+	Usage:    `(help|[--title TITLE] [--creator NAME] [--name NAME] [--force] [PATH])`,
+	Summary:  `initialize current working dir (or path) as new keg`,
 	Commands: []*Z.Cmd{help.Cmd},
 
 	Description: `
-		The {{cmd .Name}} command creates a {{pre "keg"}} YAML file in the
-		current working directory and opens it up for editing. 
+		The {{cmd .Name}} command creates a new keg in the current working
+		directory (or at the PATH passed, creating it if needed) with
+		a {{pre "keg"}} info file, the {{pre "dex/latest.md"}} and
+		{{pre "dex/nodes.tsv"}} index files, and a **zero node** (/0)
+		typically used for linking to planned content from other content
+		nodes.
 
-		{{cmd .Name}} also creates a **zero node** (/0) typically used for
-		linking to planned content from other content nodes. 
+		The title and creator of the keg are prompted for unless passed
+		with {{pre "--title"}} and {{pre "--creator"}} (an empty creator
+		uses the {{pre "user.name"}} from git or the {{pre "USER"}}
+		environment variable).
 
-		Finally, {{cmd .Name}} creates the {{pre "dex/latest.md"}} and 
-		{{pre "dex/nodex.tsv"}} index files and updates the {{pre "keg"}} file
-		update field to match the latest update (effectively the same as calling
-		{{cmd "dex update"}}).
+		The new keg is added to the {{pre "map"}} of the conf (see
+		{{cmd "conf"}}) named after its directory (or {{pre "--name"}}) so
+		that it can be used with {{pre "--keg"}} right away.
+
+		Creating a keg within another keg fails unless {{pre "--force"}} is
+		passed (which replaces the keg info file of an existing keg but
+		leaves its nodes and index files alone).
 
 	`,
 
-	Call: func(_ *Z.Cmd, _ ...string) error {
-		if fs.NotExists(`keg`) {
-			if err := file.Overwrite(`keg`, DefaultInfoFile); err != nil {
-				return err
-			}
-		}
-		if err := file.Overwrite(`0/README.md`, DefaultZeroNode); err != nil {
+	Call: func(x *Z.Cmd, args ...string) error {
+		flags, args, err := parseFlags(args, map[string]bool{
+			`title`: true, `creator`: true, `name`: true, `force`: false,
+		})
+		if err != nil {
 			return err
 		}
-		if err := file.Edit(`keg`); err != nil {
-			return err
+		if len(args) > 1 {
+			return x.UsageError()
+		}
+		path := `.`
+		if len(args) > 0 {
+			path = args[0]
+		}
+		title, has := flags[`title`]
+		if !has {
+			title = prompt(`Title: `)
+		}
+		if title == "" {
+			return fmt.Errorf("missing title")
 		}
-		dir, err := os.Getwd()
+		creator, has := flags[`creator`]
+		if !has {
+			creator = prompt(`Creator [%v]: `, defaultCreator())
+		}
+		_, force := flags[`force`]
+		k, err := InitKegWith(path, title, InitOpts{Force: force, Creator: creator})
 		if err != nil {
 			return err
 		}
-		if err := MakeDex(dir); err != nil {
+		name, has := flags[`name`]
+		if !has {
+			name = filepath.Base(k.Local.Path)
+		}
+		if err := AddLocal(name, k.Local.Path); err != nil {
 			return err
 		}
-		return Publish(dir)
+		fmt.Println(k.Local.Path)
+		return nil
 	},
 }
 
 var editCmd = &Z.Cmd{
 	Name:     `edit`,
 	Aliases:  []string{`e`},
-	Usage:    `(help|INTEGER_NODE_ID|last|TITLEWORD)`,
+	Usage:    `(help|[--touch-only] [INTEGER_NODE_ID|TITLEWORD...])`,
 	Summary:  `choose and edit a specific node`,
 	Commands: []*Z.Cmd{help.Cmd},
 
-	Call: func(x *Z.Cmd, args ...string) error {
-		if len(args) == 0 {
-			return help.Cmd.Call(x, args...)
-		}
-		if !term.IsInteractive() {
-			return titleCmd.Call(x, args...)
-		}
-		keg, err := current(x.Caller)
-		if err != nil {
-			return err
-		}
-		id := args[0]
-		if id == "last" {
-			if n := Last(keg.Path); n != nil {
-				id = n.ID()
-			}
-		} else {
-			_, err := strconv.Atoi(id)
-			if err != nil {
-				dex, err := ReadDex(keg.Path)
-				if err != nil {
-					return err
-				}
-				key := strings.Join(args, " ")
-				choice := dex.ChooseWithTitleText(key)
-				if choice == nil {
-					return fmt.Errorf("unable to choose a title")
-				}
-				id = strconv.Itoa(choice.N)
-			}
-		}
-		path := filepath.Join(keg.Path, id, `README.md`)
-		if !fs.Exists(path) {
-			return fmt.Errorf("content node (%s) does not exist in %q", id, keg.Name)
-		}
-		if err := file.Edit(path); err != nil {
-			return err
-		}
-		if err := MakeDex(keg.Path); err != nil {
-			return err
-		}
-		return Publish(keg.Path)
-	},
-}
+	Description: `
+		The {{cmd .Name}} command opens the {{pre "README.md"}} of a node of
+		the current keg for editing (see {{pre "VISUAL"}} and
+		{{pre "EDITOR"}}). The node is either the integer ID passed or
+		chosen by the words of its title (prompting when several titles
+		match). Without arguments the node most recently changed is opened.
 
-var createCmd = &Z.Cmd{
-	Name:     `create`,
-	Aliases:  []string{`c`},
-	Summary:  `create and edit content node`,
-	MaxArgs:  1,
-	Commands: []*Z.Cmd{help.Cmd},
+		After the editor exits the dex entry of the node is updated with its
+		current title and time of change along with the updated time in the
+		keg info file. With {{pre "--touch-only"}} no editor is opened and
+		the node is simply marked as changed now (for when it was edited
+		some other way). Either way, if the keg directory is a git repo the
+		change is then committed (and pulled and pushed if the repo has a
+		remote).
+
+	`,
 
 	Call: func(x *Z.Cmd, args ...string) error {
-		keg, err := current(x.Caller)
+		flags, args, err := parseFlags(args, map[string]bool{`touch-only`: false})
 		if err != nil {
 			return err
 		}
-		readme, err := MkTempNode()
+		k, err := openKeg(x.Caller, "")
 		if err != nil {
 			return err
 		}
-		if err := file.Edit(readme); err != nil {
+		id, err := nodeArg(k, args)
+		if err != nil {
 			return err
 		}
-		_, _, high := NodePaths(keg.Path)
-		if high < 0 {
-			high = 0
+		if _, touch := flags[`touch-only`]; !touch {
+			return editNode(k, id)
+		}
+		node, err := k.Node(id)
+		if err != nil {
+			return err
 		}
-		high++
-		if err := ImportNode(path.Dir(readme), keg.Path, strconv.Itoa(high)); err != nil {
+		if err := node.Touch(); err != nil {
 			return err
 		}
-		if err := MakeDex(keg.Path); err != nil {
+		if _, err := k.UpdateNode(id); err != nil {
 			return err
 		}
-		hd, _ := file.Head(filepath.Join(keg.Path, `dex`, `latest.md`), 1)
-		fmt.Println(hd[0])
-		return Publish(keg.Path)
+		return publishKeg(k)
 	},
 }
 
+// updateInfo sets the updated time of the keg info file to that of
+// the latest change (see UpdateUpdated) unless there is no such file or
+// no dex/latest.md to take it from.
+func updateInfo(k *Keg) error {
+	if _, err := os.Stat(filepath.Join(k.Local.Path, `keg`)); err != nil {
+		return nil
+	}
+	if _, err := Updated(k.Local.Path); err != nil {
+		return nil
+	}
+	return UpdateUpdated(k.Local.Path)
+}
+
+// publishKeg refreshes the updated time of the keg info file (see
+// updateInfo) and, if the keg directory is itself a git repo (like
+// Publish), commits every change within it (see Keg.GitCommit) and then
+// pulls (rebasing) and pushes when the repo has a remote.
+func publishKeg(k *Keg) error {
+	if err := updateInfo(k); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(k.Local.Path, `.git`)); err != nil {
+		return nil
+	}
+	if err := k.GitCommit(""); err != nil {
+		return err
+	}
+	if !k.GitRemote() {
+		return nil
+	}
+	if err := k.GitPull(); err != nil {
+		return err
+	}
+	return k.GitPush()
+}
+
+// editNode opens the README.md of the node with id in the editor (see
+// file.Edit) and updates its dex entry after (see Keg.UpdateNode) before
+// publishing the change (see publishKeg).
+func editNode(k *Keg, id int) error {
+	node, err := k.Node(id)
+	if err != nil {
+		return err
+	}
+	if err := file.Edit(node.ReadmePath()); err != nil {
+		return err
+	}
+	if _, err := k.UpdateNode(id); err != nil {
+		return err
+	}
+	return publishKeg(k)
+}
+
+var linkCmd = &Z.Cmd{
+	Name:     `link`,
+	Usage:    `(help|[--keg NAME] [--md|--id] [--clip] [INTEGER_NODE_ID|TITLEWORD...])`,
+	Summary:  `print include link line for a node`,
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Description: `
+		The {{cmd .Name}} command prints the KEGML include line
+		({{pre "* [Title](/N)"}}) for a node of the current keg (or the
+		local keg named with {{pre "--keg"}}) chosen the same way as
+		{{cmd "edit"}} for pasting into another node. Use {{pre "--md"}} for
+		the full line of {{pre "dex/latest.md"}} (with the time) or
+		{{pre "--id"}} for only the ID instead.
+
+		With {{pre "--clip"}} the line is also copied to the system
+		clipboard (using the first of {{exe "pbcopy"}}, {{exe "wl-copy"}},
+		{{exe "xclip"}}, {{exe "xsel"}}, or {{exe "clip.exe"}} found). If
+		none can be found it is only printed.
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		flags, args, err := parseFlags(args,
+			map[string]bool{`keg`: true, `md`: false, `id`: false, `clip`: false})
+		if err != nil {
+			return err
+		}
+		k, err := openKeg(x.Caller, flags[`keg`])
+		if err != nil {
+			return err
+		}
+		id, err := nodeArg(k, args)
+		if err != nil {
+			return err
+		}
+		e, err := entryOf(k, id)
+		if err != nil {
+			return err
+		}
+		line := e.AsInclude()
+		_, md := flags[`md`]
+		_, onlyid := flags[`id`]
+		switch {
+		case md && onlyid:
+			return fmt.Errorf("only one of --md and --id allowed")
+		case md:
+			line = e.MD()
+		case onlyid:
+			line = e.ID()
+		}
+		fmt.Println(line)
+		if _, clip := flags[`clip`]; clip {
+			if err := copyToClipboard(line); err != nil {
+				log.Println(err)
+			}
+		}
+		return nil
+	},
+}
+
+// entryOf returns the dex entry of the node with id or one made from
+// the node itself if it has not been indexed yet.
+func entryOf(k *Keg, id int) (DexEntry, error) {
+	if dex, _ := k.Dex(); dex != nil {
+		if e := dex.Get(id); e != nil {
+			return *e, nil
+		}
+	}
+	node, err := k.Node(id)
+	if err != nil {
+		return DexEntry{}, err
+	}
+	return DexEntry{U: node.Updated, T: node.Title, N: id}, nil
+}
+
+// clipboardTools are the commands (with arguments) tried in order by
+// copyToClipboard.
+var clipboardTools = [][]string{
+	{`pbcopy`},
+	{`wl-copy`},
+	{`xclip`, `-selection`, `clipboard`},
+	{`xsel`, `--clipboard`, `--input`},
+	{`clip.exe`},
+}
+
+// copyToClipboard copies text to the system clipboard with the first
+// of clipboardTools found in the PATH.
+func copyToClipboard(text string) error {
+	for _, tool := range clipboardTools {
+		path, err := exec.LookPath(tool[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, tool[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return fmt.Errorf("no clipboard tool found (pbcopy, wl-copy, xclip, xsel, clip.exe)")
+}
+
+var viewCmd = &Z.Cmd{
+	Name:     `view`,
+	Aliases:  []string{`cat`},
+	Usage:    `(help|[--keg NAME] [--raw] [INTEGER_NODE_ID|TITLEWORD...])`,
+	Summary:  `print a node rendered for the terminal`,
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Description: `
+		The {{cmd .Name}} command prints the {{pre "README.md"}} of a node
+		of the current keg (or the local keg named with {{pre "--keg"}})
+		chosen the same way as {{cmd "edit"}} rendered for reading in the
+		terminal: title in bold, include links with the titles of the nodes
+		they include, code blocks indented, and tags in color. Use
+		{{pre "--raw"}} for the text exactly as it is instead. Output longer
+		than the terminal is paged (see {{pre "PAGER"}}).
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		flags, args, err := parseFlags(args, map[string]bool{`keg`: true, `raw`: false})
+		if err != nil {
+			return err
+		}
+		k, err := openKeg(x.Caller, flags[`keg`])
+		if err != nil {
+			return err
+		}
+		id, err := nodeArg(k, args)
+		if err != nil {
+			return err
+		}
+		node, err := k.Node(id)
+		if err != nil {
+			return err
+		}
+		byt, err := os.ReadFile(node.ReadmePath())
+		if err != nil {
+			return err
+		}
+		if _, raw := flags[`raw`]; raw {
+			return page(string(byt))
+		}
+		dex, _ := k.Dex()
+		return page(TermView(string(byt), dex))
+	},
+}
+
+var tagsCmd = &Z.Cmd{
+	Name:     `tags`,
+	Aliases:  []string{`tag`},
+	Usage:    `(help|[--keg NAME] [--json] [TAG|missing])`,
+	Summary:  `list tags or the nodes carrying one`,
+	Comp:     tagNames{},
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Description: `
+		The {{cmd .Name}} command lists every tag (from the tag lines of the
+		nodes) of the current keg (or the local keg named with
+		{{pre "--keg"}}) after the number of nodes carrying it (most first).
+		Given a TAG (with or without the hashtag) the nodes carrying it are
+		listed instead. Given {{pre "missing"}} the nodes without any tags
+		are listed (use {{pre "#missing"}} for a tag of that name).
+
+		With {{pre "--json"}} the tags are a JSON array of objects with tag
+		and count (and nodes the same as {{cmd "list"}} with
+		{{pre "--json"}}).
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		flags, args, err := parseFlags(args, map[string]bool{`keg`: true, `json`: false})
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			return x.UsageError()
+		}
+		k, err := openKeg(x.Caller, flags[`keg`])
+		if err != nil {
+			return err
+		}
+		tags, err := k.Tags()
+		if err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			var dex Dex
+			if args[0] == `missing` {
+				all, err := k.Dex()
+				if all == nil {
+					return err
+				}
+				tagged := map[int]bool{}
+				for _, d := range tags {
+					for _, e := range d {
+						tagged[e.N] = true
+					}
+				}
+				dex = Dex{}
+				for _, e := range all.ByID() {
+					if !tagged[e.N] {
+						dex = append(dex, e)
+					}
+				}
+			} else if dex = tags[strings.TrimLeft(args[0], `#＃`)]; dex == nil {
+				dex = Dex{}
+			}
+			return printDex(dex, flags)
+		}
+		type count struct {
+			Tag   string `json:"tag"`
+			Count int    `json:"count"`
+		}
+		counts := make([]count, 0, len(tags))
+		width := 1
+		for tag, d := range tags {
+			counts = append(counts, count{tag, len(d)})
+			if w := len(strconv.Itoa(len(d))); w > width {
+				width = w
+			}
+		}
+		sort.Slice(counts, func(i, j int) bool {
+			if counts[i].Count != counts[j].Count {
+				return counts[i].Count > counts[j].Count
+			}
+			return counts[i].Tag < counts[j].Tag
+		})
+		if _, js := flags[`json`]; js {
+			byt, err := json.Marshal(counts)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(byt))
+			return nil
+		}
+		var buf strings.Builder
+		for _, c := range counts {
+			fmt.Fprintf(&buf, "%*d %v\n", width, c.Count, c.Tag)
+		}
+		return page(buf.String())
+	},
+}
+
+// tagNames completes the tags of the current keg (see Keg.Tags) for
+// the command within the keg command.
+type tagNames struct{}
+
+// Complete fulfills the bonzai.Completer interface.
+func (tagNames) Complete(x bonzai.Command, args ...string) []string {
+	cmd, is := x.(*Z.Cmd)
+	if !is || cmd.Caller == nil {
+		return nil
+	}
+	k, err := openKeg(cmd.Caller, "")
+	if err != nil {
+		return nil
+	}
+	tags, _ := k.Tags()
+	var list []string
+	for tag := range tags {
+		if len(args) == 0 || strings.HasPrefix(tag, args[0]) {
+			list = append(list, tag)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+var publishCmd = &Z.Cmd{
+	Name:     `publish`,
+	Aliases:  []string{`pub`},
+	Usage:    `(help|[--keg NAME] [--to DIR] [--private] [--commit|--push] [--rsync] [--dry-run])`,
+	Summary:  `publish keg as static web site`,
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Description: `
+		The {{cmd .Name}} command renders every node of the current keg (or
+		the local keg named with {{pre "--keg"}}) to HTML with an index page
+		in the target directory given with {{pre "--to"}} (or the
+		{{pre "publish.dir"}} value of the configuration file, see
+		{{cmd "conf"}}). Nodes tagged {{pre "#private"}} are left out unless
+		{{pre "--private"}} is given. Use {{pre "--dry-run"}} to list every
+		file that would be written without writing anything.
+
+		If the target directory is a git repo, {{pre "--commit"}} commits
+		the published files and {{pre "--push"}} also pushes them. With
+		{{pre "--rsync"}} the target directory is copied with rsync to the
+		destination given as {{pre "rsync"}} in the {{pre "urls"}} section
+		of the keg info file (files already there are never removed):
+
+		    urls:
+		      rsync: example.com:/var/www/keg
+		      public: https://example.com/keg
+
+		The public URL (the {{pre "public"}} value of the {{pre "urls"}}
+		section or the url of the keg info file if a web address) is printed
+		when done (or the target directory if the keg has none).
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		flags, args, err := parseFlags(args, map[string]bool{
+			`keg`: true, `to`: true, `private`: false, `commit`: false,
+			`push`: false, `rsync`: false, `dry-run`: false,
+		})
+		if err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			return x.UsageError()
+		}
+		k, err := openKeg(x.Caller, flags[`keg`])
+		if err != nil {
+			return err
+		}
+		dest := flags[`to`]
+		if dest == "" {
+			dest, _ = x.C(`dir`)
+			if dest == "null" {
+				dest = ""
+			}
+		}
+		if dest == "" {
+			return fmt.Errorf("no target directory (use --to or set publish.dir)")
+		}
+		dest, err = filepath.Abs(os.ExpandEnv(dest))
+		if err != nil {
+			return err
+		}
+		urls := map[string]string{}
+		if k.Info != nil {
+			urls = k.Info.URLs()
+		}
+		_, rsync := flags[`rsync`]
+		if rsync && urls[`rsync`] == "" {
+			return fmt.Errorf("no rsync destination in urls of keg info file")
+		}
+		_, private := flags[`private`]
+		opts := PublishOpts{Private: private}
+
+		if _, dry := flags[`dry-run`]; dry {
+			tmp, err := os.MkdirTemp("", `keg-publish-`)
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(tmp)
+			if err := k.PublishHTML(tmp, opts); err != nil {
+				return err
+			}
+			return filepath.Walk(tmp, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(tmp, path)
+				if err != nil {
+					return err
+				}
+				fmt.Println(filepath.Join(dest, rel))
+				return nil
+			})
+		}
+
+		if err := k.PublishHTML(dest, opts); err != nil {
+			return err
+		}
+		_, commit := flags[`commit`]
+		_, push := flags[`push`]
+		if commit || push {
+			out := &Keg{Local: Local{Name: k.Name, Path: dest}}
+			if err := out.GitCommit(`publish ` + k.Name); err != nil {
+				return err
+			}
+			if push {
+				if err := out.GitPush(); err != nil {
+					return err
+				}
+			}
+		}
+		if rsync {
+			if _, err := exec.LookPath(`rsync`); err != nil {
+				return fmt.Errorf("rsync not found")
+			}
+			cmd := exec.Command(`rsync`, `-a`, dest+string(filepath.Separator), urls[`rsync`])
+			cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("rsync: %w", err)
+			}
+		}
+		switch {
+		case urls[`public`] != "":
+			fmt.Println(urls[`public`])
+		case k.Info != nil && (strings.HasPrefix(k.Info.URL, `https://`) ||
+			strings.HasPrefix(k.Info.URL, `http://`)):
+			fmt.Println(k.Info.URL)
+		default:
+			fmt.Println(dest)
+		}
+		return nil
+	},
+}
+
+var serveCmd = &Z.Cmd{
+	Name:     `serve`,
+	Usage:    `(help|[--keg NAME] [--addr HOST:PORT] [--private])`,
+	Summary:  `browse keg with local web server`,
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Description: `
+		The {{cmd .Name}} command starts a web server (on localhost:8080
+		unless another address is given with {{pre "--addr"}}) for browsing
+		the current keg (or the local keg named with {{pre "--keg"}}) with
+		every node rendered to HTML when requested (so changes show up
+		immediately) the same way {{cmd "publish"}} would. The home page
+		lists the nodes from most to least recently updated, /N/ shows node
+		N (and /N/FILE its other files), and /search?q=WORDS lists the
+		nodes containing every word. Nodes tagged {{pre "#private"}} are not
+		served unless {{pre "--private"}} is given.
+
+		Use an address of {{pre ":8080"}} to share the keg with others on
+		the same network. The server runs until interrupted (Ctrl-C).
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		flags, args, err := parseFlags(args, map[string]bool{
+			`keg`: true, `addr`: true, `private`: false,
+		})
+		if err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			return x.UsageError()
+		}
+		k, err := openKeg(x.Caller, flags[`keg`])
+		if err != nil {
+			return err
+		}
+		addr, has := flags[`addr`]
+		if !has {
+			addr = `localhost:8080`
+		}
+		ln, err := net.Listen(`tcp`, addr)
+		if err != nil {
+			return err
+		}
+		_, private := flags[`private`]
+		srv := &http.Server{Handler: k.Handler(PublishOpts{Private: private})}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		done := make(chan error, 1)
+		go func() {
+			<-ctx.Done()
+			shut, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			done <- srv.Shutdown(shut)
+		}()
+		log.Printf("serving %v at http://%v/", k.Name, ln.Addr())
+		if err := srv.Serve(ln); err != http.ErrServerClosed {
+			return err
+		}
+		return <-done
+	},
+}
+
+var syncCmd = &Z.Cmd{
+	Name:     `sync`,
+	Usage:    `(help|[--keg NAME] [--resolved ID] [REMOTE])`,
+	Summary:  `pull changes from remote copy of keg`,
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Description: `
+		The {{cmd .Name}} command pulls the changes made to a remote copy of
+		the current keg (or the local keg named with {{pre "--keg"}}) into
+		it. The REMOTE is either the URL of the published keg (containing
+		dex/nodes.tsv) or the name of one in the conf map (see
+		{{cmd "conf"}}). Without one the {{pre "remote"}} value of the
+		{{pre "urls"}} section of the keg info file is used:
+
+		    urls:
+		      remote: https://example.com/keg
+
+		Remote nodes not yet in the keg are added and those changed
+		remotely since last synced (as recorded in dex/sync-state) replace
+		the local ones. Summary counts are printed when done. Nodes changed
+		on both sides are conflicts and are never resolved automatically:
+		each is listed (with the directory of the remote version fetched)
+		and left alone with an exit code of 1 (as are any remote nodes that
+		could not be fetched, which are tried again next time). Once
+		resolved (by hand) use {{pre "--resolved ID"}} to record the node as
+		synced again. Nodes removed remotely are counted but kept. Nothing
+		is ever pushed.
+
+		If there is no remote but the keg is within a git repo with a
+		remote then {{pre "git pull --rebase"}} is run instead (git stops on
+		any conflict and leaves it for you).
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		flags, args, err := parseFlags(args, map[string]bool{
+			`keg`: true, `resolved`: true,
+		})
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			return x.UsageError()
+		}
+		k, err := openKeg(x.Caller, flags[`keg`])
+		if err != nil {
+			return err
+		}
+		var url string
+		switch {
+		case len(args) > 0 && schemeExp.MatchString(args[0]):
+			url = args[0]
+		case len(args) > 0:
+			r, err := LookupRemote(args[0])
+			if err != nil {
+				return err
+			}
+			url = r.URL
+		case k.Info != nil:
+			url = k.Info.URLs()[`remote`]
+		}
+		ctx := context.Background()
+
+		if id, has := flags[`resolved`]; has {
+			n, err := strconv.Atoi(id)
+			if err != nil {
+				return err
+			}
+			if url == "" {
+				return fmt.Errorf("no remote to sync with")
+			}
+			return k.SyncResolved(ctx, url, n)
+		}
+
+		if url == "" {
+			if !k.GitRemote() {
+				return fmt.Errorf("no remote to sync with (give REMOTE or add to urls of keg info file)")
+			}
+			before, _ := readDexFile(k.Local.Path, `nodes.tsv`, parseDexTSV, func(error) error { return nil })
+			if err := k.GitPull(); err != nil {
+				return err
+			}
+			after, err := readDexFile(k.Local.Path, `nodes.tsv`, parseDexTSV, func(error) error { return nil })
+			if err != nil {
+				return err
+			}
+			added, removed, changed := before.Diff(after)
+			fmt.Printf("%v updated, %v new, %v removed\n", len(changed), len(added), len(removed))
+			return nil
+		}
+
+		r, err := k.Sync(ctx, url)
+		if warnings, is := err.(Errors); is {
+			for _, w := range warnings {
+				log.Print(w)
+			}
+		} else if err != nil {
+			return err
+		}
+		fmt.Print(r.String())
+		if len(r.Unfetched) > 0 {
+			return fmt.Errorf("%v nodes not fetched", len(r.Unfetched))
+		}
+		if len(r.Conflicts) > 0 {
+			return fmt.Errorf("%v conflicts", len(r.Conflicts))
+		}
+		return nil
+	},
+}
+
+var checkCmd = &Z.Cmd{
+	Name:     `check`,
+	Aliases:  []string{`doctor`},
+	Usage:    `(help|[--keg NAME] [--json] [--fix])`,
+	Summary:  `report (and fix) integrity problems`,
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Description: `
+		The {{cmd .Name}} command checks the integrity of the current keg
+		(or the local keg named with {{pre "--keg"}}) and prints every
+		problem found grouped by kind. Each problem line begins with a short
+		code (such as {{pre "broken-link"}} or {{pre "no-title"}}) followed
+		by the node ID so that it can be easily filtered with grep. The exit
+		code is 1 if any problems are found.
+
+		Use {{pre "--json"}} to print the full report (with a single list of
+		problems) as JSON instead (for CI pipelines and such) and
+		{{pre "--fix"}} to apply the safe automatic repairs first: adding
+		missing title lines from the index and rebuilding the index (which
+		syncs the titles with the README.md files). Content is never
+		removed. The problems remaining (if any) are reported afterward.
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		flags, args, err := parseFlags(args, map[string]bool{
+			`keg`: true, `json`: false, `fix`: false,
+		})
+		if err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			return x.UsageError()
+		}
+		k, err := openKeg(x.Caller, flags[`keg`])
+		if err != nil {
+			return err
+		}
+		_, fix := flags[`fix`]
+		r, err := k.CheckWith(CheckOpts{AutoFix: fix})
+		if err != nil {
+			return err
+		}
+		problems := r.Problems()
+		if _, js := flags[`json`]; js {
+			byt, err := json.Marshal(struct {
+				CheckReport
+				Problems []Problem `json:"problems"`
+			}{r, problems})
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(byt))
+		} else {
+			var last string
+			for _, p := range problems {
+				if p.Code != last {
+					fmt.Println(ProblemLabels[p.Code] + `:`)
+					last = p.Code
+				}
+				fmt.Println(`  ` + p.String())
+			}
+			if len(problems) == 0 {
+				fmt.Println(`no problems found`)
+			}
+			for _, f := range r.Fixes {
+				fmt.Println(`fixed: ` + f)
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%v problems found", len(problems))
+		}
+		return nil
+	},
+}
+
+var randomCmd = &Z.Cmd{
+	Name:     `random`,
+	Aliases:  []string{`rand`},
+	Usage:    `(help|[--keg NAME] [--stale] [--tag TAG] [--edit] [COUNT])`,
+	Summary:  `print (or edit) node chosen at random`,
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Description: `
+		The {{cmd .Name}} command prints the time, ID, and title of a node
+		(or COUNT different nodes) chosen at random from the index of the
+		current keg (or the local keg named with {{pre "--keg"}}) other
+		than the zero node. With {{pre "--edit"}} the node is opened for
+		editing instead (see {{cmd "edit"}}).
+
+		Use {{pre "--stale"}} to favor the nodes that have gone the longest
+		without change (for spaced review) and {{pre "--tag"}} to only
+		choose from the nodes carrying the tag (see {{cmd "tags"}}). The
+		exit code is 1 if there are no nodes to choose from.
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		flags, args, err := parseFlags(args, map[string]bool{
+			`keg`: true, `tag`: true, `stale`: false, `edit`: false,
+		})
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			return x.UsageError()
+		}
+		n := 1
+		if len(args) > 0 {
+			if n, err = strconv.Atoi(args[0]); err != nil {
+				return err
+			}
+		}
+		if n < 1 {
+			return x.UsageError()
+		}
+		k, err := openKeg(x.Caller, flags[`keg`])
+		if err != nil {
+			return err
+		}
+		pool, err := k.Dex()
+		if pool == nil {
+			return err
+		}
+		if tag, has := flags[`tag`]; has {
+			tags, err := k.Tags()
+			if err != nil {
+				return err
+			}
+			pool = tags[strings.TrimLeft(tag, `#＃`)]
+		}
+		pool = pool.WithoutZero()
+		if len(pool) == 0 {
+			return fmt.Errorf("no nodes to choose from")
+		}
+		_, stale := flags[`stale`]
+		chosen := pool.RandomWith(n, RandomOpts{
+			Rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+			Stale: stale,
+		})
+		if _, edit := flags[`edit`]; edit && len(chosen) > 0 {
+			return editNode(k, chosen[0].N)
+		}
+		fmt.Print(chosen.Pretty())
+		return nil
+	},
+}
+
+var grepCmd = &Z.Cmd{
+	Name:     `grep`,
+	Usage:    `(help|[--keg NAME] [-i] [-l|--open] REGEXP)`,
+	Summary:  `print lines of nodes matching regular expression`,
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Description: `
+		The {{cmd .Name}} command prints every line of the
+		{{pre "README.md"}} of every node of the current keg (or the local
+		keg named with {{pre "--keg"}}) matching the regular expression
+		(all arguments joined with spaces) after the ID and title of its
+		node (with color when to a terminal). Use {{pre "-i"}} to ignore
+		case and {{pre "-l"}} to print only the ID of each node with
+		a match, one per line.
+
+		With {{pre "--open"}} the node is opened for editing instead (see
+		{{cmd "edit"}}) if it is the only node with a match.
+
+		Like {{exe "grep"}}, the exit code is 1 if nothing matches.
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		flags, args, err := parseFlags(args,
+			map[string]bool{`keg`: true, `i`: false, `l`: false, `open`: false})
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return x.UsageError()
+		}
+		k, err := openKeg(x.Caller, flags[`keg`])
+		if err != nil {
+			return err
+		}
+		_, nocase := flags[`i`]
+		pattern := strings.Join(args, " ")
+		hits, err := k.GrepWith(pattern, GrepOpts{IgnoreCase: nocase})
+		if err != nil {
+			return err
+		}
+		if len(hits) == 0 {
+			return fmt.Errorf("no match: %v", pattern)
+		}
+		var ids []int
+		for _, h := range hits {
+			if len(ids) == 0 || ids[len(ids)-1] != h.N {
+				ids = append(ids, h.N)
+			}
+		}
+		if _, open := flags[`open`]; open && len(ids) == 1 {
+			return editNode(k, ids[0])
+		}
+		if _, list := flags[`l`]; list {
+			for _, id := range ids {
+				fmt.Println(id)
+			}
+			return nil
+		}
+		return page(hits.Pretty())
+	},
+}
+
+// nodeArg returns the ID of the node of k named by args: the integer ID
+// itself, the node chosen by the words of its title (see
+// Dex.ChooseWithTitleText), or the node changed most recently when there
+// are no args. Returns NodeNotFound for an ID without a node directory
+// and ErrNoMatch when nothing is chosen.
+func nodeArg(k *Keg, args []string) (int, error) { return nodeArgWith(k, args, true) }
+
+// findNodeArg is nodeArg without ever prompting (see
+// Dex.FindWithTitleText) returning ErrAmbiguous instead.
+func findNodeArg(k *Keg, args []string) (int, error) { return nodeArgWith(k, args, false) }
+
+func nodeArgWith(k *Keg, args []string, choose bool) (int, error) {
+	if len(args) == 1 {
+		if id, err := strconv.Atoi(args[0]); err == nil {
+			if !isDir(k.Path(id)) {
+				return id, NodeNotFound{id}
+			}
+			return id, nil
+		}
+	}
+	dex, err := k.Dex()
+	if dex == nil {
+		return -1, err
+	}
+	key := strings.Join(args, " ")
+	var choice *DexEntry
+	switch {
+	case len(args) == 0:
+		if last := dex.Last(1); len(last) > 0 {
+			choice = &last[0]
+		}
+	case choose:
+		choice = dex.ChooseWithTitleText(key)
+	default:
+		if choice, err = dex.FindWithTitleText(key); err != nil {
+			return -1, err
+		}
+	}
+	if choice == nil {
+		return -1, ErrNoMatch{key}
+	}
+	return choice.N, nil
+}
+
+// parseFlags separates the flags in args (before any -- argument) from
+// the rest. Flags start with one or two dashes and are named in known
+// (without dashes) along with whether they take a value (--name value or
+// --name=value). The returned map has every flag found (with an empty
+// value unless it takes one). Anything else starting with a dash
+// (other than a negative number or a single dash) is an unknown flag.
+func parseFlags(args []string, known map[string]bool) (map[string]string, []string, error) {
+	flags := map[string]string{}
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == `--` {
+			rest = append(rest, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' || (arg[1] >= '0' && arg[1] <= '9') {
+			rest = append(rest, arg)
+			continue
+		}
+		name := strings.TrimLeft(arg, `-`)
+		val, hasval := "", false
+		if n := strings.Index(name, `=`); n >= 0 {
+			name, val, hasval = name[:n], name[n+1:], true
+		}
+		takes, has := known[name]
+		switch {
+		case !has:
+			return nil, nil, fmt.Errorf("unknown flag: %v", arg)
+		case takes && !hasval:
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("flag needs a value: %v", arg)
+			}
+			i++
+			val = args[i]
+		case !takes && hasval:
+			return nil, nil, fmt.Errorf("flag takes no value: %v", arg)
+		}
+		flags[name] = val
+	}
+	return flags, rest, nil
+}
+
+var createCmd = &Z.Cmd{
+	Name:     `create`,
+	Aliases:  []string{`c`},
+	Usage:    `(help|[TITLE])`,
+	Summary:  `create and edit content node`,
+	Commands: []*Z.Cmd{help.Cmd},
+
+	Description: `
+		The {{cmd .Name}} command creates the next node of the current keg
+		(see {{cmd "current"}}) with the title passed as arguments (or read
+		from standard input when there are none) and opens its
+		{{pre "README.md"}} for editing (see {{pre "VISUAL"}} and
+		{{pre "EDITOR"}}). After the editor exits the dex entry is updated
+		with any change to the title along with the updated time in the keg
+		info file. If the keg directory is a git repo the change is then
+		committed (and pulled and pushed if the repo has a remote).
+
+		If nothing but the title was ever added the node can be deleted
+		again (after confirmation) so that abandoned nodes do not litter the
+		keg. In that case the exit code is 1.
+
+		The ID of the new node is the only output (to standard output) so
+		that scripts can capture it.
+
+	`,
+
+	Call: func(x *Z.Cmd, args ...string) error {
+		k, err := openKeg(x.Caller, "")
+		if err != nil {
+			return err
+		}
+		title := strings.Join(args, " ")
+		if title == "" {
+			title = prompt(`Title: `)
+		}
+		node, err := k.MakeNode(title)
+		if err != nil {
+			return err
+		}
+		if err := file.Edit(node.ReadmePath()); err != nil {
+			return err
+		}
+		byt, err := os.ReadFile(node.ReadmePath())
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(string(byt)) == `# `+title {
+			answer := prompt(`Delete empty node %v? [y/N] `, node.ID)
+			if strings.HasPrefix(strings.ToLower(answer), `y`) {
+				if err := k.DeleteNode(node.ID); err != nil {
+					return err
+				}
+				return fmt.Errorf("deleted empty node: %v", node.ID)
+			}
+		}
+		if _, err := k.UpdateNode(node.ID); err != nil {
+			return err
+		}
+		if err := publishKeg(k); err != nil {
+			return err
+		}
+		term.Print(node.ID)
+		return nil
+	},
+}
+
+// openKeg returns the local keg with name (or at path name, see
+// openNamed) or the current keg (see CurrentKeg) if name is empty. The
+// x passed is the keg command itself (the caller of the subcommand).
+func openKeg(x *Z.Cmd, name string) (*Keg, error) {
+	if name != "" {
+		return openNamed(name)
+	}
+	return currentKeg(x.Path(`current`))
+}
+
+// stdin is shared by every prompt so that nothing read ahead is lost.
+var stdin = bufio.NewReader(os.Stdin)
+
+// prompt prints the prompt to standard error (keeping standard output
+// for results) if standard input is a terminal and returns the next
+// line read from it (trimmed).
+func prompt(form string, args ...any) string {
+	if f, err := os.Stdin.Stat(); err == nil && f.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprintf(os.Stderr, form, args...)
+	}
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
 // ----------------------------- node ast -----------------------------
 
 /*
@@ -3,7 +3,16 @@
 
 package main
 
-import "github.com/rwxrob/keg"
+import (
+	"log"
+
+	"github.com/rwxrob/keg"
+)
 
 // tree grown from branch
-func main() { keg.Cmd.Run() }
+func main() {
+	if err := keg.SoftInit(); err != nil {
+		log.Print(err)
+	}
+	keg.Cmd.Run()
+}
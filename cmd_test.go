@@ -1,7 +1,1023 @@
-package keg
+package keg_test
 
-import "testing"
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
 
-func TestingCurrent(t *testing.T) {
-	t.Fail()
+	Z "github.com/rwxrob/bonzai/z"
+	"github.com/rwxrob/keg"
+)
+
+// TestRunKeg is not a test but the keg command itself when this test
+// binary is run again by runKeg.
+func TestRunKeg(t *testing.T) {
+	args := os.Getenv(`KEG_TEST_ARGS`)
+	if args == "" {
+		return
+	}
+	os.Args = append([]string{`keg`}, strings.Split(args, "\n")...)
+	if args == "\n" {
+		os.Args = os.Args[:1]
+	}
+	if err := keg.SoftInit(); err != nil {
+		t.Fatal(err)
+	}
+	keg.Cmd.Run()
+	os.Exit(0)
+}
+
+// runKeg runs the keg command with args (and any env) from within the
+// dir (if not empty) in a separate process (see TestRunKeg) with a new,
+// empty home directory and returns its standard output, standard error,
+// and exit code.
+func runKeg(t *testing.T, dir string, env []string, args ...string) (string, string, int) {
+	t.Helper()
+	return runKegInput(t, dir, "", env, args...)
+}
+
+// runKegInput is runKeg with the standard input passed.
+func runKegInput(t *testing.T, dir, stdin string, env []string, args ...string) (string, string, int) {
+	t.Helper()
+	home := t.TempDir()
+	cmd := exec.Command(os.Args[0], `-test.run=^TestRunKeg$`)
+	cmd.Dir = dir
+	joined := strings.Join(args, "\n")
+	if len(args) == 0 {
+		joined = "\n"
+	}
+	cmd.Env = append(os.Environ(),
+		`KEG_TEST_ARGS=`+joined,
+		`HOME=`+home,
+		`XDG_CONFIG_HOME=`+filepath.Join(home, `config`),
+		`XDG_CACHE_HOME=`+filepath.Join(home, `cache`),
+		`XDG_STATE_HOME=`+filepath.Join(home, `state`),
+		`KEG_CURRENT=`,
+		`PAGER=cat`,
+	)
+	cmd.Env = append(cmd.Env, env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if err != nil && !errors.As(err, &exit) {
+		t.Fatal(err)
+	}
+	return stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()
+}
+
+func TestCmd_version(t *testing.T) {
+	out, errout, code := runKeg(t, "", nil, `version`)
+	if code != 0 || out != keg.Cmd.Version { // no line return when piped (see term.Print)
+		t.Errorf("expected %q (exit 0), got %q (exit %v): %v", keg.Cmd.Version, out, code, errout)
+	}
+}
+
+func TestCmd_help(t *testing.T) {
+	for _, args := range [][]string{nil, {`help`}} {
+		out, errout, code := runKeg(t, "", nil, args...)
+		if code != 0 || !strings.Contains(out, `knowledge exchange graphs`) ||
+			!strings.Contains(out, `version`) || strings.Contains(errout, `template`) {
+			t.Errorf("%v: unexpected help (exit %v): %q %q", args, code, out, errout)
+		}
+	}
+}
+
+func TestCmd_Description(t *testing.T) {
+	desc := keg.Cmd.GetDescription()
+	if !strings.Contains(desc, `Knowledge Exchange Graph`) || strings.Contains(desc, `{{`) {
+		t.Errorf("description did not render: %q", desc)
+	}
+}
+
+// TestImport runs this test binary again (only this test) with every
+// place conf, vars, and cache files could go pointing into an empty
+// directory that must still be empty after the keg package (and its
+// Cmd) has been initialized.
+func TestImport(t *testing.T) {
+	if os.Getenv(`KEG_TEST_IMPORT`) != "" {
+		return // only package initialization matters
+	}
+	home := t.TempDir()
+	cmd := exec.Command(os.Args[0], `-test.run=^TestImport$`)
+	cmd.Env = append(os.Environ(),
+		`KEG_TEST_IMPORT=1`,
+		`HOME=`+home,
+		`XDG_CONFIG_HOME=`+filepath.Join(home, `config`),
+		`XDG_CACHE_HOME=`+filepath.Join(home, `cache`),
+		`XDG_STATE_HOME=`+filepath.Join(home, `state`),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	entries, err := os.ReadDir(home)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected import to touch no files, found %v (%v)", entries, err)
+	}
+}
+
+// editor writes a script that replaces the file it is passed with
+// content and returns the VISUAL env var that uses it (see file.Edit).
+func editor(t *testing.T, content string) string {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), `edit`)
+	body := "#!/bin/sh\ncat > \"$1\" <<'EOF'\n" + content + "EOF\n"
+	if content == "" {
+		body = "#!/bin/sh\n"
+	}
+	if err := os.WriteFile(script, []byte(body), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return `VISUAL=` + script
+}
+
+func TestCmd_create(t *testing.T) {
+	dir := copyKeg(t, `testdata/samplekeg`)
+	env := []string{editor(t, "# Renamed\n\nSome body.\n")}
+
+	out, errout, code := runKeg(t, dir, env, `create`, `New`, `node`)
+	if code != 0 || out != `13` {
+		t.Fatalf("expected 13, got %q (exit %v): %v", out, code, errout)
+	}
+	k, _ := keg.Open(dir)
+	dex, _ := k.Dex()
+	if e := dex.Get(13); e == nil || e.T != `Renamed` {
+		t.Errorf("expected renamed entry, got %v", e)
+	}
+
+	// title from standard input
+	out, errout, code = runKegInput(t, dir, "From input\n", env, `c`)
+	if code != 0 || out != `14` {
+		t.Errorf("expected 14, got %q (exit %v): %v", out, code, errout)
+	}
+
+	// nothing added, deleted after confirmation
+	env = []string{editor(t, "")}
+	_, errout, code = runKegInput(t, dir, "y\n", env, `create`, `Empty`)
+	if code != 1 || !strings.Contains(errout, `deleted empty node: 15`) || isDir(k.Path(15)) {
+		t.Errorf("expected empty node deleted (exit %v): %v", code, errout)
+	}
+	out, _, code = runKegInput(t, dir, "n\n", env, `create`, `Empty`)
+	if code != 0 || out != `15` || !isDir(k.Path(15)) {
+		t.Errorf("expected empty node kept, got %q (exit %v)", out, code)
+	}
+}
+
+// gitKeg returns a keg that is itself a git repo cloned from a new bare
+// origin (also returned) and pushed to it with a single node.
+func gitKeg(t *testing.T) (dir, origin string) {
+	t.Helper()
+	if _, err := exec.LookPath(`git`); err != nil {
+		t.Skip(`git not installed`)
+	}
+	origin = filepath.Join(t.TempDir(), `origin.git`)
+	dir = filepath.Join(t.TempDir(), `mine`)
+	git := func(args ...string) {
+		t.Helper()
+		if byt, err := exec.Command(`git`, args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, byt)
+		}
+	}
+	git(`init`, `-q`, `--bare`, origin)
+	git(`clone`, `-q`, origin, dir)
+	git(`-C`, dir, `config`, `user.name`, `Test`)
+	git(`-C`, dir, `config`, `user.email`, `test@example.com`)
+	if _, err := keg.InitKegWith(dir, `Mine`, keg.InitOpts{Creator: `Test`}); err != nil {
+		t.Fatal(err)
+	}
+	git(`-C`, dir, `add`, `-A`)
+	git(`-C`, dir, `commit`, `-q`, `-m`, `init`)
+	git(`-C`, dir, `push`, `-q`, `origin`, `HEAD`)
+	git(`-C`, dir, `branch`, `-q`, `--set-upstream-to`, `origin/`+gitBranch(t, dir))
+	return dir, origin
+}
+
+func gitBranch(t *testing.T, dir string) string {
+	t.Helper()
+	byt, err := exec.Command(`git`, `-C`, dir, `rev-parse`, `--abbrev-ref`, `HEAD`).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(byt))
+}
+
+// lastCommit returns the subject of the last commit of the git repo.
+func lastCommit(t *testing.T, repo string) string {
+	t.Helper()
+	byt, err := exec.Command(`git`, `-C`, repo, `log`, `-1`, `--format=%s`).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(byt))
+}
+
+// staleInfo sets the updated time of the keg info file in dir to long
+// ago.
+func staleInfo(t *testing.T, dir string) {
+	t.Helper()
+	path := filepath.Join(dir, `keg`)
+	info, err := keg.LoadKegInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info.Updated = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := info.Save(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// infoUpdated returns the updated line of the keg info file in dir.
+func infoUpdated(t *testing.T, dir string) string {
+	t.Helper()
+	info, err := keg.LoadKegInfo(filepath.Join(dir, `keg`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Updated.UTC().Format(keg.IsoDateFmt)
+}
+
+func TestCmd_create_git(t *testing.T) {
+	dir, origin := gitKeg(t)
+	staleInfo(t, dir)
+	env := []string{editor(t, "# Pushed\n\nBody.\n")}
+	out, errout, code := runKeg(t, dir, env, `create`, `Pushed`)
+	if code != 0 || out != `1` {
+		t.Fatalf("expected 1, got %q (exit %v): %v", out, code, errout)
+	}
+	if msg := lastCommit(t, origin); msg != `add 1 node` {
+		t.Errorf("expected new node pushed, got %q", msg)
+	}
+	k, _ := keg.Open(dir)
+	dex, _ := k.Dex()
+	if got, want := infoUpdated(t, dir), dex.ByLatest()[0].U.Format(keg.IsoDateFmt); got != want {
+		t.Errorf("expected keg info updated %v, got %v", want, got)
+	}
+	if dirty, err := k.GitDirty(); err != nil || dirty {
+		t.Errorf("expected everything committed: %v (%v)", dirty, err)
+	}
+}
+
+func TestCmd_edit_git(t *testing.T) {
+	dir, origin := gitKeg(t)
+	k, _ := keg.Open(dir)
+	old := func() {
+		t.Helper()
+		mkNode(t, dir, `1`, "# One\n", time.Now().Add(-time.Hour))
+		if _, err := keg.UpdateDex(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := k.GitCommit(`old`); err != nil {
+			t.Fatal(err)
+		}
+		staleInfo(t, dir)
+	}
+
+	old()
+	env := []string{editor(t, "# One Edited\n")}
+	if _, errout, code := runKeg(t, dir, env, `edit`, `1`); code != 0 {
+		t.Fatalf("edit failed (exit %v): %v", code, errout)
+	}
+	if msg := lastCommit(t, origin); msg != `update 1 node` {
+		t.Errorf("expected edit pushed, got %q", msg)
+	}
+	if got := infoUpdated(t, dir); strings.HasPrefix(got, `2000`) {
+		t.Errorf("keg info not updated: %v", got)
+	}
+
+	old()
+	if _, errout, code := runKeg(t, dir, nil, `edit`, `--touch-only`, `1`); code != 0 {
+		t.Fatalf("touch failed (exit %v): %v", code, errout)
+	}
+	if msg := lastCommit(t, origin); msg != `update 1 node` || lastCommit(t, dir) != msg {
+		t.Errorf("expected touch pushed, got %q", msg)
+	}
+	if got := infoUpdated(t, dir); strings.HasPrefix(got, `2000`) {
+		t.Errorf("keg info not updated: %v", got)
+	}
+	if dirty, err := k.GitDirty(); err != nil || dirty {
+		t.Errorf("expected everything committed: %v (%v)", dirty, err)
+	}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func TestCmd_edit(t *testing.T) {
+	dir := copyKeg(t, `testdata/samplekeg`)
+	k, _ := keg.Open(dir)
+	title := func(id int) string {
+		dex, _ := k.Dex()
+		if e := dex.Get(id); e != nil {
+			return e.T
+		}
+		return ""
+	}
+
+	for _, test := range []struct {
+		args []string
+		id   int
+	}{
+		{[]string{`edit`}, 6}, // latest
+		{[]string{`edit`, `3`}, 3},
+		{[]string{`e`, `title`, `for`, `12`}, 12},
+		{[]string{`edit`}, 12}, // edited last
+	} {
+		env := []string{editor(t, "# Edited "+test.args[len(test.args)-1]+"\n")}
+		_, errout, code := runKeg(t, dir, env, test.args...)
+		want := `Edited ` + test.args[len(test.args)-1]
+		if code != 0 || title(test.id) != want {
+			t.Errorf("%v: expected %q for %v, got %q (exit %v): %v",
+				test.args, want, test.id, title(test.id), code, errout)
+		}
+	}
+
+	before := time.Now().Add(-time.Second)
+	env := []string{`VISUAL=false`} // must not be opened
+	if _, errout, code := runKeg(t, dir, env, `edit`, `--touch-only`, `9`); code != 0 {
+		t.Fatalf("touch failed (exit %v): %v", code, errout)
+	}
+	if dex, _ := k.Dex(); dex.Get(9).U.Before(before.Truncate(time.Second)) {
+		t.Errorf("expected 9 touched, got %v", dex.Get(9))
+	}
+
+	for _, args := range [][]string{
+		{`edit`, `--bogus`, `1`},
+		{`edit`, `99`},
+		{`edit`, `--touch-only`, `nothing`, `matches`, `this`},
+	} {
+		if _, _, code := runKeg(t, dir, env, args...); code != 1 {
+			t.Errorf("%v: expected exit 1, got %v", args, code)
+		}
+	}
+}
+
+// kegConf writes the YAML content to the conf file the keg command run
+// by runKeg reads (see Z.Conf) and returns the env var pointing to it.
+func kegConf(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, Z.ExeName), 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, Z.ExeName, `config.yaml`)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return `XDG_CONFIG_HOME=` + dir
+}
+
+func TestCmd_latest(t *testing.T) {
+	sample, _ := filepath.Abs(`testdata/samplekeg`)
+	env := []string{kegConf(t, "map:\n  sample: "+sample+"\n")}
+	lines := func(out string) []string { return strings.Split(strings.TrimSpace(out), "\n") }
+
+	out, errout, code := runKeg(t, sample, nil, `latest`)
+	if got := lines(out); code != 0 || len(got) != 10 || !strings.Contains(got[0], `Some title for 6`) ||
+		strings.Contains(out, "\033") {
+		t.Errorf("unexpected latest (exit %v): %q %v", code, out, errout)
+	}
+
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{`last`, `--tsv`, `2`}, "6\t2022-11-17 18:34:10Z\tSome title for 6\n" +
+			"3\t2022-11-17 18:05:08Z\tSome title for 3\n"},
+		{[]string{`l`, `--md`, `1`}, "* 2022-11-17 18:34:10Z [Some title for 6](/6)\n"},
+		{[]string{`l`, `--include`, `1`}, "* [Some title for 6](/6)\n"},
+		{[]string{`latest`, `--json`, `1`}, `[{"U":"2022-11-17 18:34:10Z","N":6,"T":"Some title for 6"}]` + "\n"},
+	} {
+		out, errout, code := runKeg(t, t.TempDir(), env, append(test.args, `--keg`, `sample`)...)
+		if code != 0 || out != test.want {
+			t.Errorf("%v: expected %q, got %q (exit %v): %v", test.args, test.want, out, code, errout)
+		}
+	}
+
+	for _, args := range [][]string{
+		{`latest`, `--tsv`, `--md`},
+		{`latest`, `--keg`, `nope`},
+		{`latest`, `many`},
+		{`latest`, `0`},
+		{`latest`, `--`, `-3`},
+	} {
+		if _, _, code := runKeg(t, sample, env, args...); code != 1 {
+			t.Errorf("%v: expected exit 1, got %v", args, code)
+		}
+	}
+}
+
+func TestCmd_titles(t *testing.T) {
+	sample, _ := filepath.Abs(`testdata/samplekeg`)
+	out, errout, code := runKeg(t, sample, nil, `titles`)
+	if got := strings.Split(out, "\n"); code != 0 || len(got) != 14 ||
+		got[0] != `Some title for 0` || got[12] != `Some title for 12` {
+		t.Errorf("unexpected titles (exit %v): %q %v", code, out, errout)
+	}
+	env := []string{kegConf(t, "map:\n  sample: "+sample+"\n")}
+	out, errout, code = runKeg(t, t.TempDir(), env, `titles`, `--keg`, `sample`, `FOR 1`)
+	if want := "Some title for 1\nSome title for 10\nSome title for 11\nSome title for 12\n"; code != 0 || out != want {
+		t.Errorf("expected %q, got %q (exit %v): %v", want, out, code, errout)
+	}
+}
+
+func TestCmd_list(t *testing.T) {
+	sample, _ := filepath.Abs(`testdata/samplekeg`)
+	ids := func(out string) string {
+		var ids []string
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			ids = append(ids, strings.Split(line, "\t")[0])
+		}
+		return strings.Join(ids, " ")
+	}
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{`list`, `--tsv`}, `0 1 2 3 4 5 6 7 8 9 10 11 12`},
+		{[]string{`ls`, `--tsv`, `--sort`, `latest`}, `6 3 12 11 10 9 8 7 5 4 2 1 0`},
+		{[]string{`ls`, `--tsv`, `--sort=title`, `--filter`, `for 1`}, `1 10 11 12`},
+	} {
+		out, errout, code := runKeg(t, sample, nil, test.args...)
+		if code != 0 || ids(out) != test.want {
+			t.Errorf("%v: expected %v, got %v (exit %v): %v", test.args, test.want, ids(out), code, errout)
+		}
+	}
+	out, _, code := runKeg(t, sample, nil, `list`)
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); code != 0 || len(lines) != 13 ||
+		!strings.Contains(lines[12], `Some title for 12`) {
+		t.Errorf("unexpected list (exit %v): %q", code, out)
+	}
+	for _, args := range [][]string{{`list`, `--sort`, `size`}, {`list`, `extra`}} {
+		if _, _, code := runKeg(t, sample, nil, args...); code != 1 {
+			t.Errorf("%v: expected exit 1, got %v", args, code)
+		}
+	}
+}
+
+func TestCmd_grep(t *testing.T) {
+	dir := copyKeg(t, `testdata/samplekeg`)
+	mkNode(t, dir, `13`, "# Routing\n\nAdd a Static Route.\n\nAnother static route.\n", time.Now())
+
+	out, errout, code := runKeg(t, dir, nil, `grep`, `static`, `route`)
+	if want := "13 Routing:5: Another static route.\n"; code != 0 || out != want {
+		t.Errorf("expected %q, got %q (exit %v): %v", want, out, code, errout)
+	}
+	out, _, code = runKeg(t, dir, nil, `grep`, `-i`, `static route`)
+	if code != 0 || strings.Count(out, "\n") != 2 {
+		t.Errorf("expected 2 lines ignoring case, got %q (exit %v)", out, code)
+	}
+	out, _, code = runKeg(t, dir, nil, `grep`, `-l`, `-i`, `title for 1|route`)
+	if want := "1\n10\n11\n12\n13\n"; code != 0 || out != want {
+		t.Errorf("expected %q, got %q (exit %v)", want, out, code)
+	}
+	out, errout, code = runKeg(t, dir, nil, `grep`, `nothing matches this`)
+	if code != 1 || out != "" || errout == "" {
+		t.Errorf("expected exit 1 without output, got %q (exit %v)", out, code)
+	}
+
+	env := []string{editor(t, "# Opened\n")}
+	if _, errout, code := runKeg(t, dir, env, `grep`, `--open`, `Static`); code != 0 {
+		t.Fatalf("open failed (exit %v): %v", code, errout)
+	}
+	k, _ := keg.Open(dir)
+	if dex, _ := k.Dex(); dex.Get(13) == nil || dex.Get(13).T != `Opened` {
+		t.Errorf("expected node 13 edited and indexed")
+	}
+}
+
+func TestCmd_link(t *testing.T) {
+	sample, _ := filepath.Abs(`testdata/samplekeg`)
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{`link`, `title`, `for`, `12`}, "* [Some title for 12](/12)\n"},
+		{[]string{`link`, `--id`, `12`}, "12\n"},
+		{[]string{`link`, `--md`, `6`}, "* 2022-11-17 18:34:10Z [Some title for 6](/6)\n"},
+		{[]string{`link`}, "* [Some title for 6](/6)\n"}, // latest
+	} {
+		out, errout, code := runKeg(t, sample, nil, test.args...)
+		if code != 0 || out != test.want {
+			t.Errorf("%v: expected %q, got %q (exit %v): %v", test.args, test.want, out, code, errout)
+		}
+	}
+
+	// no clipboard tool, just printed
+	bin := t.TempDir()
+	env := []string{`PATH=` + bin}
+	out, errout, code := runKeg(t, sample, env, `link`, `--clip`, `3`)
+	if code != 0 || out != "* [Some title for 3](/3)\n" || !strings.Contains(errout, `no clipboard`) {
+		t.Errorf("expected fallback, got %q (exit %v): %v", out, code, errout)
+	}
+
+	clip := filepath.Join(t.TempDir(), `clip`)
+	script := "#!/bin/sh\n/bin/cat > " + clip + "\n"
+	if err := os.WriteFile(filepath.Join(bin, `wl-copy`), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, errout, code := runKeg(t, sample, env, `link`, `--clip`, `3`); code != 0 || errout != "" {
+		t.Errorf("clip failed (exit %v): %v", code, errout)
+	}
+	if byt, _ := os.ReadFile(clip); string(byt) != `* [Some title for 3](/3)` {
+		t.Errorf("unexpected clipboard: %q", byt)
+	}
+}
+
+func TestCmd_dir(t *testing.T) {
+	sample, _ := filepath.Abs(`testdata/samplekeg`)
+	env := []string{kegConf(t, "map:\n  sample: "+sample+"\n")}
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{`dir`}, sample},
+		{[]string{`d`, `7`}, filepath.Join(sample, `7`)},
+		{[]string{`dir`, `title`, `for`, `12`}, filepath.Join(sample, `12`)},
+		{[]string{`dir`, `Some title for 1`}, filepath.Join(sample, `1`)}, // exact
+	} {
+		out, errout, code := runKeg(t, t.TempDir(), env, append(test.args, `--keg`, `sample`)...)
+		if code != 0 || out != test.want+"\n" {
+			t.Errorf("%v: expected %q, got %q (exit %v): %v", test.args, test.want, out, code, errout)
+		}
+	}
+
+	out, errout, code := runKeg(t, sample, nil, `dir`, `for 1`)
+	if code != 1 || out != "" || !strings.Contains(errout, `Some title for 11`) ||
+		!strings.Contains(errout, `4 titles match`) {
+		t.Errorf("expected candidates listed (exit %v): %q %q", code, out, errout)
+	}
+	if _, _, code := runKeg(t, sample, nil, `dir`, `99`); code != 1 {
+		t.Errorf("expected exit 1 for missing node, got %v", code)
+	}
+}
+
+func TestCmd_view(t *testing.T) {
+	dir := copyKeg(t, `testdata/samplekeg`)
+	mkNode(t, dir, `13`, "# Viewed\n\n* [x](/3)\n", time.Now())
+	out, errout, code := runKeg(t, dir, nil, `view`, `13`)
+	if want := "Viewed\n\n* Some title for 3 (/3)\n"; code != 0 || out != want {
+		t.Errorf("expected %q, got %q (exit %v): %v", want, out, code, errout)
+	}
+	out, errout, code = runKeg(t, dir, nil, `cat`, `--raw`, `13`)
+	if want := "# Viewed\n\n* [x](/3)\n"; code != 0 || out != want {
+		t.Errorf("expected %q, got %q (exit %v): %v", want, out, code, errout)
+	}
+}
+
+func TestCmd_init(t *testing.T) {
+	conf := kegConf(t, "")
+	env := []string{conf}
+	dir := filepath.Join(t.TempDir(), `mykeg`)
+
+	out, errout, code := runKegInput(t, "", "My Keg\nMe\n", env, `init`, dir)
+	if code != 0 || out != dir+"\n" {
+		t.Fatalf("expected %q, got %q (exit %v): %v", dir, out, code, errout)
+	}
+	var files []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if want := `0/README.md dex/latest.md dex/nodes.tsv keg`; strings.Join(files, " ") != want {
+		t.Errorf("expected files %v, got %v", want, files)
+	}
+	info, err := keg.LoadKegInfo(filepath.Join(dir, `keg`))
+	if err != nil || info.Title != `My Keg` || info.Creator != `Me` {
+		t.Errorf("unexpected keg info: %v (%v)", info, err)
+	}
+
+	// registered in the conf map
+	out, errout, code = runKeg(t, "", env, `dir`, `--keg`, `mykeg`)
+	if code != 0 || out != dir+"\n" {
+		t.Errorf("expected %q, got %q (exit %v): %v", dir, out, code, errout)
+	}
+
+	// not within an existing keg unless forced
+	inner := filepath.Join(dir, `inner`)
+	_, errout, code = runKeg(t, "", env, `init`, `--title`, `Inner`, `--creator`, `Me`, inner)
+	if code != 1 || !strings.Contains(errout, `already within a keg`) {
+		t.Errorf("expected refusal (exit %v): %v", code, errout)
+	}
+	args := []string{`init`, `--title=Inner`, `--creator`, ``, `--name`, `in`, `--force`, inner}
+	if _, errout, code := runKeg(t, "", env, args...); code != 0 {
+		t.Errorf("expected forced init (exit %v): %v", code, errout)
+	}
+	out, _, _ = runKeg(t, "", env, `dir`, `--keg`, `in`)
+	if out != inner+"\n" {
+		t.Errorf("expected %q, got %q", inner, out)
+	}
+}
+
+func TestCmd_current(t *testing.T) {
+	sample, _ := filepath.Abs(`testdata/samplekeg`)
+	other := mkKeg(t)
+	env := []string{
+		kegConf(t, "map:\n  sample: "+sample+"\n  other: "+other+"\n"),
+		`XDG_CACHE_HOME=` + t.TempDir(), // keep vars between runs
+	}
+	cwd := t.TempDir()
+
+	if _, errout, code := runKeg(t, cwd, env, `current`); code != 1 || !strings.Contains(errout, `other, sample`) {
+		t.Errorf("expected no current keg (exit %v): %v", code, errout)
+	}
+	if _, errout, code := runKeg(t, cwd, env, `current`, `sample`); code != 0 {
+		t.Fatalf("set failed (exit %v): %v", code, errout)
+	}
+	out, errout, code := runKeg(t, cwd, env, `current`)
+	if want := "sample\t" + sample + "\n"; code != 0 || out != want {
+		t.Errorf("expected %q, got %q (exit %v): %v", want, out, code, errout)
+	}
+	if _, errout, code := runKeg(t, cwd, env, `switch`, `other`); code != 0 {
+		t.Fatalf("switch failed (exit %v): %v", code, errout)
+	}
+	if out, _, _ := runKeg(t, cwd, env, `dir`); out != other+"\n" {
+		t.Errorf("expected other keg used by dir, got %q", out)
+	}
+	_, errout, code = runKeg(t, cwd, env, `switch`, `nope`)
+	if code != 1 || !strings.Contains(errout, `available: other, sample`) {
+		t.Errorf("expected available kegs listed (exit %v): %v", code, errout)
+	}
+
+	out, _, _ = runKeg(t, cwd, append(env, `COMP_LINE=keg switch s`))
+	if out != "sample\n" {
+		t.Errorf("expected completion of sample, got %q", out)
+	}
+}
+
+func TestCmd_update(t *testing.T) {
+	dir := copyKeg(t, `testdata/samplekeg`) // copies are all changed now
+	out, errout, code := runKeg(t, dir, nil, `update`)
+	if code != 0 || out != "13 updated, 0 new, 0 removed\n" {
+		t.Fatalf("unexpected update %q (exit %v): %v", out, code, errout)
+	}
+	k, _ := keg.Open(dir)
+	dex, _ := k.Dex()
+	if got, want := infoUpdated(t, dir), dex.ByLatest()[0].U.Format(keg.IsoDateFmt); got != want {
+		t.Errorf("expected keg info updated %v, got %v", want, got)
+	}
+	staleInfo(t, dir)
+
+	mkNode(t, dir, `13`, "# Thirteen\n", time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err := os.RemoveAll(filepath.Join(dir, `12`)); err != nil {
+		t.Fatal(err)
+	}
+	out, _, code = runKeg(t, dir, nil, `dex`, `--check`)
+	if code != 1 || !strings.HasPrefix(out, "* 2023-01-02 03:04:05Z added [Thirteen](/13)\n") ||
+		!strings.HasSuffix(out, "0 updated, 1 new, 1 removed\n") {
+		t.Errorf("unexpected check %q (exit %v)", out, code)
+	}
+	if got := infoUpdated(t, dir); !strings.HasPrefix(got, `2000`) {
+		t.Errorf("keg info changed by --check: %v", got)
+	}
+	out, _, code = runKeg(t, dir, nil, `update`)
+	if code != 0 || out != "0 updated, 1 new, 1 removed\n" {
+		t.Errorf("unexpected update %q (exit %v)", out, code)
+	}
+	if got := infoUpdated(t, dir); strings.HasPrefix(got, `2000`) {
+		t.Errorf("keg info not updated: %v", got)
+	}
+	if out, _, code = runKeg(t, dir, nil, `update`, `--check`); code != 0 {
+		t.Errorf("expected nothing to update, got %q (exit %v)", out, code)
+	}
+
+	other := copyKeg(t, `testdata/samplekeg`)
+	env := []string{kegConf(t, "map:\n  one: "+dir+"\n  two: "+other+"\n")}
+	out, errout, code = runKeg(t, t.TempDir(), env, `update`, `--all`)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if code != 0 || len(lines) != 2 || !strings.HasPrefix(lines[0], `one: 0 updated, 0 new, 0 removed (`) ||
+		!strings.HasPrefix(lines[1], `two: 13 updated, 0 new, 0 removed (`) {
+		t.Errorf("unexpected update --all %q (exit %v): %v", out, code, errout)
+	}
+}
+
+func TestCmd_tags(t *testing.T) {
+	dir := mkKeg(t)
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, dir, `0`, "# Zero\n", old)
+	mkNode(t, dir, `1`, "# One\n\n#docker #net\n", old)
+	mkNode(t, dir, `2`, "# Two\n\n#docker\n", old)
+	mkNode(t, dir, `3`, "# Three\n\n#go\n", old)
+	if _, err := keg.UpdateDex(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{`tags`}, "2 docker\n1 go\n1 net\n"},
+		{[]string{`tags`, `--json`}, `[{"tag":"docker","count":2},{"tag":"go","count":1},{"tag":"net","count":1}]` + "\n"},
+		{[]string{`tags`, `--json`, `#docker`}, `[{"U":"2022-12-10 06:10:04Z","N":1,"T":"One"},` + "\n" +
+			`{"U":"2022-12-10 06:10:04Z","N":2,"T":"Two"}]` + "\n"},
+		{[]string{`tags`, `missing`}, "2022-12-10 06:10Z 0 Zero\n"},
+		{[]string{`tag`, `nope`}, ""},
+	} {
+		out, errout, code := runKeg(t, dir, nil, test.args...)
+		if code != 0 || strings.Join(strings.Fields(out), " ") != strings.Join(strings.Fields(test.want), " ") {
+			t.Errorf("%v: expected %q, got %q (exit %v): %v", test.args, test.want, out, code, errout)
+		}
+	}
+
+	if _, _, code := runKeg(t, dir, nil, `tags`, `--bogus`); code != 1 {
+		t.Errorf("expected exit 1 for unknown flag, got %v", code)
+	}
+	out, _, _ := runKeg(t, dir, []string{`COMP_LINE=keg tags d`})
+	if out != "docker\n" {
+		t.Errorf("expected completion of docker, got %q", out)
+	}
+}
+
+func TestCmd_random(t *testing.T) {
+	dir := mkKeg(t)
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, dir, `0`, "# Zero\n", old)
+	mkNode(t, dir, `1`, "# One\n\n#docker\n", old)
+	mkNode(t, dir, `2`, "# Two\n\n#go\n", old)
+	if _, err := keg.UpdateDex(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	out, errout, code := runKeg(t, dir, nil, `random`)
+	if code != 0 || (out != "2022-12-10 06:10Z 1 One\n" && out != "2022-12-10 06:10Z 2 Two\n") {
+		t.Errorf("expected one or two, got %q (exit %v): %v", out, code, errout)
+	}
+	for _, args := range [][]string{
+		{`random`, `--tag`, `go`},
+		{`rand`, `--stale`, `--tag`, `#go`},
+	} {
+		out, errout, code := runKeg(t, dir, nil, args...)
+		if code != 0 || out != "2022-12-10 06:10Z 2 Two\n" {
+			t.Errorf("%v: expected node 2, got %q (exit %v): %v", args, out, code, errout)
+		}
+	}
+	out, _, code = runKeg(t, dir, nil, `random`, `2`)
+	if code != 0 || len(strings.Split(strings.TrimSpace(out), "\n")) != 2 {
+		t.Errorf("expected two nodes, got %q (exit %v)", out, code)
+	}
+
+	if _, _, code := runKeg(t, dir, nil, `random`, `--tag`, `nope`); code != 1 {
+		t.Errorf("expected exit 1 for empty pool, got %v", code)
+	}
+	for _, args := range [][]string{{`random`, `0`}, {`random`, `--`, `-1`}} {
+		if out, _, code := runKeg(t, dir, nil, args...); code != 1 || out != "" {
+			t.Errorf("%v: expected exit 1 with no output, got %q (exit %v)", args, out, code)
+		}
+	}
+
+	_, errout, code = runKeg(t, dir, []string{editor(t, "# Edited\n")}, `random`, `--edit`, `--tag`, `go`)
+	if code != 0 {
+		t.Fatalf("edit failed (exit %v): %v", code, errout)
+	}
+	byt, err := os.ReadFile(filepath.Join(dir, `2`, `README.md`))
+	if err != nil || string(byt) != "# Edited\n" {
+		t.Errorf("expected node 2 edited, got %q: %v", byt, err)
+	}
+}
+
+func TestCmd_check(t *testing.T) {
+	dir := mkKeg(t)
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, dir, `0`, "# Zero\n", old)
+	mkNode(t, dir, `1`, "# One\n\n[Gone](/9)\n", old)
+	mkNode(t, dir, `2`, "# Two\n", old)
+	if _, err := keg.UpdateDex(dir); err != nil {
+		t.Fatal(err)
+	}
+	mkNode(t, dir, `2`, "# New Two\n", old)
+	mkNode(t, dir, `3`, "# Three\n", old)
+
+	out, errout, code := runKeg(t, dir, nil, `check`)
+	want := "not indexed:\n  unindexed 3\n" +
+		"title mismatch:\n  title-mismatch 2 \"Two\" (index) != \"New Two\" (README.md)\n" +
+		"broken links:\n  broken-link 1 line 3 to 9\n"
+	if code != 1 || out != want {
+		t.Errorf("expected exit 1 with:\n%v\ngot (exit %v):\n%v%v", want, code, out, errout)
+	}
+
+	out, _, code = runKeg(t, dir, nil, `doctor`, `--json`)
+	var r struct {
+		Problems []keg.Problem `json:"problems"`
+		Stale    []int         `json:"stale"`
+	}
+	if err := json.Unmarshal([]byte(out), &r); err != nil || code != 1 {
+		t.Fatalf("unexpected JSON (exit %v): %q: %v", code, out, err)
+	}
+	if len(r.Problems) != 3 || r.Problems[2].Code != `broken-link` || r.Problems[2].N != 1 {
+		t.Errorf("unexpected problems: %v", r.Problems)
+	}
+
+	out, _, code = runKeg(t, dir, nil, `check`, `--fix`)
+	want = "broken links:\n  broken-link 1 line 3 to 9\nfixed: rebuilt dex\n"
+	if code != 1 || out != want {
+		t.Errorf("expected exit 1 with:\n%v\ngot (exit %v):\n%v", want, code, out)
+	}
+	tsv, _ := os.ReadFile(filepath.Join(dir, `dex`, `nodes.tsv`))
+	if !strings.Contains(string(tsv), "\tNew Two\n") {
+		t.Errorf("expected title synced in index: %q", tsv)
+	}
+
+	mkNode(t, dir, `1`, "# One\n", old)
+	if _, err := keg.UpdateDex(dir); err != nil {
+		t.Fatal(err)
+	}
+	out, errout, code = runKeg(t, dir, nil, `check`)
+	if code != 0 || out != "no problems found\n" {
+		t.Errorf("expected no problems, got %q (exit %v): %v", out, code, errout)
+	}
+}
+
+func TestCmd_publish(t *testing.T) {
+	dir := mkKeg(t)
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, dir, `0`, "# Zero\n", old)
+	mkNode(t, dir, `1`, "# One\n\nSee [zero](/0).\n", old)
+	mkNode(t, dir, `2`, "# Secret\n\n#private\n", old)
+	if _, err := keg.UpdateDex(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), `site`)
+	got, errout, code := runKeg(t, dir, nil, `publish`, `--dry-run`, `--to`, out)
+	want := filepath.Join(out, `0`, `index.html`) + "\n" +
+		filepath.Join(out, `1`, `index.html`) + "\n" +
+		filepath.Join(out, `index.html`) + "\n"
+	if code != 0 || got != want {
+		t.Errorf("expected:\n%v\ngot (exit %v):\n%v%v", want, code, got, errout)
+	}
+	if isDir(out) {
+		t.Error("dry run wrote files")
+	}
+
+	got, errout, code = runKeg(t, dir, nil, `publish`, `--to`, out)
+	if code != 0 || got != out+"\n" {
+		t.Errorf("expected %q, got %q (exit %v): %v", out, got, code, errout)
+	}
+	byt, err := os.ReadFile(filepath.Join(out, `1`, `index.html`))
+	if err != nil || !strings.Contains(string(byt), `href="../0/"`) {
+		t.Errorf("unexpected page: %s (%v)", byt, err)
+	}
+	if isDir(filepath.Join(out, `2`)) {
+		t.Error("private node published")
+	}
+
+	info := "updated: 2022-11-26 19:33:24Z\n\nurls:\n  public: https://example.com/keg\n"
+	if err := os.WriteFile(filepath.Join(dir, `keg`), []byte(info), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := kegConf(t, "publish:\n  dir: "+out+"\n")
+	got, errout, code = runKeg(t, dir, []string{conf}, `publish`, `--private`)
+	if code != 0 || got != "https://example.com/keg\n" || !isDir(filepath.Join(out, `2`)) {
+		t.Errorf("expected public URL and private node, got %q (exit %v): %v", got, code, errout)
+	}
+
+	if _, _, code := runKeg(t, dir, nil, `publish`); code != 1 {
+		t.Errorf("expected exit 1 without target, got %v", code)
+	}
+	if _, _, code := runKeg(t, dir, nil, `publish`, `--rsync`, `--to`, out); code != 1 {
+		t.Errorf("expected exit 1 without rsync destination, got %v", code)
+	}
+
+	if _, err := exec.LookPath(`git`); err != nil {
+		t.Skip(`git not installed`)
+	}
+	for _, args := range [][]string{
+		{`init`, `-q`}, {`config`, `user.name`, `Test`}, {`config`, `user.email`, `test@example.com`},
+	} {
+		if byt, err := exec.Command(`git`, append([]string{`-C`, out}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, byt)
+		}
+	}
+	if _, errout, code := runKeg(t, dir, nil, `publish`, `--commit`, `--to`, out); code != 0 {
+		t.Fatalf("commit failed (exit %v): %v", code, errout)
+	}
+	byt, err = exec.Command(`git`, `-C`, out, `log`, `--format=%s`).Output()
+	if err != nil || string(byt) != "publish "+filepath.Base(dir)+"\n" {
+		t.Errorf("unexpected git log: %q (%v)", byt, err)
+	}
+}
+
+func TestCmd_serve(t *testing.T) {
+	dir := mkKeg(t)
+	mkNode(t, dir, `0`, "# Zero\n", time.Now().Add(-time.Hour))
+	if _, err := keg.UpdateDex(dir); err != nil {
+		t.Fatal(err)
+	}
+	home := t.TempDir()
+	cmd := exec.Command(os.Args[0], `-test.run=^TestRunKeg$`)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"KEG_TEST_ARGS=serve\n--addr\n127.0.0.1:0",
+		`HOME=`+home,
+		`XDG_CONFIG_HOME=`+filepath.Join(home, `config`),
+		`XDG_CACHE_HOME=`+filepath.Join(home, `cache`),
+		`KEG_CURRENT=`,
+	)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	line, err := bufio.NewReader(stderr).ReadString('\n')
+	if err != nil {
+		t.Fatalf("no output: %v", err)
+	}
+	i := strings.Index(line, `http://`)
+	if i < 0 {
+		t.Fatalf("no address in %q", line)
+	}
+	res, err := http.Get(strings.TrimSpace(line[i:]) + `0/`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != 200 || !strings.Contains(string(body), `<h1>Zero</h1>`) {
+		t.Errorf("unexpected response (%v):\n%s", res.StatusCode, body)
+	}
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("expected clean shutdown, got %v", err)
+	}
+}
+
+func TestCmd_sync(t *testing.T) {
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	theirs := mkKeg(t)
+	mkNode(t, theirs, `0`, "# Zero\n", old)
+	mkNode(t, theirs, `1`, "# One\n", old)
+	if _, err := keg.UpdateDex(theirs); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(theirs)))
+	defer srv.Close()
+
+	mine := mkKeg(t)
+	mkNode(t, mine, `0`, "# Zero Local\n", old.Add(time.Hour))
+	if _, err := keg.UpdateDex(mine); err != nil {
+		t.Fatal(err)
+	}
+	cache := `XDG_CACHE_HOME=` + t.TempDir()
+
+	out, errout, code := runKeg(t, mine, []string{cache}, `sync`, srv.URL)
+	if code != 1 || !strings.HasPrefix(out, "0 updated, 1 new, 0 removed remotely, 1 conflicts\nconflict: 0: ") {
+		t.Errorf("expected conflict, got %q (exit %v): %v", out, code, errout)
+	}
+	if _, err := os.Stat(filepath.Join(mine, `1`, `README.md`)); err != nil {
+		t.Errorf("new node not synced: %v", err)
+	}
+
+	info := "updated: 2022-11-26 19:33:24Z\n\nurls:\n  remote: " + srv.URL + "\n"
+	if err := os.WriteFile(filepath.Join(mine, `keg`), []byte(info), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, errout, code := runKeg(t, mine, []string{cache}, `sync`, `--resolved`, `0`); code != 0 {
+		t.Fatalf("resolve failed (exit %v): %v", code, errout)
+	}
+	out, errout, code = runKeg(t, mine, []string{cache}, `sync`)
+	if code != 0 || out != "0 updated, 0 new, 0 removed remotely, 0 conflicts\n" {
+		t.Errorf("expected nothing to sync, got %q (exit %v): %v", out, code, errout)
+	}
+
+	if _, _, code := runKeg(t, theirs, nil, `sync`); code != 1 {
+		t.Errorf("expected exit 1 without remote, got %v", code)
+	}
+
+	repo, k := gitRepo(t)
+	clone := filepath.Join(t.TempDir(), `clone`)
+	if byt, err := exec.Command(`git`, `clone`, `-q`, repo, clone).CombinedOutput(); err != nil {
+		t.Fatalf("git clone: %v\n%s", err, byt)
+	}
+	mkNode(t, k.Local.Path, `2`, "# Two\n", time.Now())
+	if _, err := keg.UpdateDex(k.Local.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.GitCommit(``); err != nil {
+		t.Fatal(err)
+	}
+	out, errout, code = runKeg(t, filepath.Join(clone, `kegs`, `mine`), nil, `sync`)
+	if code != 0 || out != "0 updated, 1 new, 0 removed\n" {
+		t.Errorf("expected git pull, got %q (exit %v): %v", out, code, errout)
+	}
 }
@@ -0,0 +1,97 @@
+package keg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	Z "github.com/rwxrob/bonzai/z"
+	"github.com/rwxrob/fs"
+)
+
+// CurrentVar returns the key of the current var within Z.Vars (which
+// depends on where Cmd is composed into the command tree).
+func CurrentVar() string { return Cmd.Path(`current`) }
+
+// CurrentKeg returns the keg to operate on resolved (in order) from:
+//
+//  1. The KEG_CURRENT environment variable (name of local or path)
+//  2. The current var (see SetCurrent and CurrentVar)
+//  3. The keg containing the working directory (even in a node)
+//
+// Names are looked up with LookupLocal. Returns NoCurrentKeg (listing
+// the configured Locals) if none of them resolve to a keg.
+func CurrentKeg() (*Keg, error) { return currentKeg(CurrentVar()) }
+
+// currentKeg is CurrentKeg with the key of the current var passed
+// (since commands within Cmd cannot refer to it during initialization).
+func currentKeg(key string) (*Keg, error) {
+	if name := os.Getenv(`KEG_CURRENT`); name != "" {
+		return openNamed(name)
+	}
+	if Z.Vars != nil {
+		if name := Z.Vars.Get(key); name != "" {
+			return openNamed(name)
+		}
+	}
+	if dir, err := os.Getwd(); err == nil {
+		for ; ; dir = filepath.Dir(dir) {
+			if fs.Exists(filepath.Join(dir, `keg`)) {
+				return Open(dir)
+			}
+			if dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+	locals, _ := Locals()
+	return nil, NoCurrentKeg{locals}
+}
+
+// openNamed opens the local keg with name or the keg at path name if
+// there is no local with that name.
+func openNamed(name string) (*Keg, error) {
+	l, err := LookupLocal(name)
+	if err != nil {
+		if dir := fs.Tilde2Home(name); isDir(dir) {
+			return Open(dir)
+		}
+		return nil, err
+	}
+	k, err := Open(l.Path)
+	if err != nil {
+		return nil, err
+	}
+	k.Name = l.Name
+	return k, nil
+}
+
+// SetCurrent sets the current var (see CurrentKeg) to the name of
+// a local keg (see LookupLocal) so that it is used until changed. The
+// vars file is created first if needed (see SoftInit).
+func SetCurrent(name string) error { return setCurrent(CurrentVar(), name) }
+
+// setCurrent is SetCurrent with the key of the current var passed (see
+// currentKeg).
+func setCurrent(key, name string) error {
+	if Z.Vars == nil {
+		return fmt.Errorf("no persistent vars (Z.Vars) available")
+	}
+	if _, err := LookupLocal(name); err != nil {
+		return err
+	}
+	if err := Z.Vars.SoftInit(); err != nil {
+		return err
+	}
+	return Z.Vars.Set(key, name)
+}
+
+// names returns the names of the locals joined with commas.
+func names(locals []Local) string {
+	n := make([]string, 0, len(locals))
+	for _, l := range locals {
+		n = append(n, l.Name)
+	}
+	return strings.Join(n, ", ")
+}
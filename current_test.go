@@ -0,0 +1,125 @@
+package keg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	Z "github.com/rwxrob/bonzai/z"
+	"github.com/rwxrob/keg"
+	"github.com/rwxrob/vars"
+)
+
+// useVars points Z.Vars at a new, empty, temporary vars file for the
+// rest of the test.
+func useVars(t *testing.T) {
+	t.Helper()
+	m := vars.New()
+	m.Id, m.Dir, m.File = `keg`, t.TempDir(), `vars`
+	if err := m.SoftInit(); err != nil {
+		t.Fatal(err)
+	}
+	orig := Z.Vars
+	Z.Vars = &m
+	t.Cleanup(func() { Z.Vars = orig })
+}
+
+// chdir changes the working directory for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	orig, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(orig) })
+}
+
+// mkKeg creates a minimal keg (just the keg info file) within a new
+// temporary directory and returns its path.
+func mkKeg(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, `keg`),
+		[]byte("updated: 2022-11-26 19:33:24Z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestCurrentKeg_env(t *testing.T) {
+	dir := mkKeg(t)
+	useConf(t, "map:\n  mine: "+dir+"\n")
+	useVars(t)
+	if err := Z.Vars.Set(keg.CurrentVar(), `other`); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(`KEG_CURRENT`, `mine`)
+	k, err := keg.CurrentKeg()
+	if err != nil || k.Name != `mine` || k.Local.Path != dir {
+		t.Errorf("got %v (%v) want mine at %v", k, err, dir)
+	}
+
+	path := mkKeg(t)
+	t.Setenv(`KEG_CURRENT`, path) // paths work as well as names
+	k, err = keg.CurrentKeg()
+	if err != nil || k.Local.Path != path {
+		t.Errorf("got %v (%v) want %v", k, err, path)
+	}
+}
+
+func TestCurrentKeg_var(t *testing.T) {
+	dir := mkKeg(t)
+	useConf(t, "map:\n  mine: "+dir+"\n")
+	useVars(t)
+	t.Setenv(`KEG_CURRENT`, ``)
+	chdir(t, mkKeg(t)) // var beats working directory
+	if err := keg.SetCurrent(`mine`); err != nil {
+		t.Fatal(err)
+	}
+	if got := Z.Vars.Get(keg.CurrentVar()); got != `mine` {
+		t.Errorf("var not set: %q", got)
+	}
+	k, err := keg.CurrentKeg()
+	if err != nil || k.Name != `mine` || k.Local.Path != dir {
+		t.Errorf("got %v (%v) want mine at %v", k, err, dir)
+	}
+
+	var notfound keg.LocalNotFound
+	if err := keg.SetCurrent(`nope`); !errors.As(err, &notfound) {
+		t.Errorf("expected LocalNotFound, got %v", err)
+	}
+}
+
+func TestCurrentKeg_cwd(t *testing.T) {
+	dir, _ := filepath.EvalSymlinks(mkKeg(t))
+	useConf(t, "map:\n  mine: "+dir+"\n")
+	useVars(t)
+	t.Setenv(`KEG_CURRENT`, ``)
+	node := filepath.Join(dir, `3`, `sub`)
+	if err := os.MkdirAll(node, 0755); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, node) // found by walking up
+	k, err := keg.CurrentKeg()
+	if err != nil || k.Local.Path != dir {
+		t.Errorf("got %v (%v) want %v", k, err, dir)
+	}
+}
+
+func TestCurrentKeg_none(t *testing.T) {
+	useConf(t, "map:\n  one: /no/such/one\n  two: /no/such/two\n")
+	useVars(t)
+	t.Setenv(`KEG_CURRENT`, ``)
+	chdir(t, t.TempDir())
+	_, err := keg.CurrentKeg()
+	var none keg.NoCurrentKeg
+	if !errors.As(err, &none) || len(none.Locals) != 2 ||
+		!strings.Contains(err.Error(), `one, two`) {
+		t.Errorf("expected NoCurrentKeg listing locals, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package keg
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Drift is a node whose title in the index (dex/nodes.tsv) is not the
+// same as the title of its README.md (see ScanDir). Nodes missing from
+// either side are reported as well.
+type Drift struct {
+	N         int
+	Index     string // title in dex/nodes.tsv
+	Readme    string // title from README.md
+	Unindexed bool   // not in dex/nodes.tsv
+	NoReadme  bool   // in dex/nodes.tsv but no README.md (or directory)
+}
+
+// TitleDrift returns every Drift between the titles in dex/nodes.tsv and
+// the README.md files of the keg sorted by node ID. Corrupt index lines
+// are skipped and a missing index means every node is Unindexed. See
+// FixTitles.
+func (k *Keg) TitleDrift() ([]Drift, error) {
+	drift, _, err := k.titleDrift()
+	return drift, err
+}
+
+// titleDrift returns TitleDrift along with the index it was based on.
+func (k *Keg) titleDrift() ([]Drift, Dex, error) {
+	skip := func(error) error { return nil }
+	dex, err := readDexFile(k.Local.Path, `nodes.tsv`, parseDexTSV, skip)
+	if err != nil {
+		return nil, nil, err
+	}
+	index := dex.Map()
+	var drift []Drift
+	seen := map[int]bool{}
+	dirs, _, _ := NodePaths(k.Local.Path)
+	for _, d := range dirs {
+		name := filepath.Base(d.Path)
+		id, err := strconv.Atoi(name)
+		if err != nil || strconv.Itoa(id) != name {
+			continue
+		}
+		seen[id] = true
+		e, indexed := index[id]
+		title, err := readNodeTitle(filepath.Join(d.Path, `README.md`))
+		switch {
+		case os.IsNotExist(err):
+			if indexed {
+				drift = append(drift, Drift{N: id, Index: e.T, NoReadme: true})
+			}
+		case err != nil:
+			return nil, nil, err
+		case !indexed:
+			drift = append(drift, Drift{N: id, Readme: title, Unindexed: true})
+		case title != e.T:
+			drift = append(drift, Drift{N: id, Index: e.T, Readme: title})
+		}
+	}
+	for _, e := range dex.ByID() {
+		if !seen[e.N] {
+			seen[e.N] = true
+			drift = append(drift, Drift{N: e.N, Index: e.T, NoReadme: true})
+		}
+	}
+	sort.SliceStable(drift, func(i, j int) bool { return drift[i].N < drift[j].N })
+	return drift, dex, nil
+}
+
+// FixTitles rewrites the index (see WriteDex) with the titles from the
+// README.md files wherever they have drifted (see TitleDrift) and adds
+// nodes not yet indexed. The update times of already indexed entries
+// are kept. Entries without a README.md cannot be fixed from one and
+// are left alone. Returns the Drift fixed (nothing written if none).
+func (k *Keg) FixTitles() ([]Drift, error) {
+	unlock, err := LockDex(k.Local.Path, DexLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	drift, dex, err := k.titleDrift()
+	if err != nil {
+		return nil, err
+	}
+	index := dex.Map()
+	var fixed []Drift
+	for _, d := range drift {
+		switch {
+		case d.NoReadme:
+			continue
+		case d.Unindexed:
+			e, err := scanNode(k.Path(d.N))
+			if err != nil {
+				return nil, err
+			}
+			dex = dex.Upsert(e)
+		default:
+			e := *index[d.N]
+			e.T = d.Readme
+			dex = dex.Upsert(e)
+		}
+		fixed = append(fixed, d)
+	}
+	if len(fixed) == 0 {
+		return nil, nil
+	}
+	return fixed, WriteDex(k.Local.Path, dex)
+}
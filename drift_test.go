@@ -0,0 +1,58 @@
+package keg_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestKeg_TitleDrift(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `1`, "# One\n", old)
+	mkNode(t, root, `2`, "# Two (renamed)\n", old)
+	mkNode(t, root, `3`, "# Three\n", old) // not indexed
+	mkNode(t, root, `4`, "", old)          // no README.md
+	tsv := "1\t2022-12-10 06:10:04Z\tOne\n" +
+		"2\t2022-12-10 06:10:04Z\tTwo\n" +
+		"4\t2022-12-10 06:10:04Z\tFour\n" +
+		"5\t2022-12-10 06:10:04Z\tFive\n" // no directory
+	if err := os.MkdirAll(filepath.Join(root, `dex`), 0700); err != nil {
+		t.Fatal(err)
+	}
+	tsvpath := filepath.Join(root, `dex`, `nodes.tsv`)
+	if err := os.WriteFile(tsvpath, []byte(tsv), 0600); err != nil {
+		t.Fatal(err)
+	}
+	k := &keg.Keg{Local: keg.Local{Path: root}}
+
+	drift, err := k.TitleDrift()
+	want := []keg.Drift{
+		{N: 2, Index: `Two`, Readme: `Two (renamed)`},
+		{N: 3, Readme: `Three`, Unindexed: true},
+		{N: 4, Index: `Four`, NoReadme: true},
+		{N: 5, Index: `Five`, NoReadme: true},
+	}
+	if err != nil || !reflect.DeepEqual(drift, want) {
+		t.Errorf("got %v (%v) want %v", drift, err, want)
+	}
+
+	fixed, err := k.FixTitles()
+	if err != nil || len(fixed) != 2 || fixed[0].N != 2 || fixed[1].N != 3 {
+		t.Errorf("unexpected fixes: %v (%v)", fixed, err)
+	}
+	dex, err := keg.ReadDexTSV(tsvpath)
+	if err != nil || len(dex) != 5 {
+		t.Fatalf("unexpected index: %v (%v)", dex, err)
+	}
+	if e := dex.Get(2); e == nil || e.T != `Two (renamed)` || !e.U.Equal(old) {
+		t.Errorf("title not fixed (or time changed): %v", e)
+	}
+	if drift, _ := k.TitleDrift(); len(drift) != 2 {
+		t.Errorf("expected only unfixable drift left, got %v", drift)
+	}
+}
@@ -0,0 +1,276 @@
+package keg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors is a collection of errors reported together as one (for
+// example, all of the problems found validating a Dex).
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap allows errors.Is and errors.As to find any of the errors
+// contained.
+func (e Errors) Unwrap() []error { return e }
+
+// -------------------------------- -- --------------------------------
+
+type BadID struct {
+	N int
+}
+
+func (e BadID) Error() string {
+	return fmt.Sprintf("invalid node id: %v", e.N)
+}
+
+// -------------------------------- -- --------------------------------
+
+type EmptyTitle struct {
+	N int
+}
+
+func (e EmptyTitle) Error() string {
+	return fmt.Sprintf("empty title for node %v", e.N)
+}
+
+// -------------------------------- -- --------------------------------
+
+type TitleHasControlChars struct {
+	N int
+	T string
+}
+
+func (e TitleHasControlChars) Error() string {
+	return fmt.Sprintf("title for node %v has control characters: %q", e.N, e.T)
+}
+
+// -------------------------------- -- --------------------------------
+
+type ZeroTime struct {
+	N int
+}
+
+func (e ZeroTime) Error() string {
+	return fmt.Sprintf("missing updated time for node %v", e.N)
+}
+
+// -------------------------------- -- --------------------------------
+
+type ErrNoMatch struct {
+	Key string
+}
+
+func (e ErrNoMatch) Error() string {
+	return fmt.Sprintf("no titles match %q", e.Key)
+}
+
+// -------------------------------- -- --------------------------------
+
+type ErrAmbiguous struct {
+	Key  string
+	Hits Dex
+}
+
+func (e ErrAmbiguous) Error() string {
+	ids := make([]string, 0, len(e.Hits))
+	for _, h := range e.Hits {
+		ids = append(ids, h.ID())
+	}
+	return fmt.Sprintf(
+		"%v titles match %q: %v", len(e.Hits), e.Key, strings.Join(ids, ", "))
+}
+
+// -------------------------------- -- --------------------------------
+
+type MissingReadme struct {
+	N    int
+	Path string
+}
+
+func (e MissingReadme) Error() string {
+	return fmt.Sprintf("node %v is missing README.md: %v", e.N, e.Path)
+}
+
+// -------------------------------- -- --------------------------------
+
+// ErrDexRebuilt is reported (see ReadDex) when no dex files were found
+// and the Dex had to be scanned from the node directories themselves.
+var ErrDexRebuilt = errors.New("dex rebuilt from node directories")
+
+// -------------------------------- -- --------------------------------
+
+type BadDexLine struct {
+	File string // nodes.tsv or latest.md
+	Line int
+	Err  error // optional
+}
+
+func (e BadDexLine) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("bad line in %v: %v", e.File, e.Line)
+	}
+	return fmt.Sprintf("bad line in %v: %v: %v", e.File, e.Line, e.Err)
+}
+
+func (e BadDexLine) Unwrap() error { return e.Err }
+
+// -------------------------------- -- --------------------------------
+
+type NotAKeg struct {
+	Path string
+}
+
+func (e NotAKeg) Error() string {
+	return fmt.Sprintf("not a keg directory: %v", e.Path)
+}
+
+// -------------------------------- -- --------------------------------
+
+type NodeNotFound struct {
+	N int
+}
+
+func (e NodeNotFound) Error() string {
+	return fmt.Sprintf("node not found: %v", e.N)
+}
+
+// -------------------------------- -- --------------------------------
+
+type ZeroNodeDelete struct{}
+
+func (e ZeroNodeDelete) Error() string {
+	return "the zero node (0) cannot be deleted"
+}
+
+// -------------------------------- -- --------------------------------
+
+type NodeExists struct {
+	N int
+}
+
+func (e NodeExists) Error() string {
+	return fmt.Sprintf("node already exists: %v", e.N)
+}
+
+// -------------------------------- -- --------------------------------
+
+type LocalNotFound struct {
+	Name string
+}
+
+func (e LocalNotFound) Error() string {
+	return fmt.Sprintf("no local keg named %q in conf map", e.Name)
+}
+
+// -------------------------------- -- --------------------------------
+
+type LocalExists struct {
+	Name string
+	Path string // already in the conf map
+}
+
+func (e LocalExists) Error() string {
+	return fmt.Sprintf("local keg %q already in conf map: %v", e.Name, e.Path)
+}
+
+// -------------------------------- -- --------------------------------
+
+type NoCurrentKeg struct {
+	Locals []Local
+}
+
+func (e NoCurrentKeg) Error() string {
+	if len(e.Locals) == 0 {
+		return "no current keg (set KEG_CURRENT, add a keg to the conf map, or change into a keg directory)"
+	}
+	return fmt.Sprintf(
+		"no current keg (set KEG_CURRENT or the current var to one of: %v)",
+		names(e.Locals))
+}
+
+// -------------------------------- -- --------------------------------
+
+type ExistingKeg struct {
+	Path string // of the keg info file found
+}
+
+func (e ExistingKeg) Error() string {
+	return fmt.Sprintf("already within a keg: %v", e.Path)
+}
+
+// -------------------------------- -- --------------------------------
+
+type ErrLocked struct {
+	Path string // of the lock file
+	PID  int    // of the holder (0 if unknown)
+}
+
+func (e ErrLocked) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("dex locked: %v", e.Path)
+	}
+	return fmt.Sprintf("dex locked by process %v: %v", e.PID, e.Path)
+}
+
+// -------------------------------- -- --------------------------------
+
+// ErrNoGit is returned by the Keg git methods (see GitCommit) when no
+// git executable can be found in the PATH.
+var ErrNoGit = errors.New("git not found in PATH (required to commit and push kegs)")
+
+// -------------------------------- -- --------------------------------
+
+type RemoteNotFound struct {
+	Name string
+}
+
+func (e RemoteNotFound) Error() string {
+	return fmt.Sprintf("no remote keg named %q in conf map", e.Name)
+}
+
+// -------------------------------- -- --------------------------------
+
+type UnknownKeg struct {
+	Name  string
+	Known []string // names of every local and remote keg
+}
+
+func (e UnknownKeg) Error() string {
+	if len(e.Known) == 0 {
+		return fmt.Sprintf("unknown keg %q (no kegs in conf map)", e.Name)
+	}
+	return fmt.Sprintf("unknown keg %q (known: %v)", e.Name, strings.Join(e.Known, ", "))
+}
+
+// -------------------------------- -- --------------------------------
+
+type TemplateNotFound struct {
+	Name      string
+	Available []string // names of every template (see Keg.Templates)
+}
+
+func (e TemplateNotFound) Error() string {
+	return fmt.Sprintf("no template named %q (available: %v)",
+		e.Name, strings.Join(e.Available, ", "))
+}
+
+// -------------------------------- -- --------------------------------
+
+type UnabsorbedLink struct {
+	From int // new node id (see Keg.Absorb)
+	Line int // line number within From README.md
+	To   int // node id linked to that was not absorbed
+}
+
+func (e UnabsorbedLink) Error() string {
+	return fmt.Sprintf("%v:%v: link to %v not absorbed (left as is)", e.From, e.Line, e.To)
+}
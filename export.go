@@ -0,0 +1,173 @@
+package keg
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ExportOpts contains the options for Keg.ExportMD.
+type ExportOpts struct {
+	Transclude bool // replace include list items with the node included
+}
+
+// includeItemExp matches a line that is nothing but a KEGML include
+// list item (see Dex.AsIncludes and AsNumberedIncludes) capturing the
+// node ID.
+var includeItemExp = regexp.MustCompile(
+	`^\s*(?:[*+-]|\d+\.)\s+\[.*\]\((?:\.\.)?/(\d+)/?\)\s*$`,
+)
+
+// ExportMD writes the README.md of every node in d (in Dex order) to
+// w as a single Markdown document suitable for printing or pandoc. The
+// document starts with a "# " heading of the keg title (or name) and
+// every node heading is demoted one level below it. Each node is
+// preceded by an HTML anchor (node-N) and links to nodes within the
+// document (see NodeLinkExp) are rewritten to point to them. With
+// opts.Transclude every include list item is replaced by the content of
+// the node it includes (demoted again). Nodes that cannot be found
+// become warning comments rather than errors. Fenced code blocks are
+// left untouched.
+func (k *Keg) ExportMD(w io.Writer, d Dex, opts ExportOpts) error {
+	title := k.Local.Name
+	if k.Info != nil && k.Info.Title != "" {
+		title = k.Info.Title
+	}
+	x := exporter{k: k, opts: opts, anchors: map[int]bool{}}
+	for _, e := range d {
+		x.anchors[e.N] = true
+	}
+	x.buf.WriteString("# " + title + "\n")
+	for _, e := range d {
+		x.blank()
+		fmt.Fprintf(&x.buf, "<a id=\"node-%v\"></a>\n\n", e.N)
+		found, err := x.node(e.N, 1, nil)
+		if err != nil {
+			return err
+		}
+		if !found {
+			fmt.Fprintf(&x.buf, "<!-- warning: node %v not found -->\n", e.N)
+		}
+	}
+	_, err := io.WriteString(w, x.buf.String())
+	return err
+}
+
+type exporter struct {
+	k       *Keg
+	opts    ExportOpts
+	anchors map[int]bool // nodes with an anchor in the document
+	buf     strings.Builder
+}
+
+// blank ensures the document so far ends with a blank line.
+func (x *exporter) blank() {
+	s := x.buf.String()
+	switch {
+	case strings.HasSuffix(s, "\n\n"):
+	case strings.HasSuffix(s, "\n"):
+		x.buf.WriteString("\n")
+	default:
+		x.buf.WriteString("\n\n")
+	}
+}
+
+// node writes the README.md of the node with id with every heading
+// demoted by levels returning false if there is no such README.md.
+// Nodes already being written (parents) cannot be transcluded again.
+func (x *exporter) node(id, levels int, parents []int) (bool, error) {
+	byt, err := os.ReadFile(filepath.Join(x.k.Path(id), `README.md`))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	parents = append(parents, id)
+	text := strings.TrimRight(strings.TrimPrefix(string(byt), "\uFEFF"), "\r\n")
+	var fence string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) &&
+				strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			x.buf.WriteString(line + "\n")
+			continue
+		}
+		if f := fenceOpen(trimmed); f != "" {
+			fence = f
+			x.buf.WriteString(line + "\n")
+			continue
+		}
+		if m := includeItemExp.FindStringSubmatch(line); m != nil && x.opts.Transclude {
+			if err := x.transclude(m[1], levels, parents); err != nil {
+				return true, err
+			}
+			continue
+		}
+		x.buf.WriteString(x.links(demote(line, levels)) + "\n")
+	}
+	return true, nil
+}
+
+// transclude writes the node with the id string in place of an include
+// list item of a node written with headings demoted by levels (see
+// node).
+func (x *exporter) transclude(id string, levels int, parents []int) error {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return err
+	}
+	for _, p := range parents {
+		if p == n {
+			x.blank()
+			fmt.Fprintf(&x.buf, "<!-- warning: node %v includes itself -->\n\n", n)
+			return nil
+		}
+	}
+	x.blank()
+	found, err := x.node(n, levels+1, parents)
+	if err != nil {
+		return err
+	}
+	if !found {
+		fmt.Fprintf(&x.buf, "<!-- warning: included node %v not found -->\n", n)
+	}
+	x.blank()
+	return nil
+}
+
+// links rewrites every link to a node with an anchor in the document
+// to point to the anchor instead (keeping any link title).
+func (x *exporter) links(line string) string {
+	return NodeLinkExp.ReplaceAllStringFunc(line, func(link string) string {
+		m := NodeLinkExp.FindStringSubmatch(link)
+		id, err := strconv.Atoi(m[1])
+		if err != nil || !x.anchors[id] {
+			return link
+		}
+		rest := link[strings.Index(link, m[1])+len(m[1]):] // keeps any title
+		return `](#node-` + m[1] + strings.TrimPrefix(rest, `/`)
+	})
+}
+
+// demote returns line with one more # (up to six) for each of levels
+// if it is an ATX heading and unchanged if not.
+func demote(line string, levels int) string {
+	n := len(line) - len(strings.TrimLeft(line, `#`))
+	if n == 0 || n > 6 || (len(line) > n && line[n] != ' ' && line[n] != '\t') {
+		return line
+	}
+	to := n + levels
+	if to > 6 {
+		to = 6
+	}
+	return strings.Repeat(`#`, to) + line[n:]
+}
@@ -0,0 +1,50 @@
+package keg_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+var updateGolden = flag.Bool(`update`, false, `update golden files in testdata`)
+
+func TestKeg_ExportMD(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	mkNode(t, root, `1`, "# One\n\n"+
+		"See [two](/2) and [nine](/9 \"Nine\").\n\n"+
+		"## Details\n\n"+
+		"```\n# not a heading [two](/2)\n```\n\n#tag\n", now)
+	mkNode(t, root, `2`, "# Two\n\nIncludes:\n\n"+
+		"* [Three](/3)\n"+
+		"* [Missing](/8)\n", now)
+	mkNode(t, root, `3`, "# Three\n\nBack to [two](../2/).\n\n"+
+		"1. [Two](/2)\n", now)
+	k := &keg.Keg{
+		Local: keg.Local{Name: `test`, Path: root},
+		Info:  &keg.KegInfo{Title: `Export Test`},
+	}
+	dex := keg.Dex{{N: 1}, {N: 2}, {N: 4}}
+
+	var buf bytes.Buffer
+	if err := k.ExportMD(&buf, dex, keg.ExportOpts{Transclude: true}); err != nil {
+		t.Fatal(err)
+	}
+	golden := `testdata/export.md`
+	if *updateGolden {
+		if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,81 @@
+package keg
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFeedLimit is the number of entries included by Feed when
+// FeedOpts.Limit is not greater than zero.
+var DefaultFeedLimit = 20
+
+// FeedOpts contains the options for Feed.
+type FeedOpts struct {
+	Title   string // title of the feed (usually the keg title)
+	BaseURL string // URL of the published keg (node links are BaseURL/N)
+	Author  string // name of the feed author (required by Atom)
+	Limit   int    // most recent entries to include (see DefaultFeedLimit)
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Link    atomLink `xml:"link"`
+	Updated string   `xml:"updated"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// Feed renders the most recently updated entries of the Dex (see Last)
+// as an Atom feed with one entry per DexEntry linking to the node under
+// the BaseURL. The feed is updated as of its newest entry. Titles are
+// escaped as needed for XML.
+func (d Dex) Feed(opts FeedOpts) ([]byte, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultFeedLimit
+	}
+	base := strings.TrimSuffix(opts.BaseURL, `/`)
+	feed := atomFeed{
+		Title:  opts.Title,
+		ID:     base + `/`,
+		Link:   atomLink{base + `/`},
+		Author: atomAuthor{opts.Author},
+	}
+	for _, e := range d.Last(opts.Limit) {
+		link := base + `/` + strconv.Itoa(e.N)
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   e.T,
+			ID:      link,
+			Link:    atomLink{link},
+			Updated: e.U.UTC().Format(time.RFC3339),
+		})
+	}
+	if len(feed.Entries) > 0 {
+		feed.Updated = feed.Entries[0].Updated
+	} else {
+		feed.Updated = time.Time{}.Format(time.RFC3339)
+	}
+	byt, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(byt, '\n')...), nil
+}
@@ -0,0 +1,34 @@
+package keg_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestDex_Feed(t *testing.T) {
+	date := time.Date(2023, 1, 14, 9, 12, 5, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date.Add(-time.Hour), N: 1, T: `Older`},
+		{U: date, N: 2, T: `Tags <b> & "quotes"`},
+		{U: date.Add(-2 * time.Hour), N: 3, T: `Oldest (left out)`},
+	}
+	got, err := dex.Feed(keg.FeedOpts{
+		Title:   `A Sample Keg`,
+		BaseURL: `https://example.com/keg/`,
+		Author:  `Some One`,
+		Limit:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(`testdata/feed.atom`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
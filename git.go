@@ -0,0 +1,121 @@
+package keg
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// git runs git with args from within the keg directory and returns its
+// standard output. The standard error (trimmed) is used as the error
+// if git fails.
+func (k *Keg) git(args ...string) (string, error) {
+	if _, err := exec.LookPath(`git`); err != nil {
+		return "", ErrNoGit
+	}
+	cmd := exec.Command(`git`, append([]string{`-C`, k.Local.Path}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.String(), fmt.Errorf("git %v: %v", args[0], msg)
+		}
+		return stdout.String(), fmt.Errorf("git %v: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// GitDirty returns true if any file within the keg directory has
+// changes not yet committed to git (including new files) so that
+// commands can warn before doing anything destructive.
+func (k *Keg) GitDirty() (bool, error) {
+	out, err := k.git(`status`, `--porcelain`, `--`, `.`)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(out) != "", nil
+}
+
+// GitCommit stages every change within the keg directory (and nothing
+// outside of it even if the git repo contains more than the keg) and
+// commits them with msg. If msg is empty one is generated from the
+// Dex.Diff of the dex/nodes.tsv last committed and the current one (see
+// GitCommitMessage). Does nothing if there is nothing to commit.
+func (k *Keg) GitCommit(msg string) error {
+	if _, err := k.git(`add`, `-A`, `--`, `.`); err != nil {
+		return err
+	}
+	if _, err := k.git(`diff`, `--cached`, `--quiet`, `--`, `.`); err == nil {
+		return nil // nothing staged
+	}
+	if msg == "" {
+		var err error
+		if msg, err = k.GitCommitMessage(); err != nil {
+			return err
+		}
+	}
+	_, err := k.git(`commit`, `-m`, msg, `--`, `.`)
+	return err
+}
+
+// GitCommitMessage returns the default message for GitCommit
+// summarizing the changes to dex/nodes.tsv since last committed (for
+// example, "add 2 nodes, update 3") or "update keg" if the index has
+// not changed. A keg never committed has every node added.
+func (k *Keg) GitCommitMessage() (string, error) {
+	skip := func(error) error { return nil }
+	var old Dex
+	if out, err := k.git(`show`, `HEAD:./dex/nodes.tsv`); err == nil {
+		old, _ = parseDexTSV(strings.NewReader(out), skip)
+	} else if err == ErrNoGit {
+		return "", err
+	}
+	cur, err := readDexFile(k.Local.Path, `nodes.tsv`, parseDexTSV, skip)
+	if err != nil {
+		return "", err
+	}
+	added, removed, changed := old.Diff(cur)
+	var parts []string
+	for _, p := range []struct {
+		verb string
+		n    int
+	}{{`add`, len(added)}, {`update`, len(changed)}, {`remove`, len(removed)}} {
+		switch {
+		case p.n == 1 && len(parts) == 0:
+			parts = append(parts, p.verb+` 1 node`)
+		case p.n > 1 && len(parts) == 0:
+			parts = append(parts, fmt.Sprintf("%v %v nodes", p.verb, p.n))
+		case p.n > 0:
+			parts = append(parts, fmt.Sprintf("%v %v", p.verb, p.n))
+		}
+	}
+	if len(parts) == 0 {
+		return `update keg`, nil
+	}
+	return strings.Join(parts, `, `), nil
+}
+
+// GitPush pushes the current branch of the git repo containing the keg
+// to its upstream.
+func (k *Keg) GitPush() error {
+	_, err := k.git(`push`)
+	return err
+}
+
+// GitPull pulls the changes to the current branch of the git repo
+// containing the keg from its upstream rebasing any local commits on
+// top of them. Nothing is resolved automatically: if the rebase stops
+// on a conflict the error from git is returned and the repo is left as
+// git left it.
+func (k *Keg) GitPull() error {
+	_, err := k.git(`pull`, `--rebase`)
+	return err
+}
+
+// GitRemote returns true if the keg is within a git repo with at least
+// one remote.
+func (k *Keg) GitRemote() bool {
+	out, err := k.git(`remote`)
+	return err == nil && strings.TrimSpace(out) != ""
+}
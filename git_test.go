@@ -0,0 +1,138 @@
+package keg_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+// gitRepo returns a new git repo in a temporary directory with a keg in
+// its kegs/mine subdirectory containing node 1 (committed).
+func gitRepo(t *testing.T) (repo string, k *keg.Keg) {
+	t.Helper()
+	if _, err := exec.LookPath(`git`); err != nil {
+		t.Skip(`git not installed`)
+	}
+	repo = t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command(`git`, append([]string{`-C`, repo}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run(`init`, `-q`)
+	run(`config`, `user.name`, `Test`)
+	run(`config`, `user.email`, `test@example.com`)
+	root := filepath.Join(repo, `kegs`, `mine`)
+	k, err := keg.InitKegWith(root, `Mine`, keg.InitOpts{Creator: `Test`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkNode(t, root, `1`, "# One\n", time.Now().Add(-time.Hour))
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := k.GitCommitMessage(); err != nil || msg != `add 2 nodes` {
+		t.Errorf("unexpected first message: %q (%v)", msg, err)
+	}
+	if err := k.GitCommit(``); err != nil {
+		t.Fatal(err)
+	}
+	return repo, k
+}
+
+func TestKeg_GitCommit(t *testing.T) {
+	repo, k := gitRepo(t)
+	root := k.Local.Path
+	if dirty, err := k.GitDirty(); err != nil || dirty {
+		t.Errorf("expected clean after commit: %v (%v)", dirty, err)
+	}
+
+	// files outside of the keg are never committed (or dirty)
+	outside := filepath.Join(repo, `other.txt`)
+	if err := os.WriteFile(outside, []byte("x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if dirty, _ := k.GitDirty(); dirty {
+		t.Error("dirty from file outside of keg")
+	}
+
+	mkNode(t, root, `1`, "# One (changed)\n", time.Now())
+	mkNode(t, root, `2`, "# Two\n", time.Now())
+	mkNode(t, root, `3`, "# Three\n", time.Now())
+	if err := os.RemoveAll(filepath.Join(root, `0`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	if dirty, err := k.GitDirty(); err != nil || !dirty {
+		t.Errorf("expected dirty: %v (%v)", dirty, err)
+	}
+	if err := k.GitCommit(``); err != nil {
+		t.Fatal(err)
+	}
+	out, _ := exec.Command(`git`, `-C`, repo, `log`, `-1`, `--format=%s`).Output()
+	if msg := strings.TrimSpace(string(out)); msg != `add 2 nodes, update 1, remove 1` {
+		t.Errorf("unexpected commit message: %q", msg)
+	}
+	status, _ := exec.Command(`git`, `-C`, repo, `status`, `--porcelain`).Output()
+	if string(status) != "?? other.txt\n" {
+		t.Errorf("unexpected status after commit:\n%s", status)
+	}
+
+	// nothing to commit is not an error
+	if err := k.GitCommit(`again`); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestKeg_GitCommit_nogit(t *testing.T) {
+	k := &keg.Keg{Local: keg.Local{Path: t.TempDir()}}
+	t.Setenv(`PATH`, ``)
+	if err := k.GitCommit(``); !errors.Is(err, keg.ErrNoGit) {
+		t.Errorf("expected ErrNoGit, got %v", err)
+	}
+	if _, err := k.GitDirty(); !errors.Is(err, keg.ErrNoGit) {
+		t.Errorf("expected ErrNoGit, got %v", err)
+	}
+}
+
+func TestKeg_GitPull(t *testing.T) {
+	repo, k := gitRepo(t)
+	if k.GitRemote() {
+		t.Error("expected no remote")
+	}
+	clone := filepath.Join(t.TempDir(), `clone`)
+	if out, err := exec.Command(`git`, `clone`, `-q`, repo, clone).CombinedOutput(); err != nil {
+		t.Fatalf("git clone: %v\n%s", err, out)
+	}
+	mine, err := keg.Open(filepath.Join(clone, `kegs`, `mine`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mine.GitRemote() {
+		t.Error("expected remote")
+	}
+
+	mkNode(t, k.Local.Path, `2`, "# Two\n", time.Now())
+	if _, err := keg.UpdateDex(k.Local.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.GitCommit(``); err != nil {
+		t.Fatal(err)
+	}
+	if err := mine.GitPull(); err != nil {
+		t.Fatal(err)
+	}
+	if byt, err := os.ReadFile(filepath.Join(mine.Path(2), `README.md`)); err != nil || string(byt) != "# Two\n" {
+		t.Errorf("node not pulled: %q (%v)", byt, err)
+	}
+}
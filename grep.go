@@ -0,0 +1,197 @@
+package keg
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/rwxrob/term"
+)
+
+// GrepHit is a single line matched by Keg.Grep.
+type GrepHit struct {
+	N    int    // node id
+	T    string // node title (from the index)
+	File string // relative to node directory (usually README.md)
+	Line int    // starting from 1
+	Text string // the entire matching line
+}
+
+// GrepHits are the hits returned by Keg.Grep in node, file, and line
+// order.
+type GrepHits []GrepHit
+
+// GrepOpts contains the options for GrepWith.
+type GrepOpts struct {
+	IgnoreCase bool // case-insensitive match
+	AllFiles   bool // every (non-binary) file, not just README.md
+	Workers    int  // max nodes read at once (default runtime.GOMAXPROCS)
+}
+
+// Grep returns GrepWith using the default options (case-sensitive,
+// README.md files only).
+func (k *Keg) Grep(pattern string) (GrepHits, error) {
+	return k.GrepWith(pattern, GrepOpts{})
+}
+
+// GrepWith returns every line of every node README.md (or every file in
+// the node directory when opts.AllFiles) matching the regular
+// expression pattern. Files are read concurrently (see ScanDirWith).
+// Binary files (those with a NUL byte at the start) are skipped. Titles
+// are taken from the index (see Dex) and fall back to the README.md
+// title for nodes not yet indexed.
+func (k *Keg) GrepWith(pattern string, opts GrepOpts) (GrepHits, error) {
+	if opts.IgnoreCase {
+		pattern = `(?i)` + pattern
+	}
+	exp, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	titles := map[int]string{}
+	if dex, _ := k.Dex(); dex != nil {
+		for _, e := range dex {
+			titles[e.N] = e.T
+		}
+	}
+
+	dirs, _, _ := NodePaths(k.Local.Path)
+	hits := make([]GrepHits, len(dirs))
+	errs := make([]error, len(dirs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(dirs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				hits[i], errs[i] = grepNode(dirs[i].Path, exp, opts.AllFiles)
+			}
+		}()
+	}
+	for i := range dirs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var all GrepHits
+	for i, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, hits[i]...)
+	}
+	for i, h := range all {
+		title, has := titles[h.N]
+		if !has {
+			title, _ = readNodeTitle(filepath.Join(k.Path(h.N), `README.md`))
+		}
+		all[i].T = title
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].N != all[j].N {
+			return all[i].N < all[j].N
+		}
+		return all[i].File < all[j].File
+	})
+	return all, nil
+}
+
+// grepNode returns the hits within a single node directory. Not being
+// a node directory at all is not an error.
+func grepNode(dir string, exp *regexp.Regexp, allfiles bool) (GrepHits, error) {
+	name := filepath.Base(dir)
+	id, err := strconv.Atoi(name)
+	if err != nil || strconv.Itoa(id) != name {
+		return nil, nil
+	}
+	files := []string{`README.md`}
+	if allfiles {
+		node := Node{ID: id, Dir: dir}
+		others, err := node.Files()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, others...)
+	}
+	var hits GrepHits
+	for _, file := range files {
+		byt, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if isBinary(byt) {
+			continue
+		}
+		s := bufio.NewScanner(bytes.NewReader(byt))
+		s.Buffer(make([]byte, 0, 64*1024), len(byt)+1)
+		for line := 1; s.Scan(); line++ {
+			text := strings.TrimSuffix(s.Text(), "\r")
+			if exp.MatchString(text) {
+				hits = append(hits, GrepHit{N: id, File: file, Line: line, Text: text})
+			}
+		}
+	}
+	return hits, nil
+}
+
+// isBinary returns true if there is a NUL byte within the first 8000
+// bytes (the same test used by git and grep) or the text is not valid
+// UTF-8.
+func isBinary(byt []byte) bool {
+	head := byt
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	return bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(head)
+}
+
+// Pretty returns the hits one per line colored like Dex.Pretty (node
+// ID, title, and line number followed by the matching text). See
+// PrettyPlain.
+func (h GrepHits) Pretty() string { return h.pretty(false) }
+
+// PrettyPlain returns Pretty without any color.
+func (h GrepHits) PrettyPlain() string { return h.pretty(true) }
+
+func (h GrepHits) pretty(plain bool) string {
+	green, black, white, reset := term.Green, term.Black, term.White, term.Reset
+	if plain || NoColor() {
+		green, black, white, reset = "", "", "", ""
+	}
+	var width int
+	for _, hit := range h {
+		if n := len(strconv.Itoa(hit.N)); n > width {
+			width = n
+		}
+	}
+	lines := make([]string, 0, len(h))
+	for _, hit := range h {
+		id := strconv.Itoa(hit.N)
+		loc := strconv.Itoa(hit.Line)
+		if hit.File != `README.md` {
+			loc = hit.File + `:` + loc
+		}
+		lines = append(lines,
+			green+id+strings.Repeat(" ", width-len(id))+" "+
+				white+hit.T+black+":"+loc+": "+reset+hit.Text,
+		)
+	}
+	return joinLines(lines)
+}
@@ -0,0 +1,62 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleKeg_Grep() {
+	k, _ := keg.Open(`testdata/samplekeg`)
+	hits, err := k.Grep(`title for 1\d`)
+	fmt.Println(err)
+	fmt.Print(hits.PrettyPlain())
+	// Output:
+	// <nil>
+	// 10 Some title for 10:1: # Some title for 10
+	// 11 Some title for 11:1: # Some title for 11
+	// 12 Some title for 12:1: # Some title for 12
+}
+
+func TestKeg_GrepWith(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Zero\n", old)
+	dir := mkNode(t, root, `1`, "# One\n\nSome Foo here\r\nand foo there\n", old)
+	os.WriteFile(filepath.Join(dir, `notes.txt`), []byte("more foo\n"), 0600)
+	os.WriteFile(filepath.Join(dir, `image.png`), []byte("foo\x00\x01"), 0600)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	k, err := keg.Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits, err := k.Grep(`foo`)
+	if err != nil || len(hits) != 1 || hits[0].Line != 4 || hits[0].T != `One` {
+		t.Errorf("case-sensitive: %v %v", hits, err)
+	}
+
+	hits, err = k.GrepWith(`foo`, keg.GrepOpts{IgnoreCase: true, AllFiles: true})
+	want := keg.GrepHits{
+		{N: 1, T: `One`, File: `README.md`, Line: 3, Text: `Some Foo here`},
+		{N: 1, T: `One`, File: `README.md`, Line: 4, Text: `and foo there`},
+		{N: 1, T: `One`, File: `notes.txt`, Line: 1, Text: `more foo`},
+	}
+	if err != nil || fmt.Sprint(hits) != fmt.Sprint(want) {
+		t.Errorf("all files: got %v (%v) want %v", hits, err, want)
+	}
+	if got := hits.PrettyPlain(); got != "1 One:3: Some Foo here\n"+
+		"1 One:4: and foo there\n1 One:notes.txt:1: more foo\n" {
+		t.Errorf("unexpected pretty:\n%v", got)
+	}
+
+	if _, err := k.Grep(`(`); err == nil {
+		t.Errorf("expected bad pattern error")
+	}
+}
@@ -0,0 +1,53 @@
+package keg
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// HTMLOpts contains the options for HTMLWith.
+type HTMLOpts struct {
+	BaseURL  string // prefix for node links (default is root relative /N/)
+	Document bool   // full HTML document instead of fragment
+	Title    string // title of the full HTML document (ignored for fragment)
+}
+
+// HTML returns HTMLWith using the default (zero value) options
+// producing an HTML fragment with root-relative links.
+func (d Dex) HTML() string { return d.HTMLWith(HTMLOpts{}) }
+
+// HTMLWith renders the Dex as a semantic HTML unordered list (with
+// class dex) with one item per entry containing the update time (also
+// in ISO form within the datetime attribute), the node ID, and the title
+// linked to the node (BaseURL/N/). All text is escaped. When Document is
+// true a minimal full HTML document with the Title is produced instead
+// of just the fragment.
+func (d Dex) HTMLWith(opts HTMLOpts) string {
+	var buf strings.Builder
+	base := strings.TrimSuffix(opts.BaseURL, `/`)
+	title := html.EscapeString(opts.Title)
+	if opts.Document {
+		buf.WriteString("<!DOCTYPE html>\n<html>\n<head>\n")
+		buf.WriteString("<meta charset=\"utf-8\">\n")
+		buf.WriteString("<title>" + title + "</title>\n")
+		buf.WriteString("</head>\n<body>\n")
+		buf.WriteString("<h1>" + title + "</h1>\n")
+	}
+	buf.WriteString("<ul class=\"dex\">\n")
+	for _, e := range d {
+		u := e.U.UTC()
+		fmt.Fprintf(&buf,
+			"<li><time datetime=\"%v\">%v</time> <span class=\"id\">%v</span> <a href=\"%v\">%v</a></li>\n",
+			u.Format(time.RFC3339), u.Format(IsoDateFmt), e.N,
+			html.EscapeString(fmt.Sprintf(`%v/%v/`, base, e.N)),
+			html.EscapeString(e.T),
+		)
+	}
+	buf.WriteString("</ul>\n")
+	if opts.Document {
+		buf.WriteString("</body>\n</html>\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,46 @@
+package keg_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleDex_HTML() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 2, T: `Some <title> & "more"`},
+		{U: date, N: 10, T: `Another`},
+	}
+	fmt.Print(dex.HTML())
+	// Output:
+	// <ul class="dex">
+	// <li><time datetime="2022-12-10T06:10:04Z">2022-12-10 06:10:04Z</time> <span class="id">2</span> <a href="/2/">Some &lt;title&gt; &amp; &#34;more&#34;</a></li>
+	// <li><time datetime="2022-12-10T06:10:04Z">2022-12-10 06:10:04Z</time> <span class="id">10</span> <a href="/10/">Another</a></li>
+	// </ul>
+}
+
+func ExampleDex_HTMLWith() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{{U: date, N: 2, T: `Some title`}}
+	fmt.Print(dex.HTMLWith(keg.HTMLOpts{
+		BaseURL:  `https://example.com/keg/`,
+		Document: true,
+		Title:    `Sample & Keg`,
+	}))
+	// Output:
+	// <!DOCTYPE html>
+	// <html>
+	// <head>
+	// <meta charset="utf-8">
+	// <title>Sample &amp; Keg</title>
+	// </head>
+	// <body>
+	// <h1>Sample &amp; Keg</h1>
+	// <ul class="dex">
+	// <li><time datetime="2022-12-10T06:10:04Z">2022-12-10 06:10:04Z</time> <span class="id">2</span> <a href="https://example.com/keg/2/">Some title</a></li>
+	// </ul>
+	// </body>
+	// </html>
+}
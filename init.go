@@ -0,0 +1,113 @@
+package keg
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rwxrob/fs"
+)
+
+// KegVersion is the version of the KEG specification written to the
+// keg info file of new kegs (see InitKeg).
+const KegVersion = `2023-01`
+
+// InitOpts contains the options for InitKegWith.
+type InitOpts struct {
+	Force   bool   // initialize even if within an existing keg
+	Creator string // default from git config user.name or $USER
+}
+
+// InitKeg returns InitKegWith using the default options.
+func InitKeg(path, title string) (*Keg, error) {
+	return InitKegWith(path, title, InitOpts{})
+}
+
+// InitKegWith creates a new keg at path (creating the directory if
+// needed) and returns it opened (see Open). The following are written:
+//
+//   - keg info file (updated, kegv, title, creator, and indexes)
+//   - dex/nodes.tsv and dex/latest.md (empty)
+//   - 0/README.md (see DefaultZeroNode)
+//
+// Returns ExistingKeg if path or any directory above it already has
+// a keg info file unless opts.Force is set, in which case the keg info
+// file is replaced but any existing dex files and zero node are left
+// as they are.
+func InitKegWith(path, title string, opts InitOpts) (*Keg, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Force {
+		for d := dir; ; d = filepath.Dir(d) {
+			if info := filepath.Join(d, `keg`); fs.Exists(info) {
+				return nil, ExistingKeg{info}
+			}
+			if d == filepath.Dir(d) {
+				break
+			}
+		}
+	}
+	creator := opts.Creator
+	if creator == "" {
+		creator = defaultCreator()
+	}
+	info := &KegInfo{
+		Updated: time.Now().UTC().Truncate(time.Second),
+		KegV:    KegVersion,
+		Title:   title,
+		Creator: creator,
+		Indexes: []KegIndex{
+			{File: `dex/latest.md`, Summary: `latest changes`},
+			{File: `dex/nodes.tsv`, Summary: `all nodes by id`},
+		},
+	}
+	for _, d := range []string{dir, filepath.Join(dir, `dex`), filepath.Join(dir, `0`)} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, err
+		}
+	}
+	if err := info.Save(filepath.Join(dir, `keg`)); err != nil {
+		return nil, err
+	}
+	for file, content := range map[string]string{
+		`dex/nodes.tsv`: ``,
+		`dex/latest.md`: ``,
+		`0/README.md`:   DefaultZeroNode,
+	} {
+		if err := writeNew(filepath.Join(dir, file), content); err != nil {
+			return nil, err
+		}
+	}
+	return Open(dir)
+}
+
+// writeNew writes content to a new file at path leaving any existing
+// file untouched.
+func writeNew(path, content string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// defaultCreator returns the user.name from git config or the USER
+// environment variable if git is not available or not configured.
+func defaultCreator() string {
+	out, err := exec.Command(`git`, `config`, `--get`, `user.name`).Output()
+	if name := strings.TrimSpace(string(out)); err == nil && name != "" {
+		return name
+	}
+	return os.Getenv(`USER`)
+}
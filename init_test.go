@@ -0,0 +1,95 @@
+package keg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestInitKeg(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), `mykeg`)
+	before := time.Now().UTC().Truncate(time.Second)
+	k, err := keg.InitKegWith(dir, `My Keg`, keg.InitOpts{Creator: `Jane Doe`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Info == nil || k.Info.Title != `My Keg` || k.Info.Updated.Before(before) {
+		t.Errorf("unexpected info: %v", k.Info)
+	}
+
+	// every file written must match testdata/init (but for updated)
+	golden := `testdata/init`
+	var count int
+	err = filepath.Walk(golden, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		count++
+		rel, _ := filepath.Rel(golden, path)
+		want, _ := os.ReadFile(path)
+		got, err := os.ReadFile(filepath.Join(dir, rel))
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		if rel == `keg` {
+			lines := strings.SplitN(string(got), "\n", 2)
+			if !strings.HasPrefix(lines[0], `updated: `) || len(lines) < 2 {
+				t.Errorf("updated not first line:\n%s", got)
+				return nil
+			}
+			got = []byte(strings.SplitN(string(want), "\n", 2)[0] + "\n" + lines[1])
+		}
+		if string(got) != string(want) {
+			t.Errorf("%v: got:\n%s\nwant:\n%s", rel, got, want)
+		}
+		return nil
+	})
+	if err != nil || count != 4 {
+		t.Errorf("golden files: %v (%v)", count, err)
+	}
+}
+
+func TestInitKeg_existing(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := keg.InitKeg(dir, `First`); err != nil {
+		t.Fatal(err)
+	}
+	readme := filepath.Join(dir, `0`, `README.md`)
+	if err := os.WriteFile(readme, []byte("# Mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var existing keg.ExistingKeg
+	for _, path := range []string{dir, filepath.Join(dir, `0`, `sub`)} {
+		_, err := keg.InitKeg(path, `Second`)
+		if !errors.As(err, &existing) || existing.Path != filepath.Join(dir, `keg`) {
+			t.Errorf("%v: expected ExistingKeg, got %v", path, err)
+		}
+	}
+
+	k, err := keg.InitKegWith(dir, `Second`, keg.InitOpts{Force: true})
+	if err != nil || k.Info.Title != `Second` {
+		t.Fatalf("force failed: %v (%v)", k, err)
+	}
+	if byt, _ := os.ReadFile(readme); string(byt) != "# Mine\n" {
+		t.Errorf("zero node overwritten: %q", byt)
+	}
+}
+
+func TestInitKeg_creator(t *testing.T) {
+	chdir(t, t.TempDir())
+	t.Setenv(`HOME`, t.TempDir()) // no global git config
+	t.Setenv(`XDG_CONFIG_HOME`, t.TempDir())
+	t.Setenv(`GIT_CONFIG_NOSYSTEM`, `1`)
+	t.Setenv(`USER`, `someone`)
+	k, err := keg.InitKeg(filepath.Join(t.TempDir(), `k`), ``)
+	if err != nil || k.Info.Creator != `someone` {
+		t.Errorf("got %v (%v) want creator someone", k.Info, err)
+	}
+}
@@ -2,15 +2,21 @@ package keg
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	iofs "io/fs"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	Z "github.com/rwxrob/bonzai/z"
@@ -19,6 +25,7 @@ import (
 	"github.com/rwxrob/fs/file"
 	"github.com/rwxrob/keg/kegml"
 	"github.com/rwxrob/to"
+	"gopkg.in/yaml.v3"
 )
 
 // NodePaths returns a list of node directory paths contained in the
@@ -34,8 +41,81 @@ import (
 // ignored.
 var NodePaths = _fs.IntDirs
 
+// Keg is a keg directory stored locally. Path (of the embedded Local)
+// is always the full path to the keg directory itself, use the Path
+// method for node directory paths. Create with Open.
+type Keg struct {
+	Local
+	Info *KegInfo // nil if no keg info file
+}
+
+// Open returns the Keg for the directory at path (named after the
+// directory) if it looks like a keg (contains a keg info file or at
+// least a dex directory). Otherwise returns NotAKeg. Info is loaded
+// from the keg info file when there is one (see LoadKegInfo).
+func Open(path string) (*Keg, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, NotAKeg{path}
+	}
+	if fs.NotExists(filepath.Join(dir, `keg`)) &&
+		!isDir(filepath.Join(dir, `dex`)) {
+		return nil, NotAKeg{path}
+	}
+	k := &Keg{Local: Local{Name: filepath.Base(dir), Path: dir}}
+	if infofile := filepath.Join(dir, `keg`); fs.Exists(infofile) {
+		if k.Info, err = LoadKegInfo(infofile); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// isDir is fs.IsDir without logging when path does not exist.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// Dex returns ReadDex for the keg (see its error semantics).
+func (k *Keg) Dex() (Dex, error) { return ReadDex(k.Local.Path) }
+
+// Path returns the full path to the node directory for id (which might
+// not exist).
+func (k *Keg) Path(id int) string {
+	return filepath.Join(k.Local.Path, strconv.Itoa(id))
+}
+
+// Node returns the Node for id read from its directory or NodeNotFound
+// if there is no such node directory.
+func (k *Keg) Node(id int) (*Node, error) { return readNode(id, k.Path(id)) }
+
+// ZeroNode returns the zero node (0) of the keg, which documents the
+// keg itself and is the target of links to planned content. Returns
+// NodeNotFound if the keg has none.
+func (k *Keg) ZeroNode() (*Node, error) { return k.Node(0) }
+
+// LastChanged returns the update time of the most recently changed
+// node according to the index (see Dex).
+func (k *Keg) LastChanged() (time.Time, error) {
+	dex, err := k.Dex()
+	if dex == nil {
+		return time.Time{}, err
+	}
+	if len(dex) == 0 {
+		return time.Time{}, fmt.Errorf("no nodes in keg: %v", k.Local.Path)
+	}
+	return dex.ByLatest()[0].U, nil
+}
+
+// LatestDexEntryExp matches a single DexEntry.MD line capturing the
+// time, title, and node ID. Any of the times accepted by ParseTime are
+// matched, not just IsoDateFmt.
 var LatestDexEntryExp = regexp.MustCompile(
-	`^\* (\d\d\d\d-\d\d-\d\d \d\d:\d\d:\d\dZ) \[(.*)\]\(/(\d+)\)$`,
+	`^\* (\d\d\d\d-\d\d-\d\d(?:[ T]\d\d:\d\d(?::\d\d)?(?:Z|[+-]\d\d:\d\d))?) \[(.*)\]\(/(\d+)\)$`,
 )
 
 // ParseDex parses any input valid for to.String into a Dex pointer.
@@ -48,27 +128,200 @@ func ParseDex(in any) (*Dex, error) {
 		if len(f) != 4 {
 			return nil, fmt.Errorf("bad line in latest.md: %v", line)
 		}
-		if t, err := time.Parse(IsoDateFmt, string(f[1])); err != nil {
+		if t, err := ParseTime(f[1]); err != nil {
 			return nil, err
 		} else {
 			if i, err := strconv.Atoi(f[3]); err != nil {
 				return nil, err
 			} else {
-				dex = append(dex, DexEntry{U: t, T: f[2], N: i})
+				dex = append(dex, DexEntry{U: t, T: UnescapeLinkText(f[2]), N: i})
 			}
 		}
 	}
 	return &dex, nil
 }
 
-// ReadDex reads an existing dex/latest.md dex and returns it.
-func ReadDex(kegdir string) (*Dex, error) {
-	f := filepath.Join(kegdir, `dex`, `latest.md`)
-	buf, err := os.ReadFile(f)
+// ParseDexMD parses the Markdown list of a dex/latest.md file (see
+// DexEntry.MD) into a Dex. Lines that are not list items (headers,
+// paragraphs, blank lines) are ignored. Titles may contain square
+// brackets and parentheses since the link target is always matched from
+// the end of the line. Escaped link text is unescaped (see
+// UnescapeLinkText). Returns an error identifying the line number of
+// the first list item that cannot be parsed.
+func ParseDexMD(r io.Reader) (Dex, error) { return parseDexMD(r, failBadLine) }
+
+// failBadLine is the bad line handler used by the strict parsers.
+func failBadLine(err error) error { return err }
+
+// parseDexMD implements ParseDexMD passing every bad line (as
+// BadDexLine) to bad and stopping only if it returns an error.
+func parseDexMD(r io.Reader, bad func(error) error) (Dex, error) {
+	dex := Dex{}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		if !strings.HasPrefix(s.Text(), `* `) {
+			continue
+		}
+		f := LatestDexEntryExp.FindStringSubmatch(s.Text())
+		if len(f) != 4 {
+			if err := bad(BadDexLine{`latest.md`, line, nil}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		t, err := ParseTime(f[1])
+		if err == nil {
+			var id int
+			id, err = strconv.Atoi(f[3])
+			if err == nil {
+				dex = append(dex, DexEntry{U: t, T: UnescapeLinkText(f[2]), N: id})
+				continue
+			}
+		}
+		if err := bad(BadDexLine{`latest.md`, line, err}); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return dex, nil
+}
+
+// ParseDexTSV parses the tab-separated values of a dex/nodes.tsv file
+// (see DexEntry.TSV) into a Dex. Blank lines are skipped. Since older
+// versions did not sanitize titles, rows corrupted by titles with tabs
+// or line returns are repaired rather than rejected: any additional
+// tab-separated fields after the time stamp are joined to the title
+// with a single space, and any line that does not begin with an
+// integer node ID is appended to the title of the entry before it.
+// Returns an error identifying the line number of the first malformed
+// row that cannot be repaired.
+func ParseDexTSV(r io.Reader) (Dex, error) { return parseDexTSV(r, failBadLine) }
+
+// parseDexTSV implements ParseDexTSV passing every bad line (as
+// BadDexLine) to bad and stopping only if it returns an error.
+func parseDexTSV(r io.Reader, bad func(error) error) (Dex, error) {
+	dex := Dex{}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSuffix(s.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		f := strings.Split(text, "\t")
+		id, err := strconv.Atoi(f[0])
+		if err != nil && len(dex) > 0 {
+			dex[len(dex)-1].T += " " + strings.Join(f, " ")
+			continue
+		}
+		var t time.Time
+		switch {
+		case err != nil:
+		case len(f) < 3:
+			err = fmt.Errorf("expected 3 fields")
+		default:
+			t, err = ParseTime(f[1])
+		}
+		if err != nil {
+			if err := bad(BadDexLine{`nodes.tsv`, line, err}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		dex = append(dex, DexEntry{U: t, T: strings.Join(f[2:], " "), N: id})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return dex, nil
+}
+
+// ParseDexYAML parses a YAML list of mappings with u, n, and t keys
+// (see Dex.YAML) into a Dex.
+func ParseDexYAML(r io.Reader) (Dex, error) {
+	var items []struct {
+		U string `yaml:"u"`
+		N int    `yaml:"n"`
+		T string `yaml:"t"`
+	}
+	if err := yaml.NewDecoder(r).Decode(&items); err != nil && err != io.EOF {
+		return nil, err
+	}
+	dex := Dex{}
+	for i, it := range items {
+		t, err := ParseTime(it.U)
+		if err != nil {
+			return nil, fmt.Errorf("bad item in YAML dex: %v: %w", i+1, err)
+		}
+		dex = append(dex, DexEntry{U: t, T: it.T, N: it.N})
+	}
+	return dex, nil
+}
+
+// ReadDexTSV opens the dex/nodes.tsv file at path and passes it to
+// ParseDexTSV.
+func ReadDexTSV(path string) (Dex, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	return ParseDex(buf)
+	defer f.Close()
+	return ParseDexTSV(f)
+}
+
+// ReadDex loads the index of the keg at kegpath from dex/nodes.tsv
+// falling back to dex/latest.md when it does not exist and to ScanDir
+// when neither does. This is the cheapest way to get a Dex for a keg.
+// Corrupt lines are skipped rather than failing the load. The Dex
+// returned is only nil when the index could not be loaded at all.
+// Otherwise any error returned is Errors containing warnings: a
+// BadDexLine for every line skipped, the problems reported by ScanDir,
+// and ErrDexRebuilt (see errors.Is) if scanned. The Dex is always sorted
+// as it was in the file read (ByID if scanned).
+func ReadDex(kegpath string) (Dex, error) {
+	var warnings Errors
+	warn := func(err error) error {
+		warnings = append(warnings, err)
+		return nil
+	}
+	dex, err := readDexFile(kegpath, `nodes.tsv`, parseDexTSV, warn)
+	if dex == nil && err == nil {
+		dex, err = readDexFile(kegpath, `latest.md`, parseDexMD, warn)
+	}
+	if dex == nil && err == nil {
+		dex, err = ScanDir(kegpath)
+		if scanned, is := err.(Errors); is {
+			warnings = append(warnings, scanned...)
+			err = nil
+		}
+		warnings = append(warnings, ErrDexRebuilt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(warnings) > 0 {
+		return dex, warnings
+	}
+	return dex, nil
+}
+
+// readDexFile parses the named file in the dex directory of kegpath
+// with parse returning a nil Dex and error if it does not exist.
+func readDexFile(
+	kegpath, name string,
+	parse func(io.Reader, func(error) error) (Dex, error),
+	bad func(error) error,
+) (Dex, error) {
+	f, err := os.Open(filepath.Join(kegpath, `dex`, name))
+	if err != nil {
+		if errors.Is(err, iofs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return parse(f, bad)
 }
 
 // ScanDex takes the target path to a keg root directory returns a
@@ -94,29 +347,275 @@ func ScanDex(kegdir string) (*Dex, error) {
 	return &dex, nil
 }
 
-// MakeDex calls ScanDex and writes (or overwrites) the output to the
-// reserved dex node file within the kegdir passed. File-level
-// locking is attempted using the go-internal/lockedfile (used by Go
-// itself). Both a friendly markdown file reverse sorted by time of last
-// update (latest.md) and a tab-delimited file sorted numerically by
-// node ID (nodes.tsv) are created.
-func MakeDex(kegdir string) error {
-	dex, err := ScanDex(kegdir)
+// ScanDir walks the keg root directory at path and returns a Dex with
+// an entry for every node directory (any directory named with a
+// canonical non-negative integer including the zero node, so dex,
+// dotdirs, and names like 007 are skipped) sorted ByID. The title is
+// the first "# " line of the node README.md and U is the newest
+// modification time of any file within the node directory (see
+// DexEntry.MD). Nodes that cannot be read (usually a missing README.md)
+// are left out and reported together as Errors (in node ID order)
+// without stopping the scan so the Dex returned is always as complete
+// as possible. Nodes are read concurrently (see ScanDirWith).
+func ScanDir(path string) (Dex, error) { return ScanDirWith(path, ScanOpts{}) }
+
+// ScanOpts contains the options for ScanDirWith.
+type ScanOpts struct {
+	Workers int // max nodes read at once (default runtime.GOMAXPROCS)
+}
+
+// ScanDirWith returns ScanDir reading up to opts.Workers nodes at the
+// same time. The results are identical no matter how many workers are
+// used. A single worker reads the nodes sequentially.
+func ScanDirWith(path string, opts ScanOpts) (Dex, error) {
+	scans, err := scanDir(path, opts, false)
+	dex := make(Dex, 0, len(scans))
+	for _, s := range scans {
+		dex = append(dex, s.DexEntry)
+	}
+	return dex, err
+}
+
+// nodeScan is everything learned about a node from a single pass over
+// its directory (see scanNodeWith). Only the DexEntry is filled in
+// unless full.
+type nodeScan struct {
+	DexEntry
+	Words int      // in README.md
+	Files int      // other than README.md (see NodeChanged)
+	Bytes int64    // total size of Files
+	Tags  []string // see kegml.ReadTags
+}
+
+// scanDir returns the nodeScan of every node directory in path sorted
+// by node ID along with Errors for those that could not be read (see
+// ScanDirWith).
+func scanDir(path string, opts ScanOpts, full bool) ([]nodeScan, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	dirs, _, _ := NodePaths(path)
+	scans := make([]nodeScan, len(dirs))
+	errs := make([]error, len(dirs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(dirs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				scans[i], errs[i] = scanNodeWith(dirs[i].Path, full)
+			}
+		}()
+	}
+	for i := range dirs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	ok := make([]nodeScan, 0, len(scans))
+	var failed []int
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			ok = append(ok, scans[i])
+		case scans[i].N >= 0:
+			failed = append(failed, i)
+		}
+	}
+	sort.SliceStable(ok, func(a, b int) bool { return ok[a].N < ok[b].N })
+	if len(failed) == 0 {
+		return ok, nil
+	}
+	sort.Slice(failed, func(a, b int) bool {
+		return scans[failed[a]].N < scans[failed[b]].N
+	})
+	all := make(Errors, 0, len(failed))
+	for _, i := range failed {
+		all = append(all, errs[i])
+	}
+	return ok, all
+}
+
+// scanNode returns the DexEntry for the node directory at dir. N is
+// negative if dir is not named like a node at all (which is not an
+// error worth reporting).
+func scanNode(dir string) (DexEntry, error) {
+	s, err := scanNodeWith(dir, false)
+	return s.DexEntry, err
+}
+
+// scanNodeWith returns scanNode along with the rest of the nodeScan if
+// full. Either way each file is only read (or stat-ed) once.
+func scanNodeWith(dir string, full bool) (nodeScan, error) {
+	name := filepath.Base(dir)
+	id, err := strconv.Atoi(name)
+	if err != nil || id < 0 || strconv.Itoa(id) != name {
+		return nodeScan{DexEntry: DexEntry{N: -1}},
+			fmt.Errorf("not a node directory: %v", dir)
+	}
+	s := nodeScan{DexEntry: DexEntry{N: id}}
+	readme := filepath.Join(dir, `README.md`)
+	if _, err := os.Stat(readme); err != nil {
+		return s, MissingReadme{id, readme}
+	}
+	if full {
+		byt, err := os.ReadFile(readme)
+		if err != nil {
+			return s, err
+		}
+		if s.T, err = nodeTitle(bytes.NewReader(byt)); err != nil {
+			return s, err
+		}
+		s.Words = len(strings.Fields(string(byt)))
+		s.Tags = kegml.TagsIn(string(byt))
+	} else if s.T, err = readNodeTitle(readme); err != nil {
+		return s, err
+	}
+	s.U, s.Files, s.Bytes, err = nodeFiles(dir)
+	return s, err
+}
+
+// readNodeTitle returns the title of the node README.md file at path,
+// which is the text of the first line beginning with "# ". If there is
+// no such line the first line with any text is used instead (without
+// any leading #). A leading byte order mark and carriage returns are
+// ignored.
+func readNodeTitle(path string) (string, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return err
+		return "", err
+	}
+	defer f.Close()
+	return nodeTitle(f)
+}
+
+// nodeTitle returns the title (see readNodeTitle) of the README.md
+// read from r.
+func nodeTitle(r io.Reader) (string, error) {
+	var first string
+	s := bufio.NewScanner(r)
+	for n := 0; s.Scan(); n++ {
+		line := strings.TrimRight(s.Text(), "\r")
+		if n == 0 {
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+		if strings.HasPrefix(line, `# `) {
+			return strings.TrimSpace(line[2:]), nil
+		}
+		if first == "" {
+			first = strings.TrimSpace(strings.TrimLeft(line, `#`))
+		}
+	}
+	return first, s.Err()
+}
+
+// UpdateDex scans the keg at kegpath (see ScanDir) and writes both
+// dex/nodes.tsv (sorted ByID) and dex/latest.md (sorted ByLatest),
+// creating the dex directory if needed. Each file is written to
+// a temporary file first and then renamed into place so that a crash
+// never leaves a truncated index behind. Existing file permissions are
+// kept. The Dex computed is returned (sorted ByID) even when the scan
+// reported problems with some nodes (as Errors) so that callers can
+// print a summary. Any other error means nothing more was written.
+func UpdateDex(kegpath string) (Dex, error) {
+	return UpdateDexWith(kegpath, UpdateOpts{})
+}
+
+// UpdateOpts contains the options for UpdateDexWith.
+type UpdateOpts struct {
+	NoZeroLatest bool // leave the zero node out of dex/latest.md
+}
+
+// UpdateDexWith is UpdateDex with options. The dex/nodes.tsv file always
+// includes every node (including the zero node). The dex lock is held
+// throughout (see LockDex).
+func UpdateDexWith(kegpath string, opts UpdateOpts) (Dex, error) {
+	var dex Dex
+	var scanerr error
+	err := withDexLock(kegpath, func() error {
+		dex, scanerr = ScanDir(kegpath)
+		if _, is := scanerr.(Errors); scanerr != nil && !is {
+			return scanerr
+		}
+		latest := dex
+		if opts.NoZeroLatest {
+			latest = dex.WithoutZero()
+		}
+		return writeDex(kegpath, dex, latest)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return dex, scanerr
+}
+
+// WriteDex writes dex to both dex/nodes.tsv (sorted ByID) and
+// dex/latest.md (sorted ByLatest) of the keg at kegpath creating the
+// dex directory if needed. Both are written atomically (see UpdateDex).
+// Callers that read the dex before writing it should hold the dex lock
+// (see LockDex) around both.
+func WriteDex(kegpath string, dex Dex) error { return writeDex(kegpath, dex, dex) }
 
-	// markdown is first since reverse chrono of updates is default
-	mdpath := filepath.Join(kegdir, `dex`, `latest.md`)
-	if err := file.Overwrite(mdpath, dex.MD()); err != nil {
+// writeDex writes dex to dex/nodes.tsv and latest to dex/latest.md.
+func writeDex(kegpath string, dex, latest Dex) error {
+	dexdir := filepath.Join(kegpath, `dex`)
+	if err := os.MkdirAll(dexdir, 0755); err != nil {
 		return err
 	}
+	tsv := filepath.Join(dexdir, `nodes.tsv`)
+	if err := writeFileAtomic(tsv, dex.ByID().TSV()); err != nil {
+		return err
+	}
+	md := filepath.Join(dexdir, `latest.md`)
+	return writeFileAtomic(md, latest.ByLatest().MD())
+}
 
-	tsvpath := filepath.Join(kegdir, `dex`, `nodes.tsv`)
-	if err := file.Overwrite(tsvpath, dex.ByID().TSV()); err != nil {
+// writeFileAtomic replaces the file at path with content by writing to
+// a temporary file in the same directory and renaming it. The
+// permissions of the file being replaced are kept (0644 if new).
+func writeFileAtomic(path, content string) error {
+	perm := iofs.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), `.`+filepath.Base(path)+`-*`)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly after rename
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
 		return err
 	}
+	return os.Rename(tmp.Name(), path)
+}
 
+// MakeDex calls UpdateDex to write (or overwrite) the reserved dex
+// node files within the kegdir passed and then updates the keg info
+// file (see UpdateUpdated). Both a friendly markdown file reverse
+// sorted by time of last update (latest.md) and a tab-delimited file
+// sorted numerically by node ID (nodes.tsv) are created. Problems with
+// individual nodes are logged rather than returned.
+func MakeDex(kegdir string) error {
+	if _, err := UpdateDex(kegdir); err != nil {
+		if _, is := err.(Errors); !is {
+			return err
+		}
+		log.Println(err)
+	}
 	return UpdateUpdated(kegdir)
 }
 
@@ -143,7 +642,7 @@ func MkTempNode() (string, error) {
 func ImportNode(from, to, nodeid string) error {
 	to = path.Join(to, nodeid)
 	if _fs.Exists(to) {
-		return _fs.ErrorExists{to}
+		return _fs.ErrorExists{P: to}
 	}
 	return os.Rename(from, to)
 }
@@ -1,8 +1,14 @@
 package keg_test
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
 
 	"github.com/rwxrob/keg"
 )
@@ -31,6 +37,197 @@ func ExampleUpdatedString() {
 	// 2022-11-17 18:34:10Z
 }
 
+func ExampleParseDexMD() {
+	in := "# Latest Changes\n\n" +
+		"* 2022-12-10 06:10:04Z [Using [brackets] in (titles)](/3)\n" +
+		"* 2022-12-10 06:10:04Z [Some title](/2)\n"
+	dex, err := keg.ParseDexMD(strings.NewReader(in))
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, e := range dex {
+		fmt.Printf("%v %q\n", e.N, e.T)
+	}
+	// Output:
+	// 3 "Using [brackets] in (titles)"
+	// 2 "Some title"
+}
+
+func ExampleParseDexMD_roundtrip() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 2, T: `Some title`},
+		{U: date.Add(time.Hour), N: 10, T: `Another (title)`},
+		{U: date, N: 11, T: `Using [brackets] in (titles)`},
+		{U: date, N: 12, T: `Back\slash \[already\] escaped]`},
+	}
+	got, err := keg.ParseDexMD(strings.NewReader(dex.MD()))
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(reflect.DeepEqual(got, dex))
+	// Output:
+	// true
+}
+
+func ExampleParseDexMD_bad() {
+	in := "* 2022-12-10 06:10:04Z [Some title](/2)\n* not an entry\n"
+	_, err := keg.ParseDexMD(strings.NewReader(in))
+	fmt.Println(err)
+	// Output:
+	// bad line in latest.md: 2
+}
+
+func ExampleParseDexTSV() {
+	in := "2\t2022-12-10 06:10:04Z\tSome title\n\n" +
+		"3\t2022-12-10 06:10:04Z\tSome\ttabbed title\n"
+	dex, err := keg.ParseDexTSV(strings.NewReader(in))
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, e := range dex {
+		fmt.Printf("%v %q\n", e.N, e.T)
+	}
+	// Output:
+	// 2 "Some title"
+	// 3 "Some tabbed title"
+}
+
+func ExampleParseDexTSV_legacy() {
+	in := "2\t2022-12-10 06:10:04Z\tSome title\r\n" +
+		"3\t2022-12-10 06:10:04Z\tSplit\n" +
+		"over\tlines\n"
+	dex, err := keg.ParseDexTSV(strings.NewReader(in))
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, e := range dex {
+		fmt.Printf("%v %q\n", e.N, e.T)
+	}
+	// Output:
+	// 2 "Some title"
+	// 3 "Split over lines"
+}
+
+func ExampleParseDexTSV_roundtrip() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 2, T: "Pasted\ttab"},
+		{U: date, N: 3, T: "Pasted\r\nline"},
+		{U: date, N: 4, T: "\tall\nof\rthem"},
+	}
+	got, err := keg.ParseDexTSV(strings.NewReader(dex.TSV()))
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, e := range got {
+		fmt.Printf("%v %q\n", e.N, e.T)
+	}
+	// Output:
+	// 2 "Pasted tab"
+	// 3 "Pasted line"
+	// 4 " all of them"
+}
+
+func ExampleParseDexTSV_bad() {
+	in := "2\t2022-12-10 06:10:04Z\tSome title\n3\tyesterday\tBad time\n"
+	_, err := keg.ParseDexTSV(strings.NewReader(in))
+	fmt.Println(err)
+	_, err = keg.ParseDexTSV(strings.NewReader("3\n"))
+	fmt.Println(err)
+	// Output:
+	// bad line in nodes.tsv: 2: unrecognized time: "yesterday"
+	// bad line in nodes.tsv: 1: expected 3 fields
+}
+
+func ExampleParseDexYAML() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 1, T: `*starred* title`},
+		{U: date, N: 2, T: `key: value`},
+		{U: date, N: 3, T: `# not a comment`},
+		{U: date, N: 4, T: `"quoted" \ back`},
+	}
+	fmt.Print(dex.YAML())
+	got, err := keg.ParseDexYAML(strings.NewReader(dex.YAML()))
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(reflect.DeepEqual(got, dex))
+	empty, _ := keg.ParseDexYAML(strings.NewReader(keg.Dex{}.YAML()))
+	fmt.Println(len(empty))
+	// Output:
+	// - u: "2022-12-10 06:10:04Z"
+	//   n: 1
+	//   t: "*starred* title"
+	// - u: "2022-12-10 06:10:04Z"
+	//   n: 2
+	//   t: "key: value"
+	// - u: "2022-12-10 06:10:04Z"
+	//   n: 3
+	//   t: "# not a comment"
+	// - u: "2022-12-10 06:10:04Z"
+	//   n: 4
+	//   t: "\"quoted\" \\ back"
+	// true
+	// 0
+}
+
+func ExampleParseTime() {
+	for _, s := range []string{
+		`2023-01-14 09:12:05Z`,
+		`2023-01-14T09:12:05Z`,
+		`2023-01-14T04:12:05-05:00`,
+		`2023-01-14 09:12Z`,
+		`2023-01-14`,
+	} {
+		t, err := keg.ParseTime(s)
+		fmt.Println(t.Format(keg.IsoDateFmt), t.Location(), err)
+	}
+	_, err := keg.ParseTime(`01/14/2023`)
+	fmt.Println(err)
+	// Output:
+	// 2023-01-14 09:12:05Z UTC <nil>
+	// 2023-01-14 09:12:05Z UTC <nil>
+	// 2023-01-14 09:12:05Z UTC <nil>
+	// 2023-01-14 09:12:00Z UTC <nil>
+	// 2023-01-14 00:00:00Z UTC <nil>
+	// unrecognized time: "01/14/2023"
+}
+
+func ExampleParseDexMD_lenient() {
+	in := "* 2023-01-14T09:12:05Z [RFC3339](/1)\n" +
+		"* 2023-01-14 09:12Z [No seconds](/2)\n" +
+		"* 2023-01-14 [Date only](/3)\n"
+	dex, err := keg.ParseDexMD(strings.NewReader(in))
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Print(dex.MD())
+	dex, err = keg.ParseDexTSV(strings.NewReader("4\t2023-01-14\tDate only\n"))
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Print(dex.TSV())
+	// Output:
+	// * 2023-01-14 09:12:05Z [RFC3339](/1)
+	// * 2023-01-14 09:12:00Z [No seconds](/2)
+	// * 2023-01-14 00:00:00Z [Date only](/3)
+	// 4	2023-01-14 00:00:00Z	Date only
+}
+
+func ExampleReadDexTSV() {
+	dex, err := keg.ReadDexTSV(`testdata/samplekeg/dex/nodes.tsv`)
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(len(dex))
+	fmt.Println(dex[12].TSV())
+	// Output:
+	// 13
+	// 12	2022-11-17 15:37:57Z	Some title for 12
+}
+
 /*
 func ExampleDex_WithTitleText() {
 	dex, _ := keg.ReadDex(`testdata/samplekeg`)
@@ -40,14 +237,14 @@ func ExampleDex_WithTitleText() {
 }
 */
 
-/*
 func ExampleReadDex() {
-	dex, _ := keg.ReadDex(`testdata/samplekeg`)
-	fmt.Println(dex.TSV())
+	dex, err := keg.ReadDex(`testdata/samplekeg`)
+	fmt.Println(len(dex), err)
+	fmt.Println(dex[6].TSV())
 	// Output:
-	// ignored
+	// 13 <nil>
+	// 6	2022-11-17 18:34:10Z	Some title for 6
 }
-*/
 
 /*
 func ExampleUpdateUpdated() {
@@ -77,3 +274,258 @@ func ExampleMakeDex() {
 	// ignored
 }
 */
+
+// mkNode creates a node directory under root with the README.md
+// content and modification time passed (no README.md if content is
+// empty).
+func mkNode(t *testing.T, root, name, content string, mod time.Time) string {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if content != "" {
+		readme := filepath.Join(dir, `README.md`)
+		if err := os.WriteFile(readme, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(readme, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(dir, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestScanDir(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `3`, "# Three\n", old)
+	mkNode(t, root, `0`, "Some preamble\n\n# Zero\n\nBody\n", old)
+	one := mkNode(t, root, `1`, "# One\n", old)
+	newer := old.Add(time.Hour)
+	other := filepath.Join(one, `data.txt`)
+	if err := os.WriteFile(other, []byte(`data`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(other, newer, newer); err != nil {
+		t.Fatal(err)
+	}
+	mkNode(t, root, `4`, "", old)
+	mkNode(t, root, `dex`, "# Not a node\n", old)
+	mkNode(t, root, `.git`, "# Not a node\n", old)
+	mkNode(t, root, `007`, "# Not a node\n", old)
+
+	dex, err := keg.ScanDir(root)
+
+	want := keg.Dex{
+		{U: old, N: 0, T: `Zero`},
+		{U: newer, N: 1, T: `One`},
+		{U: old, N: 3, T: `Three`},
+	}
+	if !dex.Equal(want) {
+		t.Errorf("got:\n%vwant:\n%v", dex, want)
+	}
+	var missing keg.MissingReadme
+	if !errors.As(err, &missing) || missing.N != 4 {
+		t.Errorf("expected MissingReadme for node 4, got %v", err)
+	}
+}
+
+func TestScanDirWith(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	for i := 0; i < 50; i++ {
+		content := fmt.Sprintf("# Node %v\n", i)
+		if i%7 == 3 {
+			content = ""
+		}
+		mkNode(t, root, fmt.Sprint(i), content, old.Add(time.Duration(i)*time.Minute))
+	}
+	want, wanterr := keg.ScanDirWith(root, keg.ScanOpts{Workers: 1})
+	if len(want) != 43 {
+		t.Fatalf("expected 43 nodes, got %v", len(want))
+	}
+	for _, n := range []int{2, 8, 64} {
+		got, goterr := keg.ScanDirWith(root, keg.ScanOpts{Workers: n})
+		if !got.Equal(want) {
+			t.Errorf("workers %v: got:\n%vwant:\n%v", n, got, want)
+		}
+		if goterr.Error() != wanterr.Error() {
+			t.Errorf("workers %v: got errors:\n%v\nwant:\n%v", n, goterr, wanterr)
+		}
+	}
+	if errs, ok := wanterr.(keg.Errors); !ok || len(errs) != 7 ||
+		errs[0].(keg.MissingReadme).N != 3 || errs[6].(keg.MissingReadme).N != 45 {
+		t.Errorf("unexpected errors: %v", wanterr)
+	}
+}
+
+func benchmarkScanDir(b *testing.B, workers int) {
+	root := b.TempDir()
+	for i := 0; i < 5000; i++ {
+		dir := filepath.Join(root, fmt.Sprint(i))
+		if err := os.Mkdir(dir, 0700); err != nil {
+			b.Fatal(err)
+		}
+		readme := filepath.Join(dir, `README.md`)
+		content := fmt.Sprintf("# Node %v\n\nSome body.\n", i)
+		if err := os.WriteFile(readme, []byte(content), 0600); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := keg.ScanDirWith(root, keg.ScanOpts{Workers: workers}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScanDir_sequential(b *testing.B) { benchmarkScanDir(b, 1) }
+func BenchmarkScanDir_concurrent(b *testing.B) { benchmarkScanDir(b, 0) }
+func BenchmarkScanDir_workers16(b *testing.B)  { benchmarkScanDir(b, 16) }
+
+func TestUpdateDex(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Zero\n", old)
+	mkNode(t, root, `1`, "# One\n", old.Add(2*time.Hour))
+	mkNode(t, root, `2`, "# Two\n", old.Add(time.Hour))
+
+	dex, err := keg.UpdateDex(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dex) != 3 || dex[0].N != 0 || dex[2].N != 2 {
+		t.Errorf("unexpected dex returned:\n%v", dex)
+	}
+	tsv, _ := os.ReadFile(filepath.Join(root, `dex`, `nodes.tsv`))
+	if string(tsv) != dex.TSV() {
+		t.Errorf("unexpected nodes.tsv:\n%s", tsv)
+	}
+	md, _ := os.ReadFile(filepath.Join(root, `dex`, `latest.md`))
+	want := "* 2022-12-10 08:10:04Z [One](/1)\n" +
+		"* 2022-12-10 07:10:04Z [Two](/2)\n" +
+		"* 2022-12-10 06:10:04Z [Zero](/0)\n"
+	if string(md) != want {
+		t.Errorf("unexpected latest.md:\n%s", md)
+	}
+
+	// permissions are kept and no temporary files are left behind
+	tsvpath := filepath.Join(root, `dex`, `nodes.tsv`)
+	if err := os.Chmod(tsvpath, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(tsvpath); info.Mode().Perm() != 0600 {
+		t.Errorf("permissions not kept: %v", info.Mode())
+	}
+	files, _ := os.ReadDir(filepath.Join(root, `dex`))
+	if len(files) != 2 {
+		t.Errorf("expected only two files in dex, got %v", len(files))
+	}
+}
+
+func TestUpdateDexWith(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Zero\n", old.Add(3*time.Hour))
+	mkNode(t, root, `1`, "# One\n", old)
+
+	dex, err := keg.UpdateDexWith(root, keg.UpdateOpts{NoZeroLatest: true})
+	if err != nil || len(dex) != 2 || dex.Next() != 2 {
+		t.Fatalf("unexpected dex: %v (%v)", dex, err)
+	}
+	tsv, _ := os.ReadFile(filepath.Join(root, `dex`, `nodes.tsv`))
+	if string(tsv) != dex.TSV() {
+		t.Errorf("zero node missing from nodes.tsv:\n%s", tsv)
+	}
+	md, _ := os.ReadFile(filepath.Join(root, `dex`, `latest.md`))
+	if string(md) != "* 2022-12-10 06:10:04Z [One](/1)\n" {
+		t.Errorf("unexpected latest.md:\n%s", md)
+	}
+}
+
+func TestReadDex(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Zero\n", old)
+	mkNode(t, root, `1`, "# One\n", old)
+
+	// neither dex file so rebuilt from the node directories
+	dex, err := keg.ReadDex(root)
+	if len(dex) != 2 || !errors.Is(err, keg.ErrDexRebuilt) {
+		t.Errorf("expected rebuilt dex, got %v, %v", dex, err)
+	}
+
+	// latest.md only (with a corrupt line)
+	dexdir := filepath.Join(root, `dex`)
+	if err := os.Mkdir(dexdir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	md := "* 2022-12-10 06:10:04Z [One](/1)\n" +
+		"* corrupt\n" +
+		"* 2022-12-10 06:10:03Z [Zero](/0)\n"
+	if err := os.WriteFile(filepath.Join(dexdir, `latest.md`), []byte(md), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dex, err = keg.ReadDex(root)
+	var bad keg.BadDexLine
+	if len(dex) != 2 || dex[0].N != 1 || !errors.As(err, &bad) ||
+		bad.File != `latest.md` || bad.Line != 2 || errors.Is(err, keg.ErrDexRebuilt) {
+		t.Errorf("unexpected latest.md dex, got %v, %v", dex, err)
+	}
+
+	// nodes.tsv preferred when it exists
+	tsv := "0\t2022-12-10 06:10:04Z\tZero\n" +
+		"1\tnot a time\tOne\n" +
+		"2\t2022-12-10 06:10:04Z\tTwo\n"
+	if err := os.WriteFile(filepath.Join(dexdir, `nodes.tsv`), []byte(tsv), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dex, err = keg.ReadDex(root)
+	if len(dex) != 2 || dex[1].N != 2 || !errors.As(err, &bad) ||
+		bad.File != `nodes.tsv` || bad.Line != 2 {
+		t.Errorf("unexpected nodes.tsv dex, got %v, %v", dex, err)
+	}
+
+	// unreadable dex file fails the load
+	tsvpath := filepath.Join(dexdir, `nodes.tsv`)
+	if err := os.Remove(tsvpath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(tsvpath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if dex, err = keg.ReadDex(root); dex != nil || err == nil {
+		t.Errorf("expected failed load, got %v, %v", dex, err)
+	}
+}
+
+func ExampleOpen() {
+	k, err := keg.Open(`testdata/samplekeg`)
+	fmt.Println(k.Name, err)
+	fmt.Println(k.Info.Title)
+	fmt.Println(filepath.Base(filepath.Dir(k.Path(6))), filepath.Base(k.Path(6)))
+	node, err := k.Node(6)
+	fmt.Println(node.ID, node.Title, err)
+	_, err = k.Node(99)
+	fmt.Println(err)
+	last, err := k.LastChanged()
+	fmt.Println(last, err)
+	_, err = keg.Open(`testdata`)
+	fmt.Println(err)
+	// Output:
+	// samplekeg <nil>
+	// A Sample Keg
+	// samplekeg 6
+	// 6 Some title for 6 <nil>
+	// node not found: 99
+	// 2022-11-17 18:34:10 +0000 UTC <nil>
+	// not a keg directory: testdata
+}
@@ -0,0 +1,229 @@
+package keg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KegInfo is the content of the keg info file (named keg) at the top of
+// every keg directory. The file uses a simplified YAML format in which
+// a multi-line value (like the summary) is just indented text under its
+// key. Only Updated is required. Any keys not known are kept (in
+// order) and written back out by Save.
+type KegInfo struct {
+	Updated time.Time
+	KegV    string // version of the KEG specification
+	Title   string
+	URL     string
+	Creator string
+	State   string
+	Summary string
+	Indexes []KegIndex
+
+	unknown []*yaml.Node // key and value pairs
+}
+
+// KegIndex is a single entry in the indexes list of the KegInfo.
+type KegIndex struct {
+	File    string `yaml:"file"`
+	Summary string `yaml:"summary"`
+}
+
+// LoadKegInfo reads the keg info file at path (see ParseKegInfo).
+func LoadKegInfo(path string) (*KegInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseKegInfo(f)
+}
+
+// ParseKegInfo parses the simplified YAML of a keg info file. Returns
+// an error if it cannot be parsed or if the updated field is missing or
+// is not a time accepted by ParseTime.
+func ParseKegInfo(r io.Reader) (*KegInfo, error) {
+	byt, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(literalBlocks(byt), &doc); err != nil {
+		return nil, fmt.Errorf("invalid keg info: %w", err)
+	}
+	info := new(KegInfo)
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid keg info: missing updated")
+	}
+	m := doc.Content[0].Content
+	var hasupdated bool
+	for i := 0; i+1 < len(m); i += 2 {
+		key, val := m[i], m[i+1]
+		var err error
+		switch key.Value {
+		case `updated`:
+			hasupdated = true
+			info.Updated, err = ParseTime(val.Value)
+		case `kegv`:
+			info.KegV = val.Value
+		case `title`:
+			info.Title = val.Value
+		case `url`:
+			info.URL = val.Value
+		case `creator`:
+			info.Creator = val.Value
+		case `state`:
+			info.State = val.Value
+		case `summary`:
+			info.Summary = strings.TrimRight(val.Value, "\n")
+		case `indexes`:
+			err = val.Decode(&info.Indexes)
+		default:
+			info.unknown = append(info.unknown, key, val)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid keg info: %v: %w", key.Value, err)
+		}
+	}
+	if !hasupdated {
+		return nil, fmt.Errorf("invalid keg info: missing updated")
+	}
+	return info, nil
+}
+
+var blockKey = regexp.MustCompile(`^[A-Za-z][\w-]*:[ \t]*$`)
+var yamlBlock = regexp.MustCompile(`^\s+(- |[\w-]+:(\s|$))`)
+
+// literalBlocks turns every top-level key followed by indented plain
+// text (rather than a YAML list or mapping) into a YAML literal block
+// so that the text can contain anything.
+func literalBlocks(byt []byte) []byte {
+	lines := strings.Split(string(byt), "\n")
+	for i, line := range lines {
+		if !blockKey.MatchString(line) {
+			continue
+		}
+		for _, next := range lines[i+1:] {
+			if strings.TrimSpace(next) == "" {
+				continue
+			}
+			if next[0] == ' ' && !yamlBlock.MatchString(next) {
+				lines[i] = strings.TrimRight(line, " \t") + ` |`
+			}
+			break
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// URLs returns the name to URL mapping of the optional urls section of
+// the keg info file (kept with the unknown keys) such as the rsync
+// destination used by the publish command. Returns an empty map if there
+// is no urls section (or it is not a mapping).
+func (i *KegInfo) URLs() map[string]string {
+	urls := map[string]string{}
+	for n := 0; n+1 < len(i.unknown); n += 2 {
+		if i.unknown[n].Value != `urls` || i.unknown[n+1].Kind != yaml.MappingNode {
+			continue
+		}
+		m := i.unknown[n+1].Content
+		for j := 0; j+1 < len(m); j += 2 {
+			urls[m[j].Value] = m[j+1].Value
+		}
+	}
+	return urls
+}
+
+// Save writes the KegInfo to the file at path (see Bytes).
+func (i *KegInfo) Save(path string) error {
+	byt, err := i.Bytes()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, string(byt))
+}
+
+// Bytes returns the KegInfo in the same simplified YAML format parsed
+// by ParseKegInfo with updated always on the first line and empty
+// optional fields left out. Values are quoted wherever YAML would
+// otherwise read them as something else (see yamlScalar). Unknown keys
+// come last.
+func (i *KegInfo) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	field := func(key, val string) {
+		if val == "" || err != nil {
+			return
+		}
+		if key != `updated` {
+			if val, err = yamlScalar(val); err != nil {
+				return
+			}
+		}
+		fmt.Fprintf(&buf, "%-9v%v\n", key+`:`, val)
+	}
+	field(`updated`, i.Updated.UTC().Format(IsoDateFmt))
+	field(`kegv`, i.KegV)
+	if i.Title+i.URL+i.Creator+i.State != "" {
+		buf.WriteString("\n")
+		field(`title`, i.Title)
+		field(`url`, i.URL)
+		field(`creator`, i.Creator)
+		field(`state`, i.State)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if i.Summary != "" {
+		buf.WriteString("\nsummary:\n")
+		for _, line := range strings.Split(i.Summary, "\n") {
+			buf.WriteString("  " + line + "\n")
+		}
+	}
+	if len(i.Indexes) > 0 {
+		buf.WriteString("\nindexes:\n")
+		for _, idx := range i.Indexes {
+			file, err := yamlScalar(idx.File)
+			if err != nil {
+				return nil, err
+			}
+			summary, err := yamlScalar(idx.Summary)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&buf, "  - file: %v\n    summary: %v\n", file, summary)
+		}
+	}
+	for n := 0; n+1 < len(i.unknown); n += 2 {
+		buf.WriteString("\n")
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		pair := &yaml.Node{Kind: yaml.MappingNode, Content: i.unknown[n : n+2]}
+		if err := enc.Encode(pair); err != nil {
+			return nil, err
+		}
+		enc.Close()
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlScalar returns val as a single line YAML scalar (quoted only if
+// needed) so that it reads back as the same string.
+func yamlScalar(val string) (string, error) {
+	n := yaml.Node{Kind: yaml.ScalarNode, Tag: `!!str`, Value: val}
+	if strings.ContainsAny(val, "\n\r") {
+		n.Style = yaml.DoubleQuotedStyle
+	}
+	byt, err := yaml.Marshal(&n)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(byt), "\n"), nil
+}
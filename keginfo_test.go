@@ -0,0 +1,124 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleLoadKegInfo() {
+	info, err := keg.LoadKegInfo(`testdata/samplekeg/keg`)
+	fmt.Println(err)
+	fmt.Println(info.Updated)
+	fmt.Println(info.KegV, info.Title, info.State)
+	fmt.Println(info.URL)
+	fmt.Println(strings.Split(info.Summary, "\n")[0])
+	fmt.Println(info.Indexes)
+	// Output:
+	// <nil>
+	// 2022-11-20 15:39:05 +0000 UTC
+	// 2023-01 A Sample Keg living
+	// git@github.com:YOU/keg.git
+	// 👋 Hey there! The KEG community welcomes you. This is an initial
+	// [{dex/latest.md latest changes} {dex/nodes.tsv all nodes by id}]
+}
+
+func ExampleParseKegInfo_minimal() {
+	info, err := keg.ParseKegInfo(strings.NewReader("updated: 2023-01-14T10:01:02Z\n"))
+	fmt.Println(err)
+	fmt.Println(info.Updated, info.Title == "", info.Indexes == nil)
+	byt, _ := info.Bytes()
+	fmt.Print(string(byt))
+	// Output:
+	// <nil>
+	// 2023-01-14 10:01:02 +0000 UTC true true
+	// updated: 2023-01-14 10:01:02Z
+}
+
+func ExampleParseKegInfo_invalid() {
+	_, err := keg.ParseKegInfo(strings.NewReader("updated: yesterday\ntitle: Foo\n"))
+	fmt.Println(err)
+	_, err = keg.ParseKegInfo(strings.NewReader("title: Foo\n"))
+	fmt.Println(err)
+	// Output:
+	// invalid keg info: updated: unrecognized time: "yesterday"
+	// invalid keg info: missing updated
+}
+
+func TestKegInfo_Save(t *testing.T) {
+	orig, err := os.ReadFile(`testdata/samplekeg/keg`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := string(orig) + "\n\nextra:\n  - one\n  - two\nother: thing\n"
+	info, err := keg.ParseKegInfo(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := t.TempDir() + `/keg`
+	if err := info.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	want := string(orig) + "\n\nextra:\n  - one\n  - two\n\nother: thing\n"
+	if string(got) != want {
+		t.Errorf("got:\n%v\nwant:\n%v", string(got), want)
+	}
+	again, err := keg.LoadKegInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Summary != info.Summary || !again.Updated.Equal(info.Updated) {
+		t.Errorf("round trip changed info:\n%v", again)
+	}
+}
+
+func TestKegInfo_URLs(t *testing.T) {
+	info, err := keg.ParseKegInfo(strings.NewReader("updated: 2023-01-14T10:01:02Z\n" +
+		"url: https://example.com/keg\n\nurls:\n  rsync: host:/srv/keg\n  git: git@example.com:keg.git\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := info.URLs()
+	if len(urls) != 2 || urls[`rsync`] != `host:/srv/keg` || urls[`git`] != `git@example.com:keg.git` {
+		t.Errorf("unexpected urls: %v", urls)
+	}
+	byt, _ := info.Bytes()
+	if !strings.Contains(string(byt), "urls:\n  rsync: host:/srv/keg\n") {
+		t.Errorf("urls section not kept: %q", byt)
+	}
+	info, _ = keg.ParseKegInfo(strings.NewReader("updated: 2023-01-14T10:01:02Z\n"))
+	if urls := info.URLs(); urls == nil || len(urls) != 0 {
+		t.Errorf("expected empty urls, got %v", urls)
+	}
+}
+
+func TestKegInfo_Bytes_roundTrip(t *testing.T) {
+	for _, title := range []string{
+		`Go: Notes`, `My #1 keg`, `- dash`, `[x]`, `yes`, `{braces}`, `'quoted'`,
+		`"double"`, `plain title`, `100`, `~`, `null`, `Two\nLines`, "Two\nLines",
+	} {
+		info := &keg.KegInfo{
+			Updated: time.Date(2023, 1, 14, 10, 1, 2, 0, time.UTC),
+			Title:   title, Creator: title, State: title, URL: title,
+			Indexes: []keg.KegIndex{{File: `dex/nodes.tsv`, Summary: title}},
+		}
+		byt, err := info.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := keg.ParseKegInfo(strings.NewReader(string(byt)))
+		if err != nil {
+			t.Errorf("%q: %v:\n%s", title, err, byt)
+			continue
+		}
+		if got.Title != title || got.Creator != title || got.State != title || got.URL != title ||
+			len(got.Indexes) != 1 || got.Indexes[0].Summary != title {
+			t.Errorf("%q: did not round trip:\n%s", title, byt)
+		}
+	}
+}
@@ -17,6 +17,8 @@ var PEGN string
 const (
 	Untyped int = iota
 	Title
+	Tags
+	Tag
 )
 
 // ------------------------------- Title ------------------------------
@@ -74,3 +76,101 @@ func ReadTitle(path string) (string, error) {
 	}
 	return nd.V, nil
 }
+
+// ------------------------------- Tags -------------------------------
+
+// ScanTags scans a single line of one or more hashtags separated by
+// spaces (optionally indented) appending the text of each (without the
+// hashtag itself) to buf if not nil. Anything else on the line
+// (including a title, which has a space after the hashtag) causes the
+// whole line to be reverted.
+func ScanTags(s pegn.Scanner, buf *[]string) bool {
+	m := s.Mark()
+	var tags []string
+	for {
+		if s.Finished() {
+			break
+		}
+		b := s.Mark()
+		if !s.Scan() {
+			break
+		}
+		r := s.Rune()
+		if r == '\n' {
+			break
+		}
+		if r == ' ' || r == '\t' {
+			continue
+		}
+		s.Goto(b)
+		tag := make([]rune, 0, 20)
+		if !ScanTag(s, &tag) {
+			return s.Revert(m, Tags)
+		}
+		tags = append(tags, string(tag))
+	}
+	if len(tags) == 0 {
+		return s.Revert(m, Tags)
+	}
+	if buf != nil {
+		*buf = append(*buf, tags...)
+	}
+	return true
+}
+
+// ScanTag scans a single hashtag (# or ＃) followed by one or more
+// runes that are not space or another hashtag appending the runes (but
+// not the hashtag) to buf if not nil.
+func ScanTag(s pegn.Scanner, buf *[]rune) bool {
+	m := s.Mark()
+	if !s.Scan() || !(s.Rune() == '#' || s.Rune() == '\uFF03') {
+		return s.Revert(m, Tag)
+	}
+	var count int
+	for !s.Finished() {
+		b := s.Mark()
+		s.Scan()
+		r := s.Rune()
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			s.Goto(b)
+			break
+		}
+		if r == '#' || r == '\uFF03' {
+			return s.Revert(m, Tag)
+		}
+		if buf != nil {
+			*buf = append(*buf, r)
+		}
+		count++
+	}
+	if count == 0 {
+		return s.Revert(m, Tag)
+	}
+	return true
+}
+
+// ReadTags reads the tags from the tag line of a KEGML file (the last
+// line with any text if it contains only hashtags, see ScanTags).
+// Returns an empty slice if there is no tag line.
+func ReadTags(path string) ([]string, error) {
+	if !strings.HasSuffix(path, `README.md`) {
+		path = filepath.Join(path, `README.md`)
+	}
+	byt, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return TagsIn(string(byt)), nil
+}
+
+// TagsIn returns the tags from the tag line of the KEGML text (see
+// ReadTags).
+func TagsIn(text string) []string {
+	lines := strings.Split(strings.TrimRight(text, " \t\r\n"), "\n")
+	last := strings.TrimRight(lines[len(lines)-1], "\r")
+	tags := []string{}
+	if len(lines) > 1 { // first line is always the title
+		ScanTags(scanner.New(last), &tags)
+	}
+	return tags
+}
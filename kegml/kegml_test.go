@@ -20,7 +20,7 @@ func ExampleScanTitle_short() {
 
 }
 
-func ExampleTitle_parsed_Short() {
+func ExampleTitle_parsed_short() {
 
 	s := scanner.New(`# A short title`)
 
@@ -44,7 +44,7 @@ func ExampleTitle() {
 	// This is a title
 }
 
-func ExampleTitle_no_README() {
+func ExampleTitle_no_readme() {
 	title, _ := kegml.ReadTitle(`testdata/sample-node`)
 	fmt.Println(title)
 	// Output:
@@ -79,3 +79,40 @@ func ExampleTitle_parsed_Long() {
 
 }
 */
+
+func ExampleScanTags() {
+	for _, line := range []string{
+		`#go #keg`,
+		`    #indented  #tags`,
+		`# A title`,
+		`#go and some words`,
+		`##double`,
+	} {
+		tags := []string{}
+		fmt.Println(kegml.ScanTags(scanner.New(line), &tags), tags)
+	}
+	// Output:
+	// true [go keg]
+	// true [indented tags]
+	// false []
+	// false []
+	// false []
+}
+
+func ExampleReadTags() {
+	tags, err := kegml.ReadTags(`testdata/tagged-node`)
+	fmt.Println(tags, err)
+	tags, err = kegml.ReadTags(`testdata/sample-node`)
+	fmt.Println(tags, err)
+	// Output:
+	// [go keg wide] <nil>
+	// [] <nil>
+}
+
+func ExampleTagsIn() {
+	fmt.Println(kegml.TagsIn("# Title\n\nSome text.\n\n#one #two\n"))
+	fmt.Println(kegml.TagsIn("# #title #only\n"))
+	// Output:
+	// [one two]
+	// []
+}
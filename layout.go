@@ -0,0 +1,71 @@
+package keg
+
+import (
+	"bytes"
+	"io"
+)
+
+// RFC3339Fmt is the strict RFC3339 alternative to IsoDateFmt (with
+// a T separator) for consumers that cannot parse the space. Pass it to
+// WithTimeLayout. ParseTime accepts both.
+const RFC3339Fmt = `2006-01-02T15:04:05Z`
+
+// DexView is a rendering view of a Dex that formats every time with
+// Layout instead of IsoDateFmt. The entries are always converted to UTC
+// first so Layout should end in a literal Z (or a zone like Z07:00).
+// Create with Dex.WithTimeLayout.
+type DexView struct {
+	Dex    Dex
+	Layout string
+}
+
+// WithTimeLayout returns a view of the Dex whose renderers (MD, TSV,
+// JSON, YAML, CSV) format times with the given layout. An empty layout
+// is the same as IsoDateFmt.
+func (e Dex) WithTimeLayout(layout string) DexView {
+	if layout == "" {
+		layout = IsoDateFmt
+	}
+	return DexView{Dex: e, Layout: layout}
+}
+
+// MD returns Dex.MD using the view Layout.
+func (v DexView) MD() string { return v.Dex.buildString(v.WriteMD) }
+
+// WriteMD writes MD to w one entry at a time.
+func (v DexView) WriteMD(w io.Writer) error {
+	return v.Dex.writeLines(w, func(e DexEntry) string { return e.md(v.Layout) })
+}
+
+// TSV returns Dex.TSV using the view Layout.
+func (v DexView) TSV() string { return v.Dex.buildString(v.WriteTSV) }
+
+// WriteTSV writes TSV to w one entry at a time.
+func (v DexView) WriteTSV(w io.Writer) error {
+	return v.Dex.writeLines(w, func(e DexEntry) string { return e.tsv(v.Layout) })
+}
+
+// String fulfills the fmt.Stringer interface as TSV.
+func (v DexView) String() string { return v.TSV() }
+
+// MarshalJSON returns Dex.MarshalJSON using the view Layout.
+func (v DexView) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, 0))
+	if err := v.WriteJSON(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteJSON writes the same JSON text as MarshalJSON to w one entry at
+// a time.
+func (v DexView) WriteJSON(w io.Writer) error { return v.Dex.writeJSON(w, v.Layout) }
+
+// YAML returns Dex.YAML using the view Layout.
+func (v DexView) YAML() string { return v.Dex.yaml(v.Layout) }
+
+// WriteCSV writes Dex.WriteCSV using the view Layout.
+func (v DexView) WriteCSV(w io.Writer) error { return v.Dex.writeCSV(w, v.Layout) }
+
+// CSV returns WriteCSV as a string.
+func (v DexView) CSV() string { return v.Dex.buildString(v.WriteCSV) }
@@ -0,0 +1,37 @@
+package keg_test
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleDex_WithTimeLayout() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{{U: date, N: 2, T: `Some title`}}
+	view := dex.WithTimeLayout(keg.RFC3339Fmt)
+	fmt.Print(view.TSV())
+	fmt.Print(view.MD())
+	byt, _ := view.MarshalJSON()
+	fmt.Println(string(byt))
+	fmt.Print(dex.WithTimeLayout("").TSV())
+	// Output:
+	// 2	2022-12-10T06:10:04Z	Some title
+	// * 2022-12-10T06:10:04Z [Some title](/2)
+	// [{"U":"2022-12-10T06:10:04Z","N":2,"T":"Some title"}]
+	// 2	2022-12-10 06:10:04Z	Some title
+}
+
+func ExampleDex_WithTimeLayout_parsed() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{{U: date, N: 2, T: `Some title`}}
+	tsv, _ := keg.ParseDexTSV(
+		strings.NewReader(dex.WithTimeLayout(keg.RFC3339Fmt).TSV()))
+	md, _ := keg.ParseDexMD(
+		strings.NewReader(dex.WithTimeLayout(keg.RFC3339Fmt).MD()))
+	fmt.Println(tsv.Equal(dex), md.Equal(dex))
+	// Output:
+	// true true
+}
@@ -0,0 +1,337 @@
+package keg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Link is a single link from the README.md of one node to another
+// node within the same keg or, when Keg is set, another keg (see
+// KegLinkExp).
+type Link struct {
+	From int    // node id containing the link
+	To   int    // node id linked to
+	Line int    // line number within From README.md
+	Keg  string // name of the other keg linked to (empty if same keg)
+}
+
+// NodeLinkExp matches the target of a Markdown link to another node in
+// the same keg, either root relative (/N) or relative to the node
+// (../N), with an optional trailing slash and link title, capturing the
+// node ID. Since only the target is matched the link text may contain
+// anything (including parentheses).
+var NodeLinkExp = regexp.MustCompile(`\]\((?:\.\.)?/(\d+)/?(?:\s+"[^"]*")?\)`)
+
+// KegLinkExp matches the target of a Markdown link to a node in another
+// keg by name, either keg:name/N or /@name/N, capturing the name (of
+// either form) and the node ID. See ResolveLink.
+var KegLinkExp = regexp.MustCompile(`\]\((?:keg:([\w.-]+)|/@([\w.-]+))/(\d+)/?(?:\s+"[^"]*")?\)`)
+
+// anyLinkExp matches either NodeLinkExp or KegLinkExp capturing the keg
+// name (if any) and the node ID so that links come in line order.
+var anyLinkExp = regexp.MustCompile(`\]\((?:(?:keg:|/@)([\w.-]+)/|(?:\.\.)?/)(\d+)/?(?:\s+"[^"]*")?\)`)
+
+// ParseLinks returns the IDs of the nodes linked to (see NodeLinkExp)
+// by the KEGML read from r along with the line number of each. Links
+// within fenced code blocks are ignored. See ParseKegLinks for links to
+// other kegs as well.
+func ParseLinks(r io.Reader) (ids, lines []int, err error) {
+	links, err := ParseKegLinks(r)
+	for _, l := range links {
+		if l.Keg == "" {
+			ids = append(ids, l.To)
+			lines = append(lines, l.Line)
+		}
+	}
+	return ids, lines, err
+}
+
+// ParseKegLinks returns every link (with From left 0) to another node
+// by the KEGML read from r, both within the same keg (see NodeLinkExp)
+// and to other kegs (see KegLinkExp), in line order. Links within
+// fenced code blocks are ignored.
+func ParseKegLinks(r io.Reader) ([]Link, error) {
+	var links []Link
+	s := bufio.NewScanner(r)
+	var fence string
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		trimmed := strings.TrimSpace(text)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) &&
+				strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if f := fenceOpen(trimmed); f != "" {
+			fence = f
+			continue
+		}
+		for _, m := range anyLinkExp.FindAllStringSubmatch(text, -1) {
+			id, err := strconv.Atoi(m[2])
+			if err != nil {
+				continue
+			}
+			links = append(links, Link{To: id, Line: line, Keg: m[1]})
+		}
+	}
+	return links, s.Err()
+}
+
+// fenceOpen returns the fence token (three or more backticks or
+// tildes) if line begins a fenced block or an empty string if not.
+func fenceOpen(line string) string {
+	for _, c := range []string{"`", "~"} {
+		n := len(line) - len(strings.TrimLeft(line, c))
+		if n >= 3 {
+			return strings.Repeat(c, n)
+		}
+	}
+	return ""
+}
+
+// Links returns every link (see ParseLinks) from every node README.md
+// in the keg in node and line order. Nodes without a README.md have no
+// links.
+func (k *Keg) Links() ([]Link, error) {
+	links, _, err := k.links()
+	return links, err
+}
+
+// links returns Links as well as the set of every node ID that has
+// a directory so that both can come from the same pass.
+func (k *Keg) links() ([]Link, map[int]bool, error) {
+	var links []Link
+	nodes := map[int]bool{}
+	dirs, _, _ := NodePaths(k.Local.Path)
+	for _, d := range dirs {
+		name := filepath.Base(d.Path)
+		id, err := strconv.Atoi(name)
+		if err != nil || strconv.Itoa(id) != name {
+			continue
+		}
+		nodes[id] = true
+		f, err := os.Open(filepath.Join(d.Path, `README.md`))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, nil, err
+		}
+		found, err := ParseKegLinks(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, l := range found {
+			if l.Keg == k.Local.Name {
+				l.Keg = "" // by name but the same keg
+			}
+			l.From = id
+			links = append(links, l)
+		}
+	}
+	sort.SliceStable(links, func(i, j int) bool { return links[i].From < links[j].From })
+	return links, nodes, nil
+}
+
+// LinkGraph returns the forward links of every node in the keg that
+// links to at least one other (sorted and without duplicates). Links
+// a node makes to itself are included. Links to other kegs are not.
+func (k *Keg) LinkGraph() (map[int][]int, error) {
+	links, err := k.Links()
+	if err != nil {
+		return nil, err
+	}
+	return linkGraph(links), nil
+}
+
+func linkGraph(links []Link) map[int][]int {
+	graph := map[int][]int{}
+	seen := map[[2]int]bool{}
+	for _, l := range links {
+		if l.Keg != "" || seen[[2]int{l.From, l.To}] {
+			continue
+		}
+		seen[[2]int{l.From, l.To}] = true
+		graph[l.From] = append(graph[l.From], l.To)
+	}
+	for _, to := range graph {
+		sort.Ints(to)
+	}
+	return graph
+}
+
+// Backlinks returns the entries (from the index, see Dex, or read from
+// the node directory if not yet indexed) of every other node that links
+// to the node with id sorted ByID.
+func (k *Keg) Backlinks(id int) (Dex, error) {
+	links, err := k.Links()
+	if err != nil {
+		return nil, err
+	}
+	dex, err := k.Dex()
+	if dex == nil {
+		return nil, err
+	}
+	index := dex.Map()
+	from := Dex{}
+	seen := map[int]bool{}
+	for _, l := range links {
+		if l.Keg != "" || l.To != id || l.From == id || seen[l.From] {
+			continue
+		}
+		seen[l.From] = true
+		if e, has := index[l.From]; has {
+			from = append(from, *e)
+			continue
+		}
+		e, _ := scanNode(k.Path(l.From)) // not yet indexed
+		from = append(from, e)
+	}
+	return from.ByID(), nil
+}
+
+// BrokenLink is a link to a node that has no directory in the keg (or
+// in the other local keg linked to, or to a keg that is not known at
+// all).
+type BrokenLink struct {
+	From int    `json:"from"`          // node id containing the link
+	Line int    `json:"line"`          // line number within From README.md
+	To   int    `json:"to"`            // missing node id
+	Keg  string `json:"keg,omitempty"` // name of other keg (see Link)
+}
+
+func (b BrokenLink) String() string {
+	if b.Keg != "" {
+		return fmt.Sprintf("%v:%v: broken link to keg:%v/%v", b.From, b.Line, b.Keg, b.To)
+	}
+	return fmt.Sprintf("%v:%v: broken link to %v", b.From, b.Line, b.To)
+}
+
+// Orphans returns the entries (see Backlinks) of every node not linked
+// to by any other node sorted ByID. The zero node is never an orphan.
+func (k *Keg) Orphans() (Dex, error) {
+	orphans, _, err := k.linkReport()
+	return orphans, err
+}
+
+// BrokenLinks returns every link to a node ID without a node directory
+// in node and line order. Links to other local kegs (see Locals) are
+// broken if the node directory is not in that keg and links to kegs
+// neither in Locals nor Remotes are always broken. Links to Remotes are
+// not checked (nor any other kegs if there is no conf map at all).
+func (k *Keg) BrokenLinks() ([]BrokenLink, error) {
+	_, broken, err := k.linkReport()
+	return broken, err
+}
+
+// linkReport returns both Orphans and BrokenLinks from a single pass
+// over the keg (see links).
+func (k *Keg) linkReport() (Dex, []BrokenLink, error) {
+	links, nodes, err := k.links()
+	if err != nil {
+		return nil, nil, err
+	}
+	linked := map[int]bool{}
+	var broken []BrokenLink
+	var others map[string]string
+	for _, l := range links {
+		if l.Keg != "" {
+			if others == nil {
+				others = otherKegs()
+			}
+			path, known := others[l.Keg]
+			if len(others) > 0 && (!known || path != "" && !isDir(filepath.Join(path, strconv.Itoa(l.To)))) {
+				broken = append(broken, BrokenLink{From: l.From, Line: l.Line, To: l.To, Keg: l.Keg})
+			}
+			continue
+		}
+		if l.From != l.To {
+			linked[l.To] = true
+		}
+		if !nodes[l.To] {
+			broken = append(broken, BrokenLink{From: l.From, Line: l.Line, To: l.To})
+		}
+	}
+	dex, err := k.Dex()
+	if dex == nil {
+		return nil, nil, err
+	}
+	index := dex.Map()
+	orphans := Dex{}
+	for id := range nodes {
+		if id == 0 || linked[id] {
+			continue
+		}
+		if e, has := index[id]; has {
+			orphans = append(orphans, *e)
+			continue
+		}
+		e, _ := scanNode(k.Path(id)) // not yet indexed
+		orphans = append(orphans, e)
+	}
+	return orphans.ByID(), broken, nil
+}
+
+// otherKegs returns the path of every local keg and an empty path for
+// every remote keg by name (see BrokenLinks) or an empty (non-nil) map
+// if they cannot be looked up.
+func otherKegs() map[string]string {
+	others := map[string]string{}
+	locals, err := Locals()
+	if err != nil {
+		return others
+	}
+	remotes, err := Remotes()
+	if err != nil {
+		return others
+	}
+	for _, l := range locals {
+		others[l.Name] = l.Path
+	}
+	for _, r := range remotes {
+		others[r.Name] = ""
+	}
+	return others
+}
+
+// KegBacklinks returns every link (see Link) to the node with id from
+// each of the other local kegs (see Locals) that link to this one by
+// name, keyed by the name of the other keg. Kegs that cannot be opened
+// are skipped.
+func (k *Keg) KegBacklinks(id int) (map[string][]Link, error) {
+	locals, err := Locals()
+	if err != nil {
+		return nil, err
+	}
+	back := map[string][]Link{}
+	for _, l := range locals {
+		if l.Missing || l.Path == k.Local.Path {
+			continue
+		}
+		other, err := Open(l.Path)
+		if err != nil {
+			continue
+		}
+		other.Local.Name = l.Name
+		links, _, err := other.links()
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range links {
+			if link.Keg == k.Local.Name && link.To == id {
+				back[l.Name] = append(back[l.Name], link)
+			}
+		}
+	}
+	return back, nil
+}
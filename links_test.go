@@ -0,0 +1,83 @@
+package keg_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleParseLinks() {
+	in := "# Links\n\n" +
+		"See [Using (parens) in titles](/3) and [this](../4/).\n" +
+		"* [Included](/5 \"with (a) title\")\n" +
+		"```go\n" +
+		"// [Not a link](/6)\n" +
+		"```\n" +
+		"Not [external](https://example.com/7) nor [missing]( /8)\n" +
+		"~~~~\n[Still code](/9)\n~~~\n~~~~\n" +
+		"[Last](/10)\n"
+	ids, lines, err := keg.ParseLinks(strings.NewReader(in))
+	fmt.Println(ids, lines, err)
+	// Output:
+	// [3 4 5 10] [3 3 4 13] <nil>
+}
+
+func TestKeg_Backlinks(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Zero\n", old)
+	mkNode(t, root, `1`, "# One\n\n* [Two](/2)\n* [Three](/3)\n* [Two again](/2)\n", old)
+	mkNode(t, root, `2`, "# Two\n\nBack to [one](../1) and [me](/2).\n", old)
+	mkNode(t, root, `3`, "# Three\n\nSee [two](/2).\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	mkNode(t, root, `4`, "# Four (not indexed)\n\n[Two](/2)\n", old)
+	k, _ := keg.Open(root)
+
+	graph, err := k.LinkGraph()
+	want := map[int][]int{1: {2, 3}, 2: {1, 2}, 3: {2}, 4: {2}}
+	if err != nil || !reflect.DeepEqual(graph, want) {
+		t.Errorf("got %v (%v) want %v", graph, err, want)
+	}
+
+	back, err := k.Backlinks(2)
+	if err != nil || !reflect.DeepEqual(back.IDs(), []int{1, 3, 4}) ||
+		back[2].T != `Four (not indexed)` {
+		t.Errorf("unexpected backlinks (%v):\n%v", err, back)
+	}
+	if back, _ := k.Backlinks(0); len(back) != 0 {
+		t.Errorf("expected no backlinks to 0, got %v", back)
+	}
+}
+
+func TestKeg_Orphans(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Zero\n", old)
+	mkNode(t, root, `1`, "# One\n\n* [Two](/2)\n* [Gone](/9)\n", old)
+	mkNode(t, root, `2`, "# Two\n\nOnly [me](/2).\n\n```\n[Not a link](/8)\n```\n\n[Gone](../7)\n", old)
+	mkNode(t, root, `3`, "# Three\n\nSee [zero](/0).\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	k, _ := keg.Open(root)
+
+	orphans, err := k.Orphans()
+	if err != nil || !reflect.DeepEqual(orphans.IDs(), []int{1, 3}) {
+		t.Errorf("unexpected orphans (%v):\n%v", err, orphans)
+	}
+
+	broken, err := k.BrokenLinks()
+	want := []keg.BrokenLink{{From: 1, Line: 4, To: 9}, {From: 2, Line: 9, To: 7}}
+	if err != nil || !reflect.DeepEqual(broken, want) {
+		t.Errorf("got %v (%v) want %v", broken, err, want)
+	}
+	if broken[0].String() != `1:4: broken link to 9` {
+		t.Errorf("unexpected string: %v", broken[0])
+	}
+}
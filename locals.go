@@ -0,0 +1,180 @@
+package keg
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	Z "github.com/rwxrob/bonzai/z"
+	"github.com/rwxrob/fs"
+	"gopkg.in/yaml.v3"
+)
+
+// Locals returns every named local keg from the map section of the keg
+// configuration (Z.Conf) sorted by name. The map is looked for at the
+// top of the configuration (keg as the root command) or within the keg
+// section (keg composed into another command tree). Paths have ~ and
+// environment variables expanded. Kegs whose directories do not exist
+// are still returned but have Missing set. An empty slice is returned
+// if there is no map. Entries with URLs are Remotes instead.
+func Locals() ([]Local, error) {
+	m, err := confMap()
+	if err != nil {
+		return nil, err
+	}
+	locals := make([]Local, 0, len(m))
+	for name, path := range m {
+		if schemeExp.MatchString(path) {
+			continue // see Remotes
+		}
+		l := Local{Name: name, Path: fs.Tilde2Home(os.ExpandEnv(path))}
+		l.Missing = !isDir(l.Path)
+		locals = append(locals, l)
+	}
+	sort.Slice(locals, func(i, j int) bool { return locals[i].Name < locals[j].Name })
+	return locals, nil
+}
+
+// schemeExp matches the start of an absolute URL (scheme://).
+var schemeExp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://`)
+
+// confMap returns the map section of the keg configuration (see
+// Locals) or an empty map if there is none.
+func confMap() (map[string]string, error) {
+	if Z.Conf == nil {
+		return nil, fmt.Errorf("no configuration (Z.Conf) available")
+	}
+	data, err := Z.Conf.Data()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	var conf struct {
+		Map map[string]string `yaml:"map"`
+		Keg struct {
+			Map map[string]string `yaml:"map"`
+		} `yaml:"keg"`
+	}
+	if err := yaml.Unmarshal([]byte(data), &conf); err != nil {
+		return nil, err
+	}
+	if conf.Map != nil {
+		return conf.Map, nil
+	}
+	if conf.Keg.Map != nil {
+		return conf.Keg.Map, nil
+	}
+	return map[string]string{}, nil
+}
+
+// Remotes returns every named remote keg from the same conf map as
+// Locals (those with a URL, scheme://, instead of a path) sorted by
+// name. See FetchDex.
+func Remotes() ([]Remote, error) {
+	m, err := confMap()
+	if err != nil {
+		return nil, err
+	}
+	remotes := make([]Remote, 0, len(m))
+	for name, url := range m {
+		if schemeExp.MatchString(url) {
+			remotes = append(remotes, Remote{Name: name, URL: os.ExpandEnv(url)})
+		}
+	}
+	sort.Slice(remotes, func(i, j int) bool { return remotes[i].Name < remotes[j].Name })
+	return remotes, nil
+}
+
+// LookupRemote returns the remote keg with name from Remotes or
+// RemoteNotFound if there is none.
+func LookupRemote(name string) (*Remote, error) {
+	remotes, err := Remotes()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range remotes {
+		if r.Name == name {
+			return &r, nil
+		}
+	}
+	return nil, RemoteNotFound{name}
+}
+
+// LookupLocal returns the local keg with name from Locals or
+// LocalNotFound if there is none. A Missing Local is still returned.
+func LookupLocal(name string) (*Local, error) {
+	locals, err := Locals()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range locals {
+		if l.Name == name {
+			return &l, nil
+		}
+	}
+	return nil, LocalNotFound{name}
+}
+
+// AddLocal adds the local keg name with path to the same conf map as
+// Locals keeping everything else in the configuration (Z.Conf). The map
+// is added to the top of the configuration unless the keg section
+// already has one. Nothing is written if name already has the same path
+// but LocalExists is returned if it has another.
+func AddLocal(name, path string) error {
+	if Z.Conf == nil {
+		return fmt.Errorf("no configuration (Z.Conf) available")
+	}
+	data, err := Z.Conf.Data()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("invalid conf: not a mapping")
+	}
+	m := mappingValue(root, `map`)
+	if m == nil {
+		if k := mappingValue(root, `keg`); k != nil && k.Kind == yaml.MappingNode {
+			m = mappingValue(k, `map`)
+		}
+	}
+	if m == nil {
+		m = &yaml.Node{Kind: yaml.MappingNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: `map`}, m)
+	}
+	if m.Kind != yaml.MappingNode {
+		return fmt.Errorf("invalid conf: map is not a mapping")
+	}
+	if v := mappingValue(m, name); v != nil {
+		if v.Value == path {
+			return nil
+		}
+		return LocalExists{name, v.Value}
+	}
+	m.Content = append(m.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: name},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: path},
+	)
+	return Z.Conf.OverWrite(&doc)
+}
+
+// mappingValue returns the value of key within the YAML mapping m or nil
+// if it has none.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
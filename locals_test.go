@@ -0,0 +1,98 @@
+package keg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	Z "github.com/rwxrob/bonzai/z"
+	"github.com/rwxrob/conf"
+	"github.com/rwxrob/keg"
+)
+
+// useConf points Z.Conf at a temporary conf file with the YAML content
+// passed for the rest of the test.
+func useConf(t *testing.T, content string) {
+	t.Helper()
+	c := conf.C{Id: `keg`, Dir: t.TempDir(), File: `config.yaml`}
+	if err := os.MkdirAll(c.DirPath(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.Path(), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	orig := Z.Conf
+	Z.Conf = c
+	t.Cleanup(func() { Z.Conf = orig })
+}
+
+func TestLocals(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	sample, _ := filepath.Abs(`testdata/samplekeg`)
+	t.Setenv(`KEG_TEST_DIR`, sample)
+	useConf(t, "map:\n"+
+		"  sample: $KEG_TEST_DIR\n"+
+		"  gone: ~/no/such/keg\n")
+
+	locals, err := keg.Locals()
+	want := []keg.Local{
+		{Name: `gone`, Path: filepath.Join(home, `no/such/keg`), Missing: true},
+		{Name: `sample`, Path: sample},
+	}
+	if err != nil || len(locals) != 2 || locals[0] != want[0] || locals[1] != want[1] {
+		t.Errorf("got %v (%v) want %v", locals, err, want)
+	}
+
+	l, err := keg.LookupLocal(`sample`)
+	if err != nil || l.Path != sample {
+		t.Errorf("unexpected lookup: %v (%v)", l, err)
+	}
+	var notfound keg.LocalNotFound
+	if _, err := keg.LookupLocal(`nope`); !errors.As(err, &notfound) || notfound.Name != `nope` {
+		t.Errorf("expected LocalNotFound, got %v", err)
+	}
+}
+
+func TestLocals_composed(t *testing.T) {
+	useConf(t, "keg:\n  map:\n    sample: testdata/samplekeg\n")
+	locals, err := keg.Locals()
+	if err != nil || len(locals) != 1 || locals[0].Name != `sample` || locals[0].Missing {
+		t.Errorf("unexpected locals: %v (%v)", locals, err)
+	}
+	useConf(t, "other: thing\n")
+	if locals, err := keg.Locals(); err != nil || len(locals) != 0 {
+		t.Errorf("expected no locals, got %v (%v)", locals, err)
+	}
+}
+
+func TestAddLocal(t *testing.T) {
+	useConf(t, "# mine\nother: thing\nkeg:\n  map:\n    sample: testdata/samplekeg\n")
+	if err := keg.AddLocal(`new`, `/tmp/new`); err != nil {
+		t.Fatal(err)
+	}
+	locals, err := keg.Locals()
+	if err != nil || len(locals) != 2 || locals[0].Name != `new` || locals[0].Path != `/tmp/new` {
+		t.Errorf("unexpected locals: %v (%v)", locals, err)
+	}
+	data, _ := Z.Conf.Data()
+	if !strings.Contains(data, `other: thing`) || !strings.Contains(data, `# mine`) {
+		t.Errorf("expected rest of conf kept:\n%v", data)
+	}
+	if err := keg.AddLocal(`new`, `/tmp/new`); err != nil {
+		t.Errorf("expected same path to be fine, got %v", err)
+	}
+	var exists keg.LocalExists
+	if err := keg.AddLocal(`new`, `/tmp/other`); !errors.As(err, &exists) || exists.Path != `/tmp/new` {
+		t.Errorf("expected LocalExists, got %v", err)
+	}
+
+	useConf(t, "")
+	if err := keg.AddLocal(`first`, `/tmp/first`); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := keg.LookupLocal(`first`); err != nil || l.Path != `/tmp/first` {
+		t.Errorf("unexpected lookup: %v (%v)", l, err)
+	}
+}
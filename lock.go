@@ -0,0 +1,69 @@
+package keg
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DexLockTimeout is how long UpdateDex, MakeNode, DeleteNode (and the
+// others that write the dex files) wait for the dex lock (see LockDex)
+// before giving up with ErrLocked.
+var DexLockTimeout = 10 * time.Second
+
+// DexLockStale is how old a dex/.lock file must be to be considered
+// left behind by a process that died holding it on systems without
+// flock (those with it release the lock when the process exits).
+var DexLockStale = 2 * time.Minute
+
+// LockDex acquires the advisory lock on the dex files of the keg at
+// kegpath (dex/.lock), creating the dex directory if needed, and
+// returns the function to release it. Waits up to timeout for another
+// holder (process or goroutine) to release it and then returns
+// ErrLocked. The lock is only advisory: WriteDex does not take it since
+// callers that read and then write the dex must hold it for both.
+func LockDex(kegpath string, timeout time.Duration) (unlock func() error, err error) {
+	dexdir := filepath.Join(kegpath, `dex`)
+	if err := os.MkdirAll(dexdir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dexdir, `.lock`)
+	deadline := time.Now().Add(timeout)
+	for {
+		unlock, held, err := tryLock(path)
+		if err != nil {
+			return nil, err
+		}
+		if held {
+			return unlock, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLocked{Path: path, PID: lockHolder(path)}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// withDexLock calls do while holding the dex lock of the keg at kegpath
+// (see LockDex and DexLockTimeout).
+func withDexLock(kegpath string, do func() error) error {
+	unlock, err := LockDex(kegpath, DexLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return do()
+}
+
+// lockHolder returns the process ID written to the lock file at path
+// by its holder or 0 if unknown.
+func lockHolder(path string) int {
+	byt, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(byt)))
+	return pid
+}
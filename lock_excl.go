@@ -0,0 +1,35 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd || dragonfly)
+
+package keg
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// tryLock makes one attempt to create the lock file at path
+// exclusively (O_EXCL) removing it first if older than DexLockStale.
+func tryLock(path string) (func() error, bool, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if !os.IsExist(err) {
+			return nil, false, err
+		}
+		if info, err := os.Stat(path); err == nil &&
+			time.Since(info.ModTime()) > DexLockStale {
+			os.Remove(path) // holder died without unlocking
+		}
+		return nil, false, nil
+	}
+	if _, err := f.WriteString(strconv.Itoa(os.Getpid()) + "\n"); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, false, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, false, err
+	}
+	return func() error { return os.Remove(path) }, true, nil
+}
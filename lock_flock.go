@@ -0,0 +1,49 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package keg
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// tryLock makes one attempt to take an exclusive flock on the file at
+// path (created if needed) without blocking. Since the file is removed
+// on unlock the file locked must still be the one at path or it is
+// tried again.
+func tryLock(path string) (func() error, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	locked, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	if current, err := os.Stat(path); err != nil || !os.SameFile(locked, current) {
+		f.Close()
+		return nil, false, nil // removed by previous holder
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	if _, err := f.WriteString(strconv.Itoa(os.Getpid()) + "\n"); err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	return func() error {
+		os.Remove(path)
+		return f.Close()
+	}, true, nil
+}
@@ -0,0 +1,87 @@
+package keg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestLockDex(t *testing.T) {
+	root := t.TempDir()
+	unlock, err := keg.LockDex(root, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// contention from another goroutine times out with the holder
+	var locked keg.ErrLocked
+	done := make(chan error)
+	go func() {
+		_, err := keg.LockDex(root, 50*time.Millisecond)
+		done <- err
+	}()
+	if err := <-done; !errors.As(err, &locked) || locked.PID != os.Getpid() {
+		t.Errorf("expected ErrLocked with our PID, got %v", err)
+	}
+
+	// and succeeds once released
+	go func() {
+		unlock, err := keg.LockDex(root, 5*time.Second)
+		if err == nil {
+			err = unlock()
+		}
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("lock not acquired after release: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, `dex`, `.lock`)); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestLockDex_writers(t *testing.T) {
+	root := t.TempDir()
+	k := &keg.Keg{Local: keg.Local{Path: root}}
+	mkNode(t, root, `0`, "# Zero\n", time.Now())
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// concurrent node creation and updates never lose an entry
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := k.MakeNode(`Same title`)
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := keg.UpdateDex(root)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	dex, err := keg.ReadDexTSV(filepath.Join(root, `dex`, `nodes.tsv`))
+	if err != nil || len(dex) != 11 || dex.Highest() != 10 {
+		t.Errorf("lost entries: %v (%v)", dex, err)
+	}
+}
@@ -0,0 +1,117 @@
+package keg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MetaPath returns the full path to the optional meta file (YAML) of
+// the node (see Meta).
+func (n *Node) MetaPath() string { return filepath.Join(n.Dir, `meta`) }
+
+// Meta returns the structured data (aliases, created, source, and so
+// on) from the meta file of the node (a YAML mapping). An empty map is
+// returned if there is no meta file (or it is empty). Since the meta
+// file is within the node directory, changing it changes the node (see
+// NodeChanged).
+func (n *Node) Meta() (map[string]any, error) {
+	doc, err := n.readMeta()
+	if err != nil {
+		return nil, err
+	}
+	meta := map[string]any{}
+	if len(doc.Content) == 0 {
+		return meta, nil
+	}
+	if err := doc.Content[0].Decode(&meta); err != nil {
+		return nil, fmt.Errorf("invalid meta: %v: %w", n.ID, err)
+	}
+	return meta, nil
+}
+
+// SetMeta sets the value of key in the meta file of the node (see Meta)
+// creating it if needed. Other keys (and their order and any comments)
+// are kept and a new key is added at the end.
+func (n *Node) SetMeta(key string, val any) error {
+	doc, err := n.readMeta()
+	if err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+	}
+	var v yaml.Node
+	if err := v.Encode(val); err != nil {
+		return err
+	}
+	m := doc.Content[0]
+	set := false
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = &v
+			set = true
+			break
+		}
+	}
+	if !set {
+		m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &v)
+	}
+	return n.writeMeta(doc)
+}
+
+func (n *Node) writeMeta(doc yaml.Node) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	enc.Close()
+	return writeFileAtomic(n.MetaPath(), buf.String())
+}
+
+// unsetMeta removes key from the meta file of the node (if there) and
+// removes the meta file itself if no keys remain.
+func (n *Node) unsetMeta(key string) error {
+	doc, err := n.readMeta()
+	if err != nil || len(doc.Content) == 0 {
+		return err
+	}
+	m := doc.Content[0]
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			break
+		}
+	}
+	if len(m.Content) == 0 {
+		return os.Remove(n.MetaPath())
+	}
+	return n.writeMeta(doc)
+}
+
+// readMeta returns the parsed meta file (an empty document if there is
+// none) or an error if it is not a YAML mapping.
+func (n *Node) readMeta() (yaml.Node, error) {
+	var doc yaml.Node
+	byt, err := os.ReadFile(n.MetaPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return doc, nil
+		}
+		return doc, err
+	}
+	if err := yaml.Unmarshal(byt, &doc); err != nil {
+		return doc, fmt.Errorf("invalid meta: %v: %w", n.ID, err)
+	}
+	if len(doc.Content) > 0 && doc.Content[0].Kind != yaml.MappingNode {
+		return doc, fmt.Errorf("invalid meta: %v: not a mapping", n.ID)
+	}
+	return doc, nil
+}
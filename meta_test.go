@@ -0,0 +1,68 @@
+package keg_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestNode_Meta(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `1`, "# One\n", old)
+	mkNode(t, root, `2`, "# Two\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	k, _ := keg.Open(root)
+	node, _ := k.Node(1)
+
+	meta, err := node.Meta()
+	if err != nil || meta == nil || len(meta) != 0 {
+		t.Errorf("expected empty meta, got %v (%v)", meta, err)
+	}
+
+	orig := "# from the web\nsource: https://example.com\nzebra: last\naliases:\n  - uno\n"
+	if err := os.WriteFile(node.MetaPath(), []byte(orig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.SetMeta(`aliases`, []string{`uno`, `one`}); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.SetMeta(`created`, `2022-12-10`); err != nil {
+		t.Fatal(err)
+	}
+	byt, _ := os.ReadFile(node.MetaPath())
+	want := "# from the web\nsource: https://example.com\nzebra: last\n" +
+		"aliases:\n  - uno\n  - one\ncreated: \"2022-12-10\"\n"
+	if string(byt) != want {
+		t.Errorf("got:\n%s\nwant:\n%v", byt, want)
+	}
+	meta, err = node.Meta()
+	wantmeta := map[string]any{
+		`source`: `https://example.com`, `zebra`: `last`,
+		`aliases`: []any{`uno`, `one`}, `created`: `2022-12-10`,
+	}
+	if err != nil || !reflect.DeepEqual(meta, wantmeta) {
+		t.Errorf("got %v (%v) want %v", meta, err, wantmeta)
+	}
+
+	if changed, _ := keg.NodeChanged(node.Dir); !changed.After(old) {
+		t.Errorf("expected meta change to change node: %v", changed)
+	}
+
+	bad, _ := k.Node(2)
+	if err := os.WriteFile(bad.MetaPath(), []byte("- not\n- a map\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bad.Meta(); err == nil {
+		t.Error("expected error for meta that is not a mapping")
+	}
+	r, err := k.Check()
+	if err != nil || !reflect.DeepEqual(r.BadMeta, []int{2}) || r.OK() {
+		t.Errorf("expected invalid meta reported: %v (%v)", r.BadMeta, err)
+	}
+}
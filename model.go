@@ -2,32 +2,80 @@ package keg
 
 import (
 	"bytes"
+	"encoding/csv"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/rwxrob/choose"
 	"github.com/rwxrob/json"
 	"github.com/rwxrob/term"
 )
 
+// IsoDateFmt is the time layout used by all dex file formats. Since it
+// ends in a literal Z every renderer converts times to UTC before
+// formatting with it. See Dex.WithTimeLayout to render with another.
 const IsoDateFmt = `2006-01-02 15:04:05Z`
 const IsoDateExpStr = `\d\d\d\d-\d\d-\d\d \d\d:\d\d:\d\dZ`
 
+// PrettyDateFmt is the shorter time layout (without seconds) used by
+// Pretty and PrettyLines.
+const PrettyDateFmt = `2006-01-02 15:04Z`
+
+// TimeLayouts are the time layouts (in order) accepted by ParseTime.
+var TimeLayouts = []string{
+	IsoDateFmt,
+	time.RFC3339,
+	`2006-01-02 15:04Z07:00`,
+	`2006-01-02T15:04Z07:00`,
+	`2006-01-02 15:04:05Z07:00`,
+	`2006-01-02`,
+}
+
+// ParseTime leniently parses a time stamp as written by hand into any
+// of the dex files trying each of the TimeLayouts (IsoDateFmt, RFC3339,
+// either without seconds, and date-only) and returns it as UTC. Returns
+// an error only if none of them match.
+func ParseTime(s string) (time.Time, error) {
+	for _, layout := range TimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time: %q", s)
+}
+
 // Local contains a name to full path mapping for kegs stored locally.
 type Local struct {
+	Name    string
+	Path    string
+	Missing bool // directory does not exist (see Locals)
+}
+
+// Remote contains a name to URL mapping for kegs published on the web
+// (see Remotes and FetchDex).
+type Remote struct {
 	Name string
-	Path string
+	URL  string // base URL of the keg (containing dex/)
 }
 
 // DexEntry represents a single line in an index (usually the latest.md
-// or nodes.tsv file). All three fields are always required.
+// or nodes.tsv file). The U, T, and N fields are always required. K is
+// optional and only used to distinguish entries from different kegs
+// when combining them (see Merge). K is never serialized.
 type DexEntry struct {
 	U time.Time // updated
 	T string    // title
 	N int       // node id (also see ID)
+	K string    `json:"-"` // keg name (optional)
 }
 
 // MarshalJSON produces JSON text that contains one DexEntry per line
@@ -35,17 +83,66 @@ type DexEntry struct {
 // a consistent DateTime format. Note that the (broken) encoding/json
 // encoder is not used at all.
 func (e *DexEntry) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(IsoDateFmt), nil
+}
+
+func (e DexEntry) marshalJSON(layout string) []byte {
 	buf := bytes.NewBuffer(make([]byte, 0, 0))
 	buf.WriteRune('{')
-	buf.WriteString(`"U":"` + e.U.Format(IsoDateFmt) + `",`)
+	buf.WriteString(`"U":"` + e.U.UTC().Format(layout) + `",`)
 	buf.WriteString(`"N":` + strconv.Itoa(e.N) + `,`)
 	buf.WriteString(`"T":"` + json.Escape(e.T) + `"`)
 	buf.WriteRune('}')
-	return buf.Bytes(), nil
+	return buf.Bytes()
+}
+
+// TSV returns the entry as a single line of tab-separated values for
+// the dex/nodes.tsv file. Any tabs or line returns in the title are
+// replaced with a single space so that they cannot corrupt the file.
+func (e DexEntry) TSV() string { return e.tsv(IsoDateFmt) }
+
+func (e DexEntry) tsv(layout string) string {
+	return fmt.Sprintf("%v\t%v\t%v", e.N, e.U.UTC().Format(layout), tsvSafe.Replace(e.T))
+}
+
+var tsvSafe = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+
+// Validate returns the first problem found with the entry that would
+// corrupt any of the dex files if written (BadID, EmptyTitle,
+// TitleHasControlChars, ZeroTime) or nil if none.
+func (e DexEntry) Validate() error {
+	switch {
+	case e.N < 0:
+		return BadID{e.N}
+	case strings.TrimSpace(e.T) == "":
+		return EmptyTitle{e.N}
+	case strings.IndexFunc(e.T, unicode.IsControl) >= 0:
+		return TitleHasControlChars{e.N, e.T}
+	case e.U.IsZero():
+		return ZeroTime{e.N}
+	}
+	return nil
+}
+
+// YAML returns the entry as a YAML mapping with u, n, and t keys
+// indented to be a single item in a YAML list (see Dex.YAML). The time
+// and title are always double-quoted (and escaped) so that no title
+// can change the meaning of the YAML.
+func (e DexEntry) YAML() string { return e.yaml(IsoDateFmt) }
+
+func (e DexEntry) yaml(layout string) string {
+	return fmt.Sprintf(
+		"- u: \"%v\"\n  n: %v\n  t: \"%v\"\n",
+		e.U.UTC().Format(layout), e.N, json.Escape(e.T),
+	)
 }
 
-func (e DexEntry) TSV() string {
-	return fmt.Sprintf("%v\t%v\t%v", e.N, e.U.Format(IsoDateFmt), e.T)
+// Equal returns true if both entries have the same node ID, title, and
+// update time to the second (since that is all any of the dex formats
+// preserve). K is ignored.
+func (e DexEntry) Equal(other DexEntry) bool {
+	return e.N == other.N && e.T == other.T &&
+		e.U.Truncate(time.Second).Equal(other.U.Truncate(time.Second))
 }
 
 // ID returns the node identifier as a string instead of an integer.
@@ -61,77 +158,269 @@ func (e DexEntry) ID() string { return strconv.Itoa(e.N) }
 //
 // Note that the second of last change is based on *any* file within the
 // node directory changing, not just the README.md or meta files.
-func (e DexEntry) MD() string {
+//
+// Square brackets and backslashes in the title are escaped (see
+// EscapeLinkText) so that the link is never broken.
+func (e DexEntry) MD() string { return e.md(IsoDateFmt) }
+
+func (e DexEntry) md(layout string) string {
 	return fmt.Sprintf(
 		"* %v [%v](/%v)",
-		e.U.Format(IsoDateFmt),
-		e.T, e.N,
+		e.U.UTC().Format(layout),
+		EscapeLinkText(e.T), e.N,
 	)
 }
 
 // String implements fmt.Stringer interface as MD.
 func (e DexEntry) String() string { return e.MD() }
 
-// Asinclude returns a KEGML include link list item without the time
-// suitable for creating include blocks in node files.
-func (e DexEntry) AsInclude() string {
-	return fmt.Sprintf("* [%v](/%v)", e.T, e.N)
+// AsInclude returns a KEGML include link list item without the time
+// suitable for creating include blocks in node files. The title is
+// escaped the same as MD.
+func (e DexEntry) AsInclude() string { return e.AsIncludeWith(`/`) }
+
+// AsIncludeWith returns AsInclude but with the link prefix to use before
+// the node ID instead of the default slash (/). For example, ../ and
+// /otherkeg/ produce (../42) and (/otherkeg/42).
+func (e DexEntry) AsIncludeWith(prefix string) string {
+	return "* " + e.includeLink(prefix)
 }
 
+// includeLink returns the Markdown link to the node with escaped title
+// used by all include list renderers.
+func (e DexEntry) includeLink(prefix string) string {
+	return fmt.Sprintf("[%v](%v%v)", EscapeLinkText(e.T), prefix, e.N)
+}
+
+// EscapeLinkText escapes backslashes and square brackets with
+// a backslash so that the text can be safely used within the square
+// brackets of a Markdown link. Parentheses do not need escaping in
+// link text. See UnescapeLinkText.
+func EscapeLinkText(text string) string { return linkEscaper.Replace(text) }
+
+// UnescapeLinkText reverses EscapeLinkText.
+func UnescapeLinkText(text string) string { return linkUnescaper.Replace(text) }
+
+var linkEscaper = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`)
+var linkUnescaper = strings.NewReplacer(`\\`, `\`, `\[`, `[`, `\]`, `]`)
+
 // Dex is a collection of DexEntry structs. This allows mapping methods
 // for its serialization to different output formats.
 type Dex []DexEntry
 
 // MarshalJSON produces JSON text that contains one DexEntry per line
-// that has not been HTML escaped (unlike the default).
+// that has not been HTML escaped (unlike the default). An empty Dex
+// always produces an empty JSON array ([]), never null. See WriteJSON.
 func (d *Dex) MarshalJSON() ([]byte, error) {
 	buf := bytes.NewBuffer(make([]byte, 0, 0))
-	buf.WriteString("[")
-	for _, entry := range *d {
-		byt, _ := entry.MarshalJSON()
-		buf.Write(byt)
-		buf.WriteString(",\n")
+	if err := d.WriteJSON(buf); err != nil {
+		return nil, err
 	}
-	byt := buf.Bytes()
-	byt[len(byt)-2] = ']'
-	return byt, nil
+	return buf.Bytes(), nil
 }
 
-// String fulfills the fmt.Stringer interface as JSON. Any error returns
-// a "null" string.
-func (e Dex) String() string { return e.TSV() }
+// WriteJSON writes the same JSON text as MarshalJSON to w one entry at
+// a time.
+func (e Dex) WriteJSON(w io.Writer) error { return e.writeJSON(w, IsoDateFmt) }
 
-// MD renders the entire Dex as a Markdown list suitable for the
-// standard dex/latest.md file.
-func (e Dex) MD() string {
-	var str string
-	for _, entry := range e {
-		str += entry.MD() + "\n"
+func (e Dex) writeJSON(w io.Writer, layout string) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for i, entry := range e {
+		if i > 0 {
+			if _, err := io.WriteString(w, ",\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(entry.marshalJSON(layout)); err != nil {
+			return err
+		}
 	}
-	return str
+	_, err := io.WriteString(w, "]")
+	return err
 }
 
+// String fulfills the fmt.Stringer interface as TSV since that is the
+// canonical dex/nodes.tsv content.
+func (e Dex) String() string { return e.TSV() }
+
+// MD renders the entire Dex as a Markdown list suitable for the
+// standard dex/latest.md file. See WriteMD.
+func (e Dex) MD() string { return e.buildString(e.WriteMD) }
+
+// WriteMD writes MD to w one entry at a time.
+func (e Dex) WriteMD(w io.Writer) error { return e.writeLines(w, DexEntry.MD) }
+
 // AsIncludes renders the entire Dex as a KEGML include list (markdown
 // bulleted list) and cab be useful from within editing sessions to
 // include from the current keg without leaving the terminal editor.
-func (e Dex) AsIncludes() string {
-	var str string
-	for _, entry := range e {
-		str += entry.AsInclude() + "\n"
+func (e Dex) AsIncludes() string { return e.AsIncludesWith(`/`) }
+
+// AsIncludesWith returns AsIncludes using the link prefix passed (see
+// DexEntry.AsIncludeWith).
+func (e Dex) AsIncludesWith(prefix string) string {
+	return e.buildString(func(w io.Writer) error {
+		return e.writeLines(w, func(d DexEntry) string {
+			return d.AsIncludeWith(prefix)
+		})
+	})
+}
+
+// AsNumberedIncludes renders the entire Dex as a KEGML ordered include
+// list (1. [Title](/N)) preserving the Dex order and always numbering
+// from 1. Numbers are never padded.
+func (e Dex) AsNumberedIncludes() string {
+	var buf strings.Builder
+	for i, entry := range e {
+		buf.WriteString(strconv.Itoa(i+1) + ". " + entry.includeLink(`/`) + "\n")
 	}
-	return str
+	return buf.String()
 }
 
 // TSV renders the entire Dex as a loadable tab-separated values file.
-func (e Dex) TSV() string {
-	var str string
+// See WriteTSV.
+func (e Dex) TSV() string { return e.buildString(e.WriteTSV) }
+
+// WriteTSV writes TSV to w one entry at a time.
+func (e Dex) WriteTSV(w io.Writer) error { return e.writeLines(w, DexEntry.TSV) }
+
+// writeLines writes the line rendered for each entry to w followed by
+// a line return.
+func (e Dex) writeLines(w io.Writer, line func(DexEntry) string) error {
 	for _, entry := range e {
-		str += entry.TSV() + "\n"
+		if _, err := io.WriteString(w, line(entry)+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildString returns everything written by write as a string. Since
+// strings.Builder never returns an error neither does buildString.
+func (e Dex) buildString(write func(w io.Writer) error) string {
+	var buf strings.Builder
+	buf.Grow(len(e) * 64)
+	write(&buf)
+	return buf.String()
+}
+
+// Validate calls Validate on every entry and returns all problems
+// found together as Errors or nil if there were none.
+func (d Dex) Validate() error {
+	var errs Errors
+	for _, e := range d {
+		if err := e.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// YAML renders the entire Dex as a YAML list of mappings (see
+// DexEntry.YAML). An empty Dex is an empty YAML list ([]). See
+// ParseDexYAML.
+func (e Dex) YAML() string { return e.yaml(IsoDateFmt) }
+
+func (e Dex) yaml(layout string) string {
+	if len(e) == 0 {
+		return "[]\n"
+	}
+	var buf strings.Builder
+	for _, entry := range e {
+		buf.WriteString(entry.yaml(layout))
+	}
+	return buf.String()
+}
+
+// WriteCSV writes the entire Dex to w as comma-separated values (with
+// a header row of id, updated, and title) quoted as needed by
+// encoding/csv. Each row is written as it is rendered.
+func (e Dex) WriteCSV(w io.Writer) error { return e.writeCSV(w, IsoDateFmt) }
+
+func (e Dex) writeCSV(w io.Writer, layout string) error {
+	c := csv.NewWriter(w)
+	if err := c.Write([]string{`id`, `updated`, `title`}); err != nil {
+		return err
+	}
+	for _, entry := range e {
+		err := c.Write([]string{
+			entry.ID(), entry.U.UTC().Format(layout), entry.T,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	c.Flush()
+	return c.Error()
+}
+
+// CSV returns WriteCSV as a string.
+func (e Dex) CSV() string { return e.buildString(e.WriteCSV) }
+
+// Equal returns true if both have the same number of entries and each
+// is Equal to the entry at the same position in other.
+func (d Dex) Equal(other Dex) bool {
+	if len(d) != len(other) {
+		return false
+	}
+	for i := range d {
+		if !d[i].Equal(other[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualSet returns true if both contain the same Equal entries (and the
+// same number of each) in any order.
+func (d Dex) EqualSet(other Dex) bool {
+	if len(d) != len(other) {
+		return false
+	}
+	type key struct {
+		n int
+		t string
+		u int64
+	}
+	count := map[key]int{}
+	for _, e := range d {
+		count[key{e.N, e.T, e.U.Unix()}]++
+	}
+	for _, e := range other {
+		k := key{e.N, e.T, e.U.Unix()}
+		if count[k] == 0 {
+			return false
+		}
+		count[k]--
 	}
-	return str
+	return true
 }
 
-// Highest returns the highest integer value identifier.
+// Titles returns the title of every entry preserving the Dex order.
+func (d Dex) Titles() []string {
+	titles := make([]string, 0, len(d))
+	for _, e := range d {
+		titles = append(titles, e.T)
+	}
+	return titles
+}
+
+// IDs returns the node ID of every entry preserving the Dex order. Use
+// ByID first when sorted IDs are wanted.
+func (d Dex) IDs() []int {
+	ids := make([]int, 0, len(d))
+	for _, e := range d {
+		ids = append(ids, e.N)
+	}
+	return ids
+}
+
+// Highest returns the highest integer value identifier. The zero node
+// is never counted so a Dex with nothing else returns 0 (see Next).
 func (d Dex) Highest() int {
 	var highest int
 	for _, e := range d {
@@ -142,54 +431,581 @@ func (d Dex) Highest() int {
 	return highest
 }
 
-// Highest returns Highest as string.
+// HighestString returns Highest as string.
 func (d Dex) HighestString() string { return strconv.Itoa(d.Highest()) }
 
 // HighestWidth returns width of highest integer identifier.
 func (d Dex) HighestWidth() int { return len(d.HighestString()) }
 
-// Pretty returns a string with pretty color string with time stamps
-// rendered in more readable way.
-func (d Dex) Pretty() string {
-	var str string
-	nwidth := d.HighestWidth()
+// Get returns a pointer to the first DexEntry with the node ID, or nil
+// if there is none. Get scans the entire Dex so callers resolving many
+// IDs should use Map instead.
+func (d Dex) Get(id int) *DexEntry {
+	for i := range d {
+		if d[i].N == id {
+			return &d[i]
+		}
+	}
+	return nil
+}
+
+// Map returns a map of node IDs to pointers to the entries of the Dex
+// (not copies) for fast lookup by ID. When the Dex contains duplicate
+// IDs the last entry wins (see Dedup).
+func (d Dex) Map() map[int]*DexEntry {
+	m := make(map[int]*DexEntry, len(d))
+	for i := range d {
+		m[d[i].N] = &d[i]
+	}
+	return m
+}
+
+// Dedup returns a copy of the Dex with only one entry for each node ID
+// keeping the most recently updated. When duplicates have identical
+// times the first is kept. Each remaining entry keeps the position of
+// the first entry with its ID.
+func (d Dex) Dedup() Dex {
+	seen := map[int]int{}
+	dex := Dex{}
 	for _, e := range d {
-		str += fmt.Sprintf(
-			"%v%v %v%-"+strconv.Itoa(nwidth)+"v %v%v%v\n",
-			term.Black, e.U.Format(`2006-01-02 15:03Z`),
-			term.Green, e.N,
-			term.White, e.T,
-			term.Reset,
-		)
+		i, has := seen[e.N]
+		switch {
+		case !has:
+			seen[e.N] = len(dex)
+			dex = append(dex, e)
+		case e.U.After(dex[i].U):
+			dex[i] = e
+		}
+	}
+	return dex
+}
+
+// Duplicates returns the node IDs that appear more than once in the Dex
+// from lowest to highest. An empty slice means there are none.
+func (d Dex) Duplicates() []int {
+	count := map[int]int{}
+	dups := []int{}
+	for _, e := range d {
+		count[e.N]++
+		if count[e.N] == 2 {
+			dups = append(dups, e.N)
+		}
+	}
+	sort.Ints(dups)
+	return dups
+}
+
+// Upsert returns a copy of the Dex with the entry replacing every
+// existing entry with the same node ID (updating both U and T) keeping
+// its current position. If no entry has the ID it is appended to the
+// end. No other reordering is done (see ByID and ByLatest).
+func (d Dex) Upsert(e DexEntry) Dex {
+	dex := d.Clone()
+	var found bool
+	for i := range dex {
+		if dex[i].N == e.N {
+			dex[i] = e
+			found = true
+		}
+	}
+	if !found {
+		dex = append(dex, e)
+	}
+	return dex
+}
+
+// Remove returns a copy of the Dex without any entries with the node ID
+// preserving the current order.
+func (d Dex) Remove(id int) Dex {
+	dex := make(Dex, 0, len(d))
+	for _, e := range d {
+		if e.N != id {
+			dex = append(dex, e)
+		}
+	}
+	return dex
+}
+
+// Merge combines the entries of the Dex with those of others and
+// returns them as a new Dex ordered by ByLatest. Entries are considered
+// duplicates when they have the same node ID and keg name (K) in which
+// case only the most recently updated entry is kept. When duplicates
+// have identical times the first one encountered (starting with the
+// receiver) is kept. Set K on the entries of each Dex to keep nodes
+// with the same ID from different kegs.
+func (d Dex) Merge(others ...Dex) Dex {
+	type key struct {
+		k string
+		n int
+	}
+	seen := map[key]int{}
+	dex := Dex{}
+	for _, src := range append([]Dex{d}, others...) {
+		for _, e := range src {
+			k := key{e.K, e.N}
+			i, has := seen[k]
+			switch {
+			case !has:
+				seen[k] = len(dex)
+				dex = append(dex, e)
+			case e.U.After(dex[i].U):
+				dex[i] = e
+			}
+		}
+	}
+	return dex.ByLatest()
+}
+
+// Diff compares the Dex (the older snapshot) to other (the newer) and
+// returns the entries only in other (added), only in the Dex (removed),
+// and those in both with the same node ID but a different title or
+// update time (changed, as they are in other). Order of either Dex is
+// ignored and all three are returned ordered by ID. When duplicate IDs
+// exist the last entry for each is used (see Map).
+func (d Dex) Diff(other Dex) (added, removed, changed Dex) {
+	added, removed, changed = Dex{}, Dex{}, Dex{}
+	old, cur := d.Map(), other.Map()
+	for id, e := range cur {
+		o, has := old[id]
+		switch {
+		case !has:
+			added = append(added, *e)
+		case o.T != e.T || !o.U.Equal(e.U):
+			changed = append(changed, *e)
+		}
+	}
+	for id, o := range old {
+		if _, has := cur[id]; !has {
+			removed = append(removed, *o)
+		}
+	}
+	return added.ByID(), removed.ByID(), changed.ByID()
+}
+
+// DiffMD renders the output of Diff as a Markdown list in the same
+// style as MD but with the action (added, changed, removed) between the
+// time and the title link.
+func DiffMD(added, removed, changed Dex) string {
+	var buf strings.Builder
+	for _, set := range []struct {
+		action string
+		dex    Dex
+	}{{`added`, added}, {`changed`, changed}, {`removed`, removed}} {
+		for _, e := range set.dex {
+			fmt.Fprintf(&buf,
+				"* %v %v [%v](/%v)\n",
+				e.U.UTC().Format(IsoDateFmt), set.action, EscapeLinkText(e.T), e.N,
+			)
+		}
+	}
+	return buf.String()
+}
+
+// Gaps returns the node IDs from 1 to Highest that are not in the Dex
+// from lowest to highest. The zero node (0) is never considered a gap.
+func (d Dex) Gaps() []int {
+	ids := map[int]bool{}
+	for _, e := range d {
+		ids[e.N] = true
+	}
+	gaps := []int{}
+	for i := 1; i < d.Highest(); i++ {
+		if !ids[i] {
+			gaps = append(gaps, i)
+		}
+	}
+	return gaps
+}
+
+// NextGap returns the lowest unused node ID (never 0) for those who
+// prefer to reuse the IDs of deleted nodes. Returns Next if there are
+// no Gaps.
+func (d Dex) NextGap() int {
+	if gaps := d.Gaps(); len(gaps) > 0 {
+		return gaps[0]
+	}
+	return d.Next()
+}
+
+// GroupByDay returns the entries grouped by the UTC day they were
+// updated keyed by ISO date (2006-01-02). Each group preserves the
+// current order.
+func (d Dex) GroupByDay() map[string]Dex { return d.groupBy(`2006-01-02`) }
+
+// GroupByMonth returns the entries grouped by the UTC month they were
+// updated keyed by ISO month (2006-01). Each group preserves the current
+// order.
+func (d Dex) GroupByMonth() map[string]Dex { return d.groupBy(`2006-01`) }
+
+func (d Dex) groupBy(layout string) map[string]Dex {
+	groups := map[string]Dex{}
+	for _, e := range d {
+		k := e.U.UTC().Format(layout)
+		groups[k] = append(groups[k], e)
+	}
+	return groups
+}
+
+// ChangelogMD renders the Dex as Markdown with a second-level heading
+// for every UTC day (see GroupByDay) followed by the MD list of entries
+// updated that day. Days and the entries within them are ordered from
+// newest to oldest.
+func (d Dex) ChangelogMD() string {
+	groups := d.GroupByDay()
+	days := make([]string, 0, len(groups))
+	for k := range groups {
+		days = append(days, k)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(days)))
+	var buf strings.Builder
+	for i, day := range days {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString("## " + day + "\n\n")
+		buf.WriteString(groups[day].ByLatest().MD())
+	}
+	return buf.String()
+}
+
+// WithoutZero returns a copy of the Dex without the zero node (0),
+// which documents the keg itself and is often left out of listings.
+// Order is preserved.
+func (d Dex) WithoutZero() Dex {
+	without := make(Dex, 0, len(d))
+	for _, e := range d {
+		if e.N != 0 {
+			without = append(without, e)
+		}
 	}
-	return str
+	return without
 }
 
+// Next returns the next node ID to allocate, which is always one more
+// than Highest. Since the zero node (0) is reserved for the keg itself
+// (and created with it) an empty Dex returns 1. Gaps left by deleted
+// nodes are never reused.
+func (d Dex) Next() int { return d.Highest() + 1 }
+
+// NextString returns Next as string.
+func (d Dex) NextString() string { return strconv.Itoa(d.Next()) }
+
+// Pretty returns a string with pretty color string with time stamps
+// rendered in more readable way. Color is omitted when the NO_COLOR
+// environment variable is set or output is not to an interactive
+// terminal (see term.IsInteractive).
+func (d Dex) Pretty() string { return d.PrettyWith(PrettyOpts{}) }
+
+// PrettyPlain returns Pretty without any color.
+func (d Dex) PrettyPlain() string { return d.PrettyWith(PrettyOpts{Plain: true}) }
+
 // PrettyLines returns Pretty but each line separate and without line
 // return.
-func (d Dex) PrettyLines() []string {
+func (d Dex) PrettyLines() []string { return d.PrettyLinesWith(PrettyOpts{}) }
+
+// PrettyLinesPlain returns PrettyLines without any color.
+func (d Dex) PrettyLinesPlain() []string {
+	return d.PrettyLinesWith(PrettyOpts{Plain: true})
+}
+
+// PrettyRelative returns Pretty but with the time of each entry
+// rendered relative to now (see RelTime).
+func (d Dex) PrettyRelative(now time.Time) string {
+	return d.PrettyWith(PrettyOpts{Relative: true, Now: now})
+}
+
+// PrettyLinesRelative returns PrettyLines but with the time of each
+// entry rendered relative to now (see RelTime).
+func (d Dex) PrettyLinesRelative(now time.Time) []string {
+	return d.PrettyLinesWith(PrettyOpts{Relative: true, Now: now})
+}
+
+// Column names for PrettyOpts.Columns.
+const (
+	TimeColumn  = `time`
+	IDColumn    = `id`
+	TitleColumn = `title`
+)
+
+// PrettyOpts contains the options for PrettyWith and PrettyLinesWith.
+// The zero value produces the same output as Pretty.
+type PrettyOpts struct {
+	Columns    []string  // TimeColumn, IDColumn, TitleColumn (default all)
+	TimeLayout string    // time.Format layout (default PrettyDateFmt)
+	Plain      bool      // never add color
+	MaxTitle   int       // truncate longer titles with ellipsis (0 for none)
+	Relative   bool      // render time with RelTime (ignores TimeLayout)
+	Now        time.Time // time Relative is based on (default time.Now)
+}
+
+// PrettyWith returns PrettyLinesWith joined with line returns.
+func (d Dex) PrettyWith(opts PrettyOpts) string {
+	return joinLines(d.PrettyLinesWith(opts))
+}
+
+// PrettyLinesWith returns the lines of Pretty customized with opts.
+// Every column but the last is padded so that all columns remain
+// aligned. An unknown column name is ignored.
+func (d Dex) PrettyLinesWith(opts PrettyOpts) []string {
+	if opts.Columns == nil {
+		opts.Columns = []string{TimeColumn, IDColumn, TitleColumn}
+	}
+	if opts.TimeLayout == "" {
+		opts.TimeLayout = PrettyDateFmt
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	colors := map[string]string{
+		TimeColumn:  term.Black,
+		IDColumn:    term.Green,
+		TitleColumn: term.White,
+	}
+	reset := term.Reset
+	if opts.Plain || NoColor() {
+		colors, reset = map[string]string{}, ""
+	}
+
+	cells := make([]map[string]string, len(d))
+	widths := map[string]int{}
+	for i, e := range d {
+		c := map[string]string{
+			IDColumn:    strconv.Itoa(e.N),
+			TitleColumn: truncate(e.T, opts.MaxTitle),
+		}
+		if opts.Relative {
+			c[TimeColumn] = RelTime(e.U, opts.Now)
+		} else {
+			c[TimeColumn] = e.U.UTC().Format(opts.TimeLayout)
+		}
+		for k, v := range c {
+			if n := utf8.RuneCountInString(v); n > widths[k] {
+				widths[k] = n
+			}
+		}
+		cells[i] = c
+	}
+
 	lines := make([]string, 0, len(d))
-	nwidth := d.HighestWidth()
-	for _, e := range d {
-		lines = append(lines, fmt.Sprintf(
-			"%v%v %v%-"+strconv.Itoa(nwidth)+"v %v%v%v",
-			term.Black, e.U.Format(`2006-01-02 15:03Z`),
-			term.Green, e.N,
-			term.White, e.T,
-			term.Reset,
-		))
+	for _, c := range cells {
+		var line string
+		for i, col := range opts.Columns {
+			v, known := c[col]
+			if !known {
+				continue
+			}
+			if i < len(opts.Columns)-1 {
+				v += strings.Repeat(" ", widths[col]-utf8.RuneCountInString(v)) + " "
+			}
+			line += colors[col] + v
+		}
+		lines = append(lines, line+reset)
 	}
 	return lines
 }
 
-// ByID orders the Dex from lowest to highest node ID integer.
+// truncate shortens the string to max runes (when greater than zero)
+// replacing the last with an ellipsis if the string was too long.
+func truncate(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:max-1]) + `…`
+}
+
+// RelTime returns a compact, human-friendly rendering of how long
+// before now t was (ex: now, 5m ago, 2h ago, 3d ago, 4mo ago). Anything
+// a year or more before now (or more than a minute after it) is
+// rendered as a UTC date instead (2006-01-02).
+func RelTime(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < -time.Minute || d >= 365*24*time.Hour:
+		return t.UTC().Format(`2006-01-02`)
+	case d < time.Minute:
+		return `now`
+	case d < time.Hour:
+		return fmt.Sprintf(`%vm ago`, int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf(`%vh ago`, int(d/time.Hour))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf(`%vd ago`, int(d/(24*time.Hour)))
+	default:
+		return fmt.Sprintf(`%vmo ago`, int(d/(30*24*time.Hour)))
+	}
+}
+
+// NoColor returns true if the NO_COLOR environment variable is set to
+// anything but an empty string (see no-color.org).
+func NoColor() bool { return os.Getenv(`NO_COLOR`) != "" }
+
+// joinLines returns the lines joined with each followed by a line
+// return.
+func joinLines(lines []string) string {
+	var buf strings.Builder
+	for _, l := range lines {
+		buf.WriteString(l)
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// Clone returns a shallow copy of the Dex with its own underlying array
+// so that it can be reordered without changing the original.
+func (e Dex) Clone() Dex {
+	if e == nil {
+		return nil
+	}
+	c := make(Dex, len(e))
+	copy(c, e)
+	return c
+}
+
+// ByID returns a copy of the Dex ordered from lowest to highest node ID
+// integer. The original is never reordered (see Clone).
 func (e Dex) ByID() Dex {
+	e = e.Clone()
 	sort.Slice(e, func(i, j int) bool {
 		return e[i].N < e[j].N
 	})
 	return e
 }
 
+// ByLatest returns a copy of the Dex ordered from most to least
+// recently updated. Entries updated at the same time are ordered from
+// highest to lowest node ID so that the order is always deterministic.
+// Entries with a zero time are always last.
+func (e Dex) ByLatest() Dex {
+	e = e.Clone()
+	sort.Slice(e, func(i, j int) bool {
+		iz, jz := e[i].U.IsZero(), e[j].U.IsZero()
+		switch {
+		case iz != jz:
+			return jz
+		case e[i].U.Equal(e[j].U):
+			return e[i].N > e[j].N
+		default:
+			return e[i].U.After(e[j].U)
+		}
+	})
+	return e
+}
+
+// ByTitle returns a copy of the Dex ordered alphabetically by title
+// ignoring case. Entries with the same title are ordered by node ID.
+func (e Dex) ByTitle() Dex { return e.byTitleKey(strings.ToLower) }
+
+// ByTitleNoArticles is the same as ByTitle but ignores any leading
+// English article (a, an, the) when comparing titles.
+func (e Dex) ByTitleNoArticles() Dex { return e.byTitleKey(trimArticle) }
+
+func (e Dex) byTitleKey(key func(string) string) Dex {
+	e = e.Clone()
+	sort.Slice(e, func(i, j int) bool {
+		a, b := key(e[i].T), key(e[j].T)
+		if a == b {
+			return e[i].N < e[j].N
+		}
+		return a < b
+	})
+	return e
+}
+
+// trimArticle returns the lowercase title with any leading article
+// removed.
+func trimArticle(title string) string {
+	title = strings.ToLower(title)
+	for _, a := range []string{`a `, `an `, `the `} {
+		if strings.HasPrefix(title, a) {
+			return strings.TrimLeft(title[len(a):], " ")
+		}
+	}
+	return title
+}
+
+// Reverse returns a copy of the Dex in the reverse of its current order.
+func (e Dex) Reverse() Dex {
+	e = e.Clone()
+	for i, j := 0, len(e)-1; i < j; i, j = i+1, j-1 {
+		e[i], e[j] = e[j], e[i]
+	}
+	return e
+}
+
+// Last returns the n most recently updated entries ordered from most
+// to least recent (see ByLatest). All entries are returned if n is
+// greater than the length of the Dex and an empty Dex for n <= 0.
+func (e Dex) Last(n int) Dex {
+	if n <= 0 {
+		return Dex{}
+	}
+	e = e.ByLatest()
+	if n > len(e) {
+		n = len(e)
+	}
+	return e[:n]
+}
+
+// DefaultPageSize is used by Page and Pages when the size is not
+// greater than zero.
+var DefaultPageSize = 20
+
+// Page returns the nth page (starting with 1) of the Dex when divided
+// into pages of size entries (see DefaultPageSize) preserving the
+// current order. Pages out of range return an empty Dex. See Pages.
+func (e Dex) Page(n, size int) Dex {
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	start := (n - 1) * size
+	if n < 1 || start >= len(e) {
+		return Dex{}
+	}
+	end := start + size
+	if end > len(e) {
+		end = len(e)
+	}
+	return e[start:end]
+}
+
+// Pages returns the total number of pages of size entries (see Page).
+func (e Dex) Pages(size int) int {
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	return (len(e) + size - 1) / size
+}
+
+// Since filters all entries that were updated at or after t
+// (inclusive) preserving the current order.
+func (e Dex) Since(t time.Time) Dex {
+	dex := Dex{}
+	for _, d := range e {
+		if !d.U.Before(t) {
+			dex = append(dex, d)
+		}
+	}
+	return dex
+}
+
+// Before filters all entries that were updated before t (exclusive)
+// preserving the current order.
+func (e Dex) Before(t time.Time) Dex {
+	dex := Dex{}
+	for _, d := range e {
+		if d.U.Before(t) {
+			dex = append(dex, d)
+		}
+	}
+	return dex
+}
+
+// Between filters all entries updated at or after a but before b
+// preserving the current order. It is the same as calling
+// e.Since(a).Before(b).
+func (e Dex) Between(a, b time.Time) Dex { return e.Since(a).Before(b) }
+
 // WithTitleText filters all nodes with titles that do not contain the text
 // substring in the title.
 func (e Dex) WithTitleText(keyword string) Dex {
@@ -202,24 +1018,218 @@ func (e Dex) WithTitleText(keyword string) Dex {
 	return dex
 }
 
+// WithAllWords filters all nodes with titles that do not contain every
+// one of the words (in any order) ignoring case. If no words are passed
+// every node is kept.
+func (e Dex) WithAllWords(words ...string) Dex {
+	dex := Dex{}
+	for _, d := range e {
+		title := strings.ToLower(d.T)
+		all := true
+		for _, w := range words {
+			if !strings.Contains(title, strings.ToLower(w)) {
+				all = false
+				break
+			}
+		}
+		if all {
+			dex = append(dex, d)
+		}
+	}
+	return dex
+}
+
+// WithAnyWord filters all nodes with titles that do not contain at least
+// one of the words ignoring case.
+func (e Dex) WithAnyWord(words ...string) Dex {
+	dex := Dex{}
+	for _, d := range e {
+		title := strings.ToLower(d.T)
+		for _, w := range words {
+			if strings.Contains(title, strings.ToLower(w)) {
+				dex = append(dex, d)
+				break
+			}
+		}
+	}
+	return dex
+}
+
+// WithTitleRegex filters all nodes with titles that do not match the
+// regular expression (see regexp/syntax). Matching is case-sensitive
+// unless the expression begins with the (?i) flag. Returns an error if
+// the expression cannot be compiled.
+func (e Dex) WithTitleRegex(expr string) (Dex, error) {
+	x, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	dex := Dex{}
+	for _, d := range e {
+		if x.MatchString(d.T) {
+			dex = append(dex, d)
+		}
+	}
+	return dex, nil
+}
+
+// Fuzzy returns all entries with titles containing every rune of the
+// query in order (but not necessarily together) ignoring case and
+// spaces in the query (similar to fzf). Entries are ordered by score
+// (see FuzzyScore) from best to worst with shorter titles first when
+// scores are the same.
+func (e Dex) Fuzzy(query string) Dex {
+	type hit struct {
+		e     DexEntry
+		score int
+	}
+	hits := []hit{}
+	for _, d := range e {
+		if score := FuzzyScore(d.T, query); score > 0 {
+			hits = append(hits, hit{d, score})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return len(hits[i].e.T) < len(hits[j].e.T)
+	})
+	dex := make(Dex, 0, len(hits))
+	for _, h := range hits {
+		dex = append(dex, h.e)
+	}
+	return dex
+}
+
+// FuzzyScore returns the best score for matching all the runes of query
+// (ignoring case and spaces) in order within text, or 0 if they cannot
+// all be matched. Every matched rune is worth one point with bonus
+// points for runes that begin a word and for runes that immediately
+// follow the previously matched rune. A point is lost for every rune
+// skipped between matched runes (but never below a score of 1 for any
+// match).
+func FuzzyScore(text, query string) int {
+	const boundary, consecutive, none = 8, 4, math.MinInt32
+	t := []rune(strings.ToLower(text))
+	q := []rune(strings.ToLower(strings.Join(strings.Fields(query), "")))
+	if len(q) == 0 || len(q) > len(t) {
+		return 0
+	}
+	base := make([]int, len(t))
+	for i, r := range t {
+		base[i] = 1
+		if (i == 0 || !unicode.IsLetter(t[i-1]) && !unicode.IsDigit(t[i-1])) &&
+			(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			base[i] += boundary
+		}
+	}
+	// prev[i] is the best score matching q[:j] with q[j-1] at t[i]
+	prev := make([]int, len(t))
+	for i, r := range t {
+		prev[i] = none
+		if r == q[0] {
+			prev[i] = base[i]
+		}
+	}
+	for j := 1; j < len(q); j++ {
+		cur := make([]int, len(t))
+		best := none // best prev[k]+k for k < i-1 (gap penalty is i-k-1)
+		for i := range t {
+			cur[i] = none
+			if i >= 2 && prev[i-2] != none && prev[i-2]+i-2 > best {
+				best = prev[i-2] + i - 2
+			}
+			if i == 0 || t[i] != q[j] {
+				continue
+			}
+			score := none
+			if best != none {
+				score = best - i + 1
+			}
+			if prev[i-1] != none && prev[i-1]+consecutive > score {
+				score = prev[i-1] + consecutive
+			}
+			if score != none {
+				cur[i] = score + base[i]
+			}
+		}
+		prev = cur
+	}
+	max := none
+	for _, v := range prev {
+		if v > max {
+			max = v
+		}
+	}
+	switch {
+	case max == none:
+		return 0
+	case max < 1:
+		return 1
+	}
+	return max
+}
+
+// FindWithTitleText returns a single *DexEntry for the keyword passed
+// without ever prompting. If the key contains multiple space-separated
+// words all of them must be in the title in any order (see
+// WithAllWords). If multiple titles match but exactly one of them is
+// identical to the key it is returned. Otherwise, ErrNoMatch is returned
+// when nothing matches and ErrAmbiguous (containing the candidates) when
+// more than one does.
+func (d Dex) FindWithTitleText(key string) (*DexEntry, error) {
+	hits := d.WithAllWords(strings.Fields(key)...)
+	switch len(hits) {
+	case 1:
+		return &hits[0], nil
+	case 0:
+		return nil, ErrNoMatch{key}
+	}
+	var exact *DexEntry
+	for i := range hits {
+		if hits[i].T == key {
+			if exact != nil {
+				return nil, ErrAmbiguous{key, hits}
+			}
+			exact = &hits[i]
+		}
+	}
+	if exact != nil {
+		return exact, nil
+	}
+	return nil, ErrAmbiguous{key, hits}
+}
+
 // ChooseWithTitleText returns a single *DexEntry for the keyword
-// passed. If there are more than one then user is prompted to choose
-// from list sent to the terminal.
+// passed (see FindWithTitleText) falling back to Fuzzy when no titles
+// contain the key. If there are more than one then user is prompted to
+// choose from list sent to the terminal. Returns nil if there are no
+// matches or the user does not choose one.
 func (d Dex) ChooseWithTitleText(key string) *DexEntry {
-	hits := d.WithTitleText(key)
+	hit, err := d.FindWithTitleText(key)
+	if err == nil {
+		return hit
+	}
+	var hits Dex
+	switch v := err.(type) {
+	case ErrAmbiguous:
+		hits = v.Hits
+	case ErrNoMatch:
+		hits = d.Fuzzy(key)
+	}
 	switch len(hits) {
+	case 0:
+		return nil
 	case 1:
 		return &hits[0]
-	case 0:
+	}
+	i, _, err := choose.From(hits.PrettyLines())
+	if err != nil {
+		return nil
+	}
+	if i < 0 {
 		return nil
-	default:
-		i, _, err := choose.From(hits.PrettyLines())
-		if err != nil {
-			return nil
-		}
-		if i < 0 {
-			return nil
-		}
-		return &hits[i]
 	}
+	return &hits[i]
 }
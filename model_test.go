@@ -2,10 +2,14 @@ package keg_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"testing"
 	"time"
 
 	"github.com/rwxrob/keg"
+	"github.com/rwxrob/term"
 )
 
 func ExampleDex_json() {
@@ -31,6 +35,21 @@ func ExampleDex_string() {
 	// * 2022-12-10 06:10:04Z [Some title](/2)
 }
 
+func ExampleDexEntry_MD_escaped() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	d := keg.DexEntry{U: date, N: 2, T: `Using [brackets] in (titles) \o/`}
+	fmt.Println(d.MD())
+	fmt.Println(d.AsInclude())
+	fmt.Println(d.TSV())
+	byt, _ := d.MarshalJSON()
+	fmt.Println(string(byt))
+	// Output:
+	// * 2022-12-10 06:10:04Z [Using \[brackets\] in (titles) \\o/](/2)
+	// * [Using \[brackets\] in (titles) \\o/](/2)
+	// 2	2022-12-10 06:10:04Z	Using [brackets] in (titles) \o/
+	// {"U":"2022-12-10 06:10:04Z","N":2,"T":"Using [brackets] in (titles) \\o/"}
+}
+
 func ExampleDex_tsv() {
 	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
 	d := keg.DexEntry{U: date, N: 2, T: `Some title`}
@@ -39,14 +58,777 @@ func ExampleDex_tsv() {
 	// 2	2022-12-10 06:10:04Z	Some title
 }
 
-/*
+func ExampleDex_MarshalJSON_empty() {
+	dex := keg.Dex{}
+	byt, err := dex.MarshalJSON()
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(string(byt))
+	// Output:
+	// []
+}
+
+func ExampleDex_MarshalJSON_single() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{{U: date, N: 2, T: `Some title`}}
+	byt, err := dex.MarshalJSON()
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(string(byt))
+	// Output:
+	// [{"U":"2022-12-10 06:10:04Z","N":2,"T":"Some title"}]
+}
+
+func ExampleDex_MarshalJSON() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 2, T: `Some title`},
+		{U: date, N: 1, T: `Another "title"`},
+	}
+	byt, err := dex.MarshalJSON()
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(string(byt))
+	// Output:
+	// [{"U":"2022-12-10 06:10:04Z","N":2,"T":"Some title"},
+	// {"U":"2022-12-10 06:10:04Z","N":1,"T":"Another \"title\""}]
+}
+
+func ExampleDex_ByID() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 3, T: `Three`},
+		{U: date.Add(time.Hour), N: 1, T: `One`},
+		{U: date, N: 2, T: `Two`},
+	}
+	fmt.Print(dex.ByID().AsIncludes())
+	dex.ByLatest()
+	dex.ByTitle()
+	dex.Reverse()
+	fmt.Print(dex.AsIncludes())
+	// Output:
+	// * [One](/1)
+	// * [Two](/2)
+	// * [Three](/3)
+	// * [Three](/3)
+	// * [One](/1)
+	// * [Two](/2)
+}
+
+func ExampleDex_Clone() {
+	dex := keg.Dex{{N: 1, T: `One`}}
+	c := dex.Clone()
+	c[0].T = `Changed`
+	fmt.Println(dex[0].T, c[0].T)
+	// Output:
+	// One Changed
+}
+
+func ExampleDex_ByLatest() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: time.Time{}, N: 4, T: `Never updated`},
+		{U: date, N: 1, T: `Older`},
+		{U: date.Add(time.Hour), N: 2, T: `Newest`},
+		{U: date, N: 3, T: `Older, higher ID`},
+	}
+	fmt.Print(dex.ByLatest().MD())
+	fmt.Print(dex.ByLatest().Reverse().MD())
+	// Output:
+	// * 2022-12-10 07:10:04Z [Newest](/2)
+	// * 2022-12-10 06:10:04Z [Older, higher ID](/3)
+	// * 2022-12-10 06:10:04Z [Older](/1)
+	// * 0001-01-01 00:00:00Z [Never updated](/4)
+	// * 0001-01-01 00:00:00Z [Never updated](/4)
+	// * 2022-12-10 06:10:04Z [Older](/1)
+	// * 2022-12-10 06:10:04Z [Older, higher ID](/3)
+	// * 2022-12-10 07:10:04Z [Newest](/2)
+}
+
+func ExampleDex_ByTitle() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 1, T: `the Zebra`},
+		{U: date, N: 2, T: `banana`},
+		{U: date, N: 3, T: `An apple`},
+		{U: date, N: 4, T: `Banana`},
+	}
+	fmt.Print(dex.ByTitle().AsIncludes())
+	fmt.Print(dex.ByTitleNoArticles().AsIncludes())
+	// Output:
+	// * [An apple](/3)
+	// * [banana](/2)
+	// * [Banana](/4)
+	// * [the Zebra](/1)
+	// * [An apple](/3)
+	// * [banana](/2)
+	// * [Banana](/4)
+	// * [the Zebra](/1)
+}
+
+func ExampleDex_ByTitleNoArticles() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 1, T: `The Zebra`},
+		{U: date, N: 2, T: `Yak`},
+		{U: date, N: 3, T: `An apple`},
+		{U: date, N: 4, T: `Anteater`},
+	}
+	fmt.Print(dex.ByTitleNoArticles().AsIncludes())
+	// Output:
+	// * [Anteater](/4)
+	// * [An apple](/3)
+	// * [Yak](/2)
+	// * [The Zebra](/1)
+}
+
+func ExampleDex_Last() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 1, T: `One`},
+		{U: date.Add(2 * time.Hour), N: 2, T: `Two`},
+		{U: date.Add(time.Hour), N: 3, T: `Three`},
+	}
+	fmt.Print(dex.Last(2).AsIncludes())
+	fmt.Println(len(dex.Last(5)), len(dex.Last(0)), len(dex.Last(-1)))
+	// Output:
+	// * [Two](/2)
+	// * [Three](/3)
+	// 3 0 0
+}
+
+func ExampleDex_Since() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date.Add(time.Hour), N: 1, T: `After`},
+		{U: date, N: 2, T: `Exactly`},
+		{U: date.Add(-time.Second), N: 3, T: `Before`},
+	}
+	fmt.Print(dex.Since(date).AsIncludes())
+	// Output:
+	// * [After](/1)
+	// * [Exactly](/2)
+}
+
+func ExampleDex_Before() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date.Add(time.Hour), N: 1, T: `After`},
+		{U: date, N: 2, T: `Exactly`},
+		{U: date.Add(-time.Second), N: 3, T: `Before`},
+	}
+	fmt.Print(dex.Before(date).AsIncludes())
+	// Output:
+	// * [Before](/3)
+}
+
+func ExampleDex_Between() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	week := date.AddDate(0, 0, 7)
+	dex := keg.Dex{
+		{U: week, N: 1, T: `End`},
+		{U: date.Add(time.Hour), N: 2, T: `Middle`},
+		{U: date, N: 3, T: `Start`},
+		{U: date.Add(-time.Second), N: 4, T: `Before`},
+	}
+	fmt.Print(dex.Between(date, week).AsIncludes())
+	// Output:
+	// * [Middle](/2)
+	// * [Start](/3)
+}
+
+func ExampleDex_WithTitleRegex() {
+	dex := keg.Dex{
+		{N: 1, T: `zet: Docker networking`},
+		{N: 2, T: `Podman basics`},
+		{N: 3, T: `About zet: prefixes`},
+	}
+	hits, err := dex.WithTitleRegex(`^zet: `)
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Print(hits.AsIncludes())
+	hits, _ = dex.WithTitleRegex(`(docker|podman)`)
+	fmt.Print(hits.AsIncludes())
+	hits, _ = dex.WithTitleRegex(`(?i)(docker|podman)`)
+	fmt.Print(hits.AsIncludes())
+	_, err = dex.WithTitleRegex(`(unclosed`)
+	fmt.Println(err)
+	// Output:
+	// * [zet: Docker networking](/1)
+	// * [zet: Docker networking](/1)
+	// * [Podman basics](/2)
+	// error parsing regexp: missing closing ): `(unclosed`
+}
+
+func ExampleDex_Get() {
+	dex := keg.Dex{{N: 1, T: `One`}, {N: 42, T: `Answer`}}
+	fmt.Println(dex.Get(42).T)
+	fmt.Println(dex.Get(7))
+	// Output:
+	// Answer
+	// <nil>
+}
+
+func ExampleDex_Map() {
+	dex := keg.Dex{{N: 1, T: `One`}, {N: 42, T: `Answer`}, {N: 1, T: `Dup`}}
+	m := dex.Map()
+	fmt.Println(len(m), m[42].T, m[1].T, m[7])
+	// Output:
+	// 2 Answer Dup <nil>
+}
+
+func ExampleDex_Upsert() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 1, T: `One`},
+		{U: date, N: 2, T: `Two`},
+	}
+	dex = dex.Upsert(keg.DexEntry{U: date.Add(time.Hour), N: 1, T: `Uno`}).
+		Upsert(keg.DexEntry{U: date, N: 3, T: `Three`})
+	fmt.Print(dex.MD())
+	// Output:
+	// * 2022-12-10 07:10:04Z [Uno](/1)
+	// * 2022-12-10 06:10:04Z [Two](/2)
+	// * 2022-12-10 06:10:04Z [Three](/3)
+}
+
+func ExampleDex_Remove() {
+	dex := keg.Dex{{N: 1, T: `One`}, {N: 2, T: `Two`}, {N: 3, T: `Three`}}
+	fmt.Print(dex.Remove(2).Remove(42).AsIncludes())
+	fmt.Println(len(dex))
+	// Output:
+	// * [One](/1)
+	// * [Three](/3)
+	// 3
+}
+
+func ExampleDex_Merge() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	a := keg.Dex{
+		{U: date, N: 1, T: `One`},
+		{U: date, N: 2, T: `Two`},
+	}
+	b := keg.Dex{
+		{U: date.Add(time.Hour), N: 1, T: `One (newer)`},
+		{U: date, N: 2, T: `Two (same time)`},
+		{U: date, N: 3, T: `Three`},
+	}
+	fmt.Print(a.Merge(b).MD())
+	fmt.Print(a.Merge(keg.Dex{}, a).MD())
+	// Output:
+	// * 2022-12-10 07:10:04Z [One (newer)](/1)
+	// * 2022-12-10 06:10:04Z [Three](/3)
+	// * 2022-12-10 06:10:04Z [Two](/2)
+	// * 2022-12-10 06:10:04Z [Two](/2)
+	// * 2022-12-10 06:10:04Z [One](/1)
+}
+
+func ExampleDex_Merge_kegs() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	a := keg.Dex{{U: date, N: 1, T: `One`, K: `mine`}}
+	b := keg.Dex{{U: date.Add(time.Hour), N: 1, T: `Other one`, K: `theirs`}}
+	for _, e := range a.Merge(b) {
+		fmt.Println(e.K, e.N, e.T)
+	}
+	// Output:
+	// theirs 1 Other one
+	// mine 1 One
+}
+
+func ExampleDex_Diff() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	old := keg.Dex{
+		{U: date, N: 3, T: `Three`},
+		{U: date, N: 1, T: `One`},
+		{U: date, N: 2, T: `Two`},
+		{U: date, N: 4, T: `Four`},
+	}
+	cur := keg.Dex{
+		{U: date, N: 1, T: `One`},
+		{U: date.Add(time.Hour), N: 2, T: `Two`},
+		{U: date, N: 3, T: `Tres`},
+		{U: date, N: 5, T: `Five`},
+		{U: date, N: 5, T: `Five`},
+	}
+	added, removed, changed := old.Diff(cur)
+	fmt.Print(added.AsIncludes())
+	fmt.Print(removed.AsIncludes())
+	fmt.Print(changed.AsIncludes())
+	fmt.Print(keg.DiffMD(added, removed, changed))
+	// Output:
+	// * [Five](/5)
+	// * [Four](/4)
+	// * [Two](/2)
+	// * [Tres](/3)
+	// * 2022-12-10 06:10:04Z added [Five](/5)
+	// * 2022-12-10 07:10:04Z changed [Two](/2)
+	// * 2022-12-10 06:10:04Z changed [Tres](/3)
+	// * 2022-12-10 06:10:04Z removed [Four](/4)
+}
+
+func ExampleDex_Dedup() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 2, T: `Two`},
+		{U: date, N: 1, T: `One`},
+		{U: date.Add(time.Hour), N: 2, T: `Two (newer)`},
+		{U: date, N: 1, T: `One (same time)`},
+		{U: date, N: 3, T: `Three`},
+	}
+	fmt.Println(dex.Duplicates())
+	fmt.Print(dex.Dedup().AsIncludes())
+	fmt.Println(dex.Dedup().Duplicates())
+	// Output:
+	// [1 2]
+	// * [Two (newer)](/2)
+	// * [One](/1)
+	// * [Three](/3)
+	// []
+}
+
+func ExampleDex_WithoutZero() {
+	dex := keg.Dex{{N: 2}, {N: 0}, {N: 1}}
+	fmt.Println(dex.WithoutZero().IDs(), dex.IDs())
+	// Output:
+	// [2 1] [2 0 1]
+}
+
+func ExampleDex_Next() {
+	fmt.Println(keg.Dex{}.Next())
+	fmt.Println(keg.Dex{{N: 0}}.Next())
+	fmt.Println(keg.Dex{{N: 3}}.Next())
+	fmt.Println(keg.Dex{{N: 2}, {N: 10}, {N: 5}}.NextString())
+	// Output:
+	// 1
+	// 1
+	// 4
+	// 11
+}
+
+func ExampleDexEntry_Validate() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	fmt.Println(keg.DexEntry{U: date, N: 1, T: `Fine`}.Validate())
+	fmt.Println(keg.DexEntry{U: date, N: -1, T: `Negative`}.Validate())
+	fmt.Println(keg.DexEntry{U: date, N: 2, T: ` `}.Validate())
+	fmt.Println(keg.DexEntry{U: date, N: 3, T: "Tab\there"}.Validate())
+	fmt.Println(keg.DexEntry{N: 4, T: `No time`}.Validate())
+	// Output:
+	// <nil>
+	// invalid node id: -1
+	// empty title for node 2
+	// title for node 3 has control characters: "Tab\there"
+	// missing updated time for node 4
+}
+
+func ExampleDex_Validate() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 1, T: `Fine`},
+		{U: date, N: 2, T: "Line\nbreak"},
+		{U: date, N: 3},
+	}
+	err := dex.Validate()
+	fmt.Println(err)
+	var empty keg.EmptyTitle
+	fmt.Println(errors.As(err, &empty), empty.N)
+	fmt.Println(dex[:1].Validate())
+	// Output:
+	// title for node 2 has control characters: "Line\nbreak"
+	// empty title for node 3
+	// true 3
+	// <nil>
+}
+
+func bigDex(n int) keg.Dex {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := make(keg.Dex, n)
+	for i := range dex {
+		dex[i] = keg.DexEntry{U: date.Add(time.Duration(i) * time.Minute), N: i, T: fmt.Sprintf(`Some title for %v`, i)}
+	}
+	return dex
+}
+
+func BenchmarkDex_Get(b *testing.B) {
+	dex := bigDex(5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for id := 0; id < 5000; id++ {
+			dex.Get(id)
+		}
+	}
+}
+
+func BenchmarkDex_Map(b *testing.B) {
+	dex := bigDex(5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := dex.Map()
+		for id := 0; id < 5000; id++ {
+			_ = m[id]
+		}
+	}
+}
+
 func ExampleDex_Pretty() {
 	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
 	d1 := keg.DexEntry{U: date, N: 2000, T: `Some title`}
-	d2 := keg.DexEntry{U: date, N: 1, T: `Another title`}
+	d2 := keg.DexEntry{U: date.Add(49 * time.Minute), N: 1, T: `Another title`}
 	dex := keg.Dex{d1, d2}
-	fmt.Println(dex.Pretty())
+	fmt.Print(dex.Pretty())
+	fmt.Println(dex.PrettyLines()[1])
+	// Output:
+	// 2022-12-10 06:10Z 2000 Some title
+	// 2022-12-10 06:59Z 1    Another title
+	// 2022-12-10 06:59Z 1    Another title
+}
+
+func ExampleDexEntry_utc() {
+	est := time.FixedZone(`EST`, -5*60*60)
+	date := time.Date(2022, 12, 10, 1, 10, 4, 0, est)
+	d := keg.DexEntry{U: date, N: 2, T: `Some title`}
+	fmt.Println(d.MD())
+	fmt.Println(d.TSV())
+	byt, _ := d.MarshalJSON()
+	fmt.Println(string(byt))
+	fmt.Print(keg.Dex{d}.Pretty())
+	// Output:
+	// * 2022-12-10 06:10:04Z [Some title](/2)
+	// 2	2022-12-10 06:10:04Z	Some title
+	// {"U":"2022-12-10 06:10:04Z","N":2,"T":"Some title"}
+	// 2022-12-10 06:10Z 2 Some title
+}
+
+func ExampleDex_PrettyPlain() {
+	term.SetInteractive(true)
+	defer term.SetInteractive(false)
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{{U: date, N: 2, T: `Some title`}}
+	fmt.Printf("%q\n", dex.Pretty())
+	fmt.Printf("%q\n", dex.PrettyPlain())
+	os.Setenv(`NO_COLOR`, `1`)
+	defer os.Unsetenv(`NO_COLOR`)
+	fmt.Printf("%q\n", dex.Pretty())
+	fmt.Printf("%q\n", dex.PrettyLines())
+	// Output:
+	// "\x1b[30m2022-12-10 06:10Z \x1b[32m2 \x1b[37mSome title\x1b[0m\n"
+	// "2022-12-10 06:10Z 2 Some title\n"
+	// "2022-12-10 06:10Z 2 Some title\n"
+	// ["2022-12-10 06:10Z 2 Some title"]
+}
+
+func ExampleRelTime() {
+	now := time.Date(2023, 1, 14, 9, 12, 5, 0, time.UTC)
+	fmt.Println(keg.RelTime(now.Add(-30*time.Second), now))
+	fmt.Println(keg.RelTime(now.Add(-5*time.Minute), now))
+	fmt.Println(keg.RelTime(now.Add(-2*time.Hour), now))
+	fmt.Println(keg.RelTime(now.AddDate(0, 0, -3), now))
+	fmt.Println(keg.RelTime(now.AddDate(0, -4, 0), now))
+	fmt.Println(keg.RelTime(now.AddDate(-1, 0, 0), now))
+	fmt.Println(keg.RelTime(now.Add(time.Hour), now))
+	// Output:
+	// now
+	// 5m ago
+	// 2h ago
+	// 3d ago
+	// 4mo ago
+	// 2022-01-14
+	// 2023-01-14
+}
+
+func ExampleDex_PrettyRelative() {
+	now := time.Date(2023, 1, 14, 9, 12, 5, 0, time.UTC)
+	dex := keg.Dex{
+		{U: now.Add(-2 * time.Hour), N: 1, T: `Recent`},
+		{U: now.AddDate(0, 0, -12), N: 20, T: `Less recent`},
+		{U: now.AddDate(-2, 0, 0), N: 300, T: `Old`},
+	}
+	fmt.Print(dex.PrettyRelative(now))
+	// Output:
+	// 2h ago     1   Recent
+	// 12d ago    20  Less recent
+	// 2021-01-14 300 Old
+}
+
+func ExampleDex_PrettyWith() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 2000, T: `Some title`},
+		{U: date, N: 1, T: `Another much longer title`},
+	}
+	fmt.Print(dex.PrettyWith(keg.PrettyOpts{}) == dex.Pretty())
+	fmt.Println()
+	fmt.Print(dex.PrettyWith(keg.PrettyOpts{
+		Columns:    []string{keg.IDColumn, keg.TitleColumn, keg.TimeColumn},
+		TimeLayout: `Jan 2`,
+		MaxTitle:   12,
+	}))
+	fmt.Print(dex.PrettyWith(keg.PrettyOpts{Columns: []string{keg.TitleColumn}}))
+	// Output:
+	// true
+	// 2000 Some title   Dec 10
+	// 1    Another muc… Dec 10
+	// Some title
+	// Another much longer title
+}
+
+func ExampleDex_CSV() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 1, T: `Plain`},
+		{U: date, N: 2, T: `Commas, and "quotes"`},
+	}
+	fmt.Print(dex.CSV())
+	// Output:
+	// id,updated,title
+	// 1,2022-12-10 06:10:04Z,Plain
+	// 2,2022-12-10 06:10:04Z,"Commas, and ""quotes"""
+}
+
+func ExampleDex_WriteTSV() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{{U: date, N: 1, T: `One`}, {U: date, N: 2, T: `Two`}}
+	if err := dex.WriteTSV(os.Stdout); err != nil {
+		fmt.Println(err)
+	}
+	if err := dex.WriteMD(os.Stdout); err != nil {
+		fmt.Println(err)
+	}
+	if err := dex.WriteJSON(os.Stdout); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// 1	2022-12-10 06:10:04Z	One
+	// 2	2022-12-10 06:10:04Z	Two
+	// * 2022-12-10 06:10:04Z [One](/1)
+	// * 2022-12-10 06:10:04Z [Two](/2)
+	// [{"U":"2022-12-10 06:10:04Z","N":1,"T":"One"},
+	// {"U":"2022-12-10 06:10:04Z","N":2,"T":"Two"}]
+}
+
+// concatMD is the original string concatenation implementation of MD
+// kept only for comparison.
+func concatMD(d keg.Dex) string {
+	var str string
+	for _, entry := range d {
+		str += entry.MD() + "\n"
+	}
+	return str
+}
+
+func BenchmarkDex_MD_concat(b *testing.B) {
+	dex := bigDex(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		concatMD(dex)
+	}
+}
+
+func BenchmarkDex_MD(b *testing.B) {
+	dex := bigDex(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = dex.MD()
+	}
+}
+
+func ExampleDex_String() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dex := keg.Dex{{U: date, N: 1, T: `One`}, {U: date, N: 2, T: `Two`}}
+	fmt.Print(dex)
+	fmt.Printf("%q\n", keg.Dex{}.String())
+	// Output:
+	// 1	2022-12-10 06:10:04Z	One
+	// 2	2022-12-10 06:10:04Z	Two
+	// ""
+}
+
+func ExampleDex_AsIncludesWith() {
+	dex := keg.Dex{{N: 42, T: `Answer`}, {N: 7, T: `Lucky`}}
+	fmt.Print(dex.AsIncludes())
+	fmt.Print(dex.AsIncludesWith(`../`))
+	fmt.Print(dex.AsIncludesWith(`/otherkeg/`))
+	// Output:
+	// * [Answer](/42)
+	// * [Lucky](/7)
+	// * [Answer](../42)
+	// * [Lucky](../7)
+	// * [Answer](/otherkeg/42)
+	// * [Lucky](/otherkeg/7)
+}
+
+func ExampleDex_Page() {
+	dex := bigDex(45)
+	fmt.Println(dex.Pages(20), dex.Pages(0), dex.Pages(45), keg.Dex{}.Pages(10))
+	fmt.Print(dex.Page(3, 20).AsIncludes())
+	fmt.Println(len(dex.Page(1, 0)), len(dex.Page(4, 20)), len(dex.Page(0, 20)))
+	// Output:
+	// 3 3 1 0
+	// * [Some title for 40](/40)
+	// * [Some title for 41](/41)
+	// * [Some title for 42](/42)
+	// * [Some title for 43](/43)
+	// * [Some title for 44](/44)
+	// 20 0 0
+}
+
+func ExampleDex_ChangelogMD() {
+	est := time.FixedZone(`EST`, -5*60*60)
+	date := time.Date(2023, 1, 14, 9, 12, 5, 0, time.UTC)
+	dex := keg.Dex{
+		{U: date, N: 1, T: `One`},
+		{U: date.Add(2 * time.Hour), N: 2, T: `Two`},
+		{U: time.Date(2023, 1, 14, 22, 0, 0, 0, est), N: 3, T: `Three`},
+		{U: date.AddDate(0, -1, 0), N: 4, T: `Four`},
+	}
+	fmt.Println(len(dex.GroupByDay()), len(dex.GroupByMonth()))
+	fmt.Print(dex.GroupByMonth()[`2022-12`].AsIncludes())
+	fmt.Print(dex.ChangelogMD())
+	// Output:
+	// 3 2
+	// * [Four](/4)
+	// ## 2023-01-15
+	//
+	// * 2023-01-15 03:00:00Z [Three](/3)
+	//
+	// ## 2023-01-14
+	//
+	// * 2023-01-14 11:12:05Z [Two](/2)
+	// * 2023-01-14 09:12:05Z [One](/1)
+	//
+	// ## 2022-12-14
+	//
+	// * 2022-12-14 09:12:05Z [Four](/4)
+}
+
+func ExampleDex_WithAllWords() {
+	dex := keg.Dex{
+		{N: 1, T: `Docker networking basics`},
+		{N: 2, T: `Networking with Podman`},
+		{N: 3, T: `Docker volumes`},
+	}
+	fmt.Print(dex.WithAllWords(`networking`, `DOCKER`).AsIncludes())
+	fmt.Println(len(dex.WithAllWords()))
+	fmt.Print(dex.WithAnyWord(`podman`, `volumes`).AsIncludes())
+	fmt.Println(dex.ChooseWithTitleText(`net docker`).N)
+	// Output:
+	// * [Docker networking basics](/1)
+	// 3
+	// * [Networking with Podman](/2)
+	// * [Docker volumes](/3)
+	// 1
+}
+
+func ExampleDex_FindWithTitleText() {
+	dex := keg.Dex{
+		{N: 1, T: `Docker`},
+		{N: 2, T: `Docker networking`},
+		{N: 3, T: `Docker volumes`},
+	}
+	hit, err := dex.FindWithTitleText(`docker net`)
+	fmt.Println(hit.N, err)
+	hit, err = dex.FindWithTitleText(`Docker`)
+	fmt.Println(hit.N, err)
+	_, err = dex.FindWithTitleText(`docker`)
+	fmt.Println(err)
+	var amb keg.ErrAmbiguous
+	fmt.Println(errors.As(err, &amb), len(amb.Hits))
+	_, err = dex.FindWithTitleText(`podman`)
+	fmt.Println(err)
+	// Output:
+	// 2 <nil>
+	// 1 <nil>
+	// 3 titles match "docker": 1, 2, 3
+	// true 3
+	// no titles match "podman"
+}
+
+func ExampleDex_Fuzzy() {
+	dex := keg.Dex{
+		{N: 1, T: `Darkroom internet tips`},
+		{N: 2, T: `Docker networking basics`},
+		{N: 3, T: `Docker networking`},
+		{N: 4, T: `Podman volumes`},
+	}
+	fmt.Print(dex.Fuzzy(`dkrnet`).AsIncludes())
+	fmt.Println(len(dex.Fuzzy(`xyz`)), len(dex.Fuzzy(``)))
+	fmt.Println(dex.ChooseWithTitleText(`pdmvol`).N)
+	// Output:
+	// * [Docker networking](/3)
+	// * [Docker networking basics](/2)
+	// * [Darkroom internet tips](/1)
+	// 0 0
+	// 4
+}
+
+func ExampleFuzzyScore() {
+	fmt.Println(keg.FuzzyScore(`Docker networking`, `dn`))
+	fmt.Println(keg.FuzzyScore(`Docker networking`, `do`))
+	fmt.Println(keg.FuzzyScore(`Docker networking`, `ok`))
+	fmt.Println(keg.FuzzyScore(`Docker networking`, `kd`))
+	// Output:
+	// 12
+	// 14
+	// 1
+	// 0
+}
+
+func ExampleDex_Titles() {
+	dex := keg.Dex{{N: 3, T: `Three`}, {N: 1, T: `One`}, {N: 2, T: `Two`}}
+	fmt.Println(dex.Titles())
+	fmt.Println(dex.IDs())
+	fmt.Println(dex.ByID().IDs())
+	fmt.Println(keg.Dex{}.Titles(), keg.Dex{}.IDs())
+	// Output:
+	// [Three One Two]
+	// [3 1 2]
+	// [1 2 3]
+	// [] []
+}
+
+func ExampleDex_Equal() {
+	date := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	a := keg.Dex{{U: date, N: 1, T: `One`}, {U: date, N: 2, T: `Two`}}
+	b := keg.Dex{{U: date.Add(500 * time.Millisecond), N: 1, T: `One`}, {U: date, N: 2, T: `Two`}}
+	c := keg.Dex{{U: date, N: 2, T: `Two`}, {U: date, N: 1, T: `One`}}
+	d := keg.Dex{{U: date, N: 2, T: `Two`}, {U: date, N: 2, T: `Two`}}
+	fmt.Println(a[0].Equal(b[0]), a[0].Equal(a[1]))
+	fmt.Println(a.Equal(b), a.Equal(c), a.Equal(a[:1]))
+	fmt.Println(a.EqualSet(c), a.EqualSet(d), d.EqualSet(a))
+	// Output:
+	// true false
+	// true false false
+	// true false false
+}
+
+func ExampleDex_Gaps() {
+	dex := keg.Dex{{N: 0}, {N: 3}, {N: 1}, {N: 6}}
+	fmt.Println(dex.Gaps(), dex.NextGap(), dex.Next())
+	full := keg.Dex{{N: 1}, {N: 2}}
+	fmt.Println(full.Gaps(), full.NextGap())
+	fmt.Println(keg.Dex{}.Gaps(), keg.Dex{}.NextGap())
+	// Output:
+	// [2 4 5] 2 7
+	// [] 3
+	// [] 1
+}
+
+func ExampleDex_AsNumberedIncludes() {
+	dex := bigDex(11)[1:].Reverse()
+	dex[0].T = `With [brackets]`
+	fmt.Print(dex.AsNumberedIncludes())
 	// Output:
-	// ignored
+	// 1. [With \[brackets\]](/10)
+	// 2. [Some title for 9](/9)
+	// 3. [Some title for 8](/8)
+	// 4. [Some title for 7](/7)
+	// 5. [Some title for 6](/6)
+	// 6. [Some title for 5](/5)
+	// 7. [Some title for 4](/4)
+	// 8. [Some title for 3](/3)
+	// 9. [Some title for 2](/2)
+	// 10. [Some title for 1](/1)
 }
-*/
@@ -0,0 +1,315 @@
+package keg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	iofs "io/fs"
+
+	"github.com/rwxrob/fs"
+)
+
+// Node is a single node directory of a keg with what is known about it
+// from its content.
+type Node struct {
+	ID      int
+	Dir     string    // full path to the node directory
+	Title   string    // first "# " line of README.md
+	Updated time.Time // last change to any file in Dir
+	Stale   bool      // cached copy of a remote node not checked (see FetchNode)
+}
+
+// readNode returns the Node for the node directory dir or NodeNotFound
+// if it does not exist.
+func readNode(id int, dir string) (*Node, error) {
+	if !isDir(dir) {
+		return nil, NodeNotFound{id}
+	}
+	e, err := scanNode(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Node{ID: id, Dir: dir, Title: e.T, Updated: e.U}, nil
+}
+
+// Node returns the Node for the entry from the keg directory at
+// kegpath (see Keg.Node).
+func (e DexEntry) Node(kegpath string) (*Node, error) {
+	return readNode(e.N, filepath.Join(kegpath, strconv.Itoa(e.N)))
+}
+
+// NodeChanged returns the newest modification time (in UTC) of any
+// file within the node directory dir (at any depth) since any of them
+// (not just README.md) count as a change to the node. Directory times
+// are ignored (they change when temporary files come and go) as are
+// editor swap and backup files (*.swp, *~, .#*). If dir is a keg root
+// by mistake its dex directory is skipped. Returns a zero time if there
+// are no files.
+func NodeChanged(dir string) (time.Time, error) {
+	latest, _, _, err := nodeFiles(dir)
+	return latest, err
+}
+
+// nodeFiles returns NodeChanged along with the number and total size of
+// the files counted other than README.md and meta (the attachments, see
+// Node.Attachments) from the same walk.
+func nodeFiles(dir string) (latest time.Time, files int, size int64, err error) {
+	readme, meta := filepath.Join(dir, `README.md`), filepath.Join(dir, `meta`)
+	err = filepath.WalkDir(dir, func(p string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if name == `dex` && filepath.Dir(p) == filepath.Clean(dir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isEditorTemp(name) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		if p != readme && p != meta {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return latest.UTC(), files, size, err
+}
+
+// isEditorTemp returns true if the file name is that of an editor swap
+// or backup file (*.swp, *~, .#*) rather than content.
+func isEditorTemp(name string) bool {
+	return strings.HasSuffix(name, `.swp`) || strings.HasSuffix(name, `~`) ||
+		strings.HasPrefix(name, `.#`)
+}
+
+// ReadmePath returns the full path to the README.md file of the node.
+func (n *Node) ReadmePath() string { return filepath.Join(n.Dir, `README.md`) }
+
+// ReadTitle reads the current title from the README.md file (see
+// Title) without changing the Node.
+func (n *Node) ReadTitle() (string, error) { return readNodeTitle(n.ReadmePath()) }
+
+// Touch marks the node as changed now (by setting the modification time
+// of its README.md) and updates Updated to match (see NodeChanged) along
+// with the entry of the node in both dex files (see touchDexAt) so that
+// it is at the top of dex/latest.md right away.
+func (n *Node) Touch() error {
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := os.Chtimes(n.ReadmePath(), now, now); err != nil {
+		return err
+	}
+	u, err := NodeChanged(n.Dir)
+	if err != nil {
+		return err
+	}
+	return n.touchDexAt(u.UTC().Truncate(time.Second))
+}
+
+// Files returns the paths (relative to Dir, sorted) of every file in
+// the node directory (at any depth) other than the README.md itself,
+// usually images and other attachments.
+func (n *Node) Files() ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(n.Dir, func(p string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(n.Dir, p)
+		if err != nil {
+			return err
+		}
+		if rel != `README.md` {
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// MakeNode creates the next node directory (see Dex.Next) containing
+// a README.md with the title (followed by a blank line), adds it to
+// both dex files (see WriteDex) and the changes file (see
+// AppendChange), and returns it. If another process
+// has already created the directory for the next ID the one after it
+// is tried instead. Titles must be valid (see DexEntry.Validate) so
+// empty titles and those with line returns are refused. The dex lock is
+// held throughout (see LockDex). See MakeNodeFrom for templates.
+func (k *Keg) MakeNode(title string) (*Node, error) {
+	return k.makeNode(title, func(TemplateData) (string, error) {
+		return "# " + title + "\n\n", nil
+	})
+}
+
+// makeNode is MakeNode with the README.md content returned by content
+// once the ID is known. The node directory is removed again if content
+// returns an error.
+func (k *Keg) makeNode(title string, content func(TemplateData) (string, error)) (*Node, error) {
+	unlock, err := LockDex(k.Local.Path, DexLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	dex, err := k.Dex()
+	if dex == nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := (DexEntry{U: now, T: title, N: dex.Next()}).Validate(); err != nil {
+		return nil, err
+	}
+	id := dex.Next()
+	for ; ; id++ {
+		err := os.Mkdir(k.Path(id), 0755)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, iofs.ErrExist) {
+			return nil, err
+		}
+	}
+	node := &Node{ID: id, Dir: k.Path(id), Title: title, Updated: now}
+	readme, err := content(TemplateData{ID: id, Title: title, Date: now.Format(`2006-01-02`)})
+	if err != nil {
+		os.RemoveAll(node.Dir)
+		return nil, err
+	}
+	if err := os.WriteFile(node.ReadmePath(), []byte(readme), 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Chtimes(node.ReadmePath(), now, now); err != nil {
+		return nil, err
+	}
+	dex = dex.Upsert(DexEntry{U: now, T: title, N: id})
+	if err := WriteDex(k.Local.Path, dex); err != nil {
+		return nil, err
+	}
+	return node, k.AppendChange(DexEntry{U: now, T: title, N: id}, `created`)
+}
+
+// UpdateNode updates the entry of the node with id in both dex files
+// (see WriteDex) to its current title and last change (see scanNode),
+// appends the change (see AppendChange), and returns the updated Node.
+// Nothing is written if the entry is already current. Intended for after
+// the README.md has been edited (or touched, see Node.Touch). Returns
+// NodeNotFound if there is no such node and an error (leaving the dex
+// alone) if the title is no longer valid (see DexEntry.Validate). The
+// dex lock is held throughout (see LockDex).
+func (k *Keg) UpdateNode(id int) (*Node, error) {
+	dir := k.Path(id)
+	if !isDir(dir) {
+		return nil, NodeNotFound{id}
+	}
+	var node *Node
+	err := withDexLock(k.Local.Path, func() error {
+		dex, err := k.Dex()
+		if dex == nil {
+			return err
+		}
+		e, err := scanNode(dir)
+		if err != nil {
+			return err
+		}
+		if err := e.Validate(); err != nil {
+			return err
+		}
+		node = &Node{ID: id, Dir: dir, Title: e.T, Updated: e.U}
+		if cur := dex.Get(id); cur != nil && cur.Equal(e) {
+			return nil
+		}
+		if err := WriteDex(k.Local.Path, dex.Upsert(e)); err != nil {
+			return err
+		}
+		return appendChange(k.Local.Path, e, `updated`)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// DeleteNode calls DeleteNodeWith with the default options (removing
+// the node directory entirely).
+func (k *Keg) DeleteNode(id int) error { return k.DeleteNodeWith(id, DeleteOpts{}) }
+
+// DeleteOpts contains the options for DeleteNodeWith.
+type DeleteOpts struct {
+	Trash bool // move into trash/ directory of the keg instead of removing
+}
+
+// DeleteNodeWith deletes the node directory for id (or moves it into
+// the trash directory of the keg as trash/ID, trash/ID.1, and so on,
+// if opts.Trash), removes it from both dex files (see WriteDex), and
+// appends the change (see AppendChange). Returns NodeNotFound if there
+// is no such node and refuses to delete the zero node (see
+// ZeroNodeDelete). Other nodes might still link to the deleted node.
+// The dex lock is held throughout (see LockDex).
+func (k *Keg) DeleteNodeWith(id int, opts DeleteOpts) error {
+	if id == 0 {
+		return ZeroNodeDelete{}
+	}
+	dir := k.Path(id)
+	if !isDir(dir) {
+		return NodeNotFound{id}
+	}
+	unlock, err := LockDex(k.Local.Path, DexLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	dex, err := k.Dex()
+	if dex == nil {
+		return err
+	}
+	if opts.Trash {
+		if err := k.trash(id); err != nil {
+			return err
+		}
+	} else if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := WriteDex(k.Local.Path, dex.Remove(id)); err != nil {
+		return err
+	}
+	deleted := DexEntry{U: time.Now().UTC().Truncate(time.Second), N: id}
+	if e := dex.Get(id); e != nil {
+		deleted.T = e.T
+	}
+	return k.AppendChange(deleted, `deleted`)
+}
+
+// trash moves the node directory for id into the trash directory using
+// the first of trash/ID, trash/ID.1, trash/ID.2 (and so on) not yet
+// taken.
+func (k *Keg) trash(id int) error {
+	trash := filepath.Join(k.Local.Path, `trash`)
+	if err := os.MkdirAll(trash, 0755); err != nil {
+		return err
+	}
+	to := filepath.Join(trash, strconv.Itoa(id))
+	for n := 1; fs.Exists(to); n++ {
+		to = filepath.Join(trash, strconv.Itoa(id)+`.`+strconv.Itoa(n))
+	}
+	return os.Rename(k.Path(id), to)
+}
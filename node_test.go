@@ -0,0 +1,289 @@
+package keg_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleDexEntry_Node() {
+	entry := keg.DexEntry{N: 6}
+	node, err := entry.Node(`testdata/samplekeg`)
+	fmt.Println(node.ID, node.Title, err)
+	fmt.Println(filepath.Base(node.ReadmePath()))
+	_, err = keg.DexEntry{N: 99}.Node(`testdata/samplekeg`)
+	fmt.Println(err)
+	// Output:
+	// 6 Some title for 6 <nil>
+	// README.md
+	// node not found: 99
+}
+
+func ExampleKeg_ZeroNode() {
+	k, _ := keg.Open(`testdata/samplekeg`)
+	zero, err := k.ZeroNode()
+	fmt.Println(zero.ID, zero.Title, err)
+	// Output:
+	// 0 Sorry, planned but not yet available <nil>
+}
+
+func TestNode_ReadTitle(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	for _, c := range []struct{ readme, want string }{
+		{"# Plain\n\nBody\n", `Plain`},
+		{"\uFEFF# With BOM\r\n\r\nBody\r\n", `With BOM`},
+		{"# With CRLF   \r\n", `With CRLF`},
+		{"No leading hash\n\n# Later\n", `Later`},
+		{"\n##No space\n\nBody\n", `No space`},
+	} {
+		mkNode(t, root, `1`, c.readme, old)
+		node := keg.Node{ID: 1, Dir: filepath.Join(root, `1`)}
+		got, err := node.ReadTitle()
+		if err != nil || got != c.want {
+			t.Errorf("%q: got %q (%v) want %q", c.readme, got, err, c.want)
+		}
+	}
+}
+
+func TestNode_Files(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dir := mkNode(t, root, `1`, "# One\n", old)
+	for _, f := range []string{`b.png`, `a.txt`, `data/c.csv`} {
+		path := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := os.WriteFile(path, []byte(f), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	node := keg.Node{ID: 1, Dir: dir}
+	files, err := node.Files()
+	want := []string{`a.txt`, `b.png`, `data/c.csv`}
+	if err != nil || !reflect.DeepEqual(files, want) {
+		t.Errorf("got %v (%v) want %v", files, err, want)
+	}
+
+	if err := node.Touch(); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(node.ReadmePath())
+	if time.Since(node.Updated) > time.Minute || node.Updated.Before(info.ModTime()) {
+		t.Errorf("touch did not update time: %v %v", node.Updated, info.ModTime())
+	}
+}
+
+func TestNode_Touch(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Zero\n", old)
+	mkNode(t, root, `1`, "# One\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	k, _ := keg.Open(root)
+	node, err := k.Node(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := node.Touch(); err != nil {
+		t.Fatal(err)
+	}
+	dex, err := k.Dex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := dex.Get(1)
+	if e == nil || !e.U.Equal(node.Updated) || time.Since(e.U) > time.Minute || e.T != `One` {
+		t.Errorf("dex entry not touched: %v (node %v)", e, node.Updated)
+	}
+	if latest := dex.ByLatest(); latest[0].N != 1 {
+		t.Errorf("expected touched node latest: %v", latest)
+	}
+	if z := dex.Get(0); z == nil || !z.U.Equal(old) {
+		t.Errorf("other entry changed: %v", z)
+	}
+	if got, want := readChanges(t, root), []string{`updated 1 One`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %v want %v", got, want)
+	}
+}
+
+func TestKeg_MakeNode(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Sorry, planned but not yet available\n", old)
+	mkNode(t, root, `1`, "# One\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	k, err := keg.Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := k.MakeNode(`Two`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readme, _ := os.ReadFile(node.ReadmePath())
+	if node.ID != 2 || string(readme) != "# Two\n\n" {
+		t.Errorf("unexpected node %v: %q", node.ID, readme)
+	}
+
+	// another process already made the next one
+	if err := os.Mkdir(k.Path(3), 0700); err != nil {
+		t.Fatal(err)
+	}
+	node, err = k.MakeNode(`Four`)
+	if err != nil || node.ID != 4 {
+		t.Fatalf("expected node 4, got %v (%v)", node, err)
+	}
+
+	dex, err := keg.ReadDexTSV(filepath.Join(root, `dex`, `nodes.tsv`))
+	if err != nil || len(dex) != 4 || dex[2].T != `Two` || dex[3].N != 4 {
+		t.Errorf("unexpected nodes.tsv (%v):\n%v", err, dex)
+	}
+	latest, _ := os.ReadFile(filepath.Join(root, `dex`, `latest.md`))
+	if last := keg.Last(root); last == nil || last.N != 4 {
+		t.Errorf("unexpected latest.md:\n%s", latest)
+	}
+
+	for _, title := range []string{``, `  `, "Two\nlines"} {
+		if _, err := k.MakeNode(title); err == nil {
+			t.Errorf("expected title %q to be refused", title)
+		}
+	}
+}
+
+func TestKeg_DeleteNode(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		mkNode(t, root, fmt.Sprint(i), fmt.Sprintf("# Node %v\n", i), old)
+	}
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	k, _ := keg.Open(root)
+
+	if err := k.DeleteNode(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(k.Path(1)); !os.IsNotExist(err) {
+		t.Errorf("node 1 still exists")
+	}
+
+	for i := 0; i < 2; i++ {
+		mkNode(t, root, `2`, "# Node 2\n", old)
+		if err := k.DeleteNodeWith(2, keg.DeleteOpts{Trash: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{`2`, `2.1`} {
+		if _, err := os.Stat(filepath.Join(root, `trash`, name, `README.md`)); err != nil {
+			t.Errorf("expected trash/%v: %v", name, err)
+		}
+	}
+
+	dex, _ := k.Dex()
+	if !reflect.DeepEqual(dex.IDs(), []int{0, 3}) {
+		t.Errorf("unexpected dex after delete:\n%v", dex)
+	}
+	latest, _ := os.ReadFile(filepath.Join(root, `dex`, `latest.md`))
+	if md, _ := keg.ParseDexMD(bytes.NewReader(latest)); len(md) != 2 {
+		t.Errorf("unexpected latest.md after delete:\n%s", latest)
+	}
+
+	if err := k.DeleteNode(0); !errors.As(err, &keg.ZeroNodeDelete{}) {
+		t.Errorf("expected ZeroNodeDelete, got %v", err)
+	}
+	var notfound keg.NodeNotFound
+	if err := k.DeleteNode(99); !errors.As(err, &notfound) || notfound.N != 99 {
+		t.Errorf("expected NodeNotFound, got %v", err)
+	}
+}
+
+func TestKeg_UpdateNode(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Sorry, planned but not yet available\n", old)
+	mkNode(t, root, `1`, "# One\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	k, _ := keg.Open(root)
+
+	// nothing changed, nothing written
+	if _, err := k.UpdateNode(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, `dex`, `changes.md`)); !os.IsNotExist(err) {
+		t.Errorf("expected no change recorded: %v", err)
+	}
+
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	mkNode(t, root, `1`, "# Uno\n\nEdited.\n", now)
+	node, err := k.UpdateNode(1)
+	if err != nil || node.Title != `Uno` || !node.Updated.Equal(now) {
+		t.Fatalf("unexpected node %v (%v)", node, err)
+	}
+	dex, _ := k.Dex()
+	if e := dex.Get(1); e == nil || e.T != `Uno` || !e.U.Equal(now) {
+		t.Errorf("unexpected entry after update: %v", e)
+	}
+	changes, _ := os.ReadFile(filepath.Join(root, `dex`, `changes.md`))
+	if want := "* 2023-01-02 03:04:05Z updated [Uno](/1)\n"; string(changes) != want {
+		t.Errorf("expected change %q, got %q", want, changes)
+	}
+
+	mkNode(t, root, `1`, "\n", now)
+	if _, err := k.UpdateNode(1); err == nil {
+		t.Errorf("expected empty title to be refused")
+	}
+	if dex, _ := k.Dex(); dex.Get(1).T != `Uno` {
+		t.Errorf("expected dex left alone")
+	}
+	var notfound keg.NodeNotFound
+	if _, err := k.UpdateNode(99); !errors.As(err, &notfound) {
+		t.Errorf("expected NodeNotFound, got %v", err)
+	}
+}
+
+func TestNodeChanged(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	dir := mkNode(t, root, `1`, "# One\n", old)
+	touch := func(name string, mod time.Time) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := os.WriteFile(path, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	figure := old.Add(time.Hour)
+	touch(`figures/graph.png`, figure)
+	for _, name := range []string{`.README.md.swp`, `README.md~`, `.#README.md`} {
+		touch(name, old.Add(2*time.Hour))
+	}
+	got, err := keg.NodeChanged(dir)
+	if err != nil || !got.Equal(figure) {
+		t.Errorf("got %v (%v) want %v", got, err, figure)
+	}
+
+	// dex directory at keg root ignored
+	mkNode(t, root, `dex`, "# Not a node\n", old.Add(3*time.Hour))
+	got, err = keg.NodeChanged(root)
+	if err != nil || !got.Equal(figure) {
+		t.Errorf("keg root: got %v (%v) want %v", got, err, figure)
+	}
+}
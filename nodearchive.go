@@ -0,0 +1,113 @@
+package keg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ArchivePath returns the full path to the directory of the archived
+// node with id (see ArchiveNode).
+func (k *Keg) ArchivePath(id int) string {
+	return filepath.Join(k.Local.Path, `archive`, strconv.Itoa(id))
+}
+
+// ArchiveNode moves the node directory for id into the archive
+// directory of the keg (as archive/ID), records when in its meta file
+// (as archived, see Node.Meta), and removes it from both dex files (see
+// WriteDex) so that it can be restored later (see RestoreNode). Returns
+// NodeNotFound if there is no such node, NodeExists if a node with the
+// same ID is already archived, and refuses to archive the zero node
+// (see ZeroNodeDelete). The dex lock is held throughout (see LockDex).
+func (k *Keg) ArchiveNode(id int) error {
+	if id == 0 {
+		return ZeroNodeDelete{}
+	}
+	dir := k.Path(id)
+	if !isDir(dir) {
+		return NodeNotFound{id}
+	}
+	to := k.ArchivePath(id)
+	if _, err := os.Lstat(to); err == nil {
+		return NodeExists{id}
+	}
+	return withDexLock(k.Local.Path, func() error {
+		dex, err := k.Dex()
+		if dex == nil {
+			return err
+		}
+		node := &Node{ID: id, Dir: dir}
+		now := time.Now().UTC().Truncate(time.Second)
+		if err := node.SetMeta(`archived`, now.Format(IsoDateFmt)); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(dir, to); err != nil {
+			return err
+		}
+		if err := WriteDex(k.Local.Path, dex.Remove(id)); err != nil {
+			return err
+		}
+		archived := DexEntry{U: now, N: id}
+		if e := dex.Get(id); e != nil {
+			archived.T = e.T
+		}
+		return k.AppendChange(archived, `archived`)
+	})
+}
+
+// RestoreNode moves the archived node with id (see ArchiveNode) back
+// into the keg, removes archived from its meta file, and adds it to
+// both dex files again. Returns NodeNotFound if there is no such
+// archived node and NodeExists if the ID has since been used again
+// (in which case nothing is changed). The dex lock is held throughout
+// (see LockDex).
+func (k *Keg) RestoreNode(id int) error {
+	from := k.ArchivePath(id)
+	if !isDir(from) {
+		return NodeNotFound{id}
+	}
+	return withDexLock(k.Local.Path, func() error {
+		dex, err := k.Dex()
+		if dex == nil {
+			return err
+		}
+		if _, err := os.Lstat(k.Path(id)); err == nil || dex.Get(id) != nil {
+			return NodeExists{id}
+		}
+		if err := os.Rename(from, k.Path(id)); err != nil {
+			return err
+		}
+		node := &Node{ID: id, Dir: k.Path(id)}
+		if err := node.unsetMeta(`archived`); err != nil {
+			return err
+		}
+		e, err := scanNode(node.Dir)
+		if err != nil {
+			return err
+		}
+		if err := WriteDex(k.Local.Path, dex.Upsert(e)); err != nil {
+			return err
+		}
+		return k.AppendChange(DexEntry{U: time.Now().UTC().Truncate(time.Second), T: e.T, N: id}, `restored`)
+	})
+}
+
+// Archived returns the entries (read from the node directories, see
+// ScanDir) of every archived node (see ArchiveNode) sorted by ID. An
+// empty Dex is returned if none have been archived.
+func (k *Keg) Archived() (Dex, error) {
+	dir := filepath.Join(k.Local.Path, `archive`)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return Dex{}, nil
+	}
+	dex, err := ScanDir(dir)
+	if dex == nil {
+		return nil, err
+	}
+	return dex.ByID(), err
+}
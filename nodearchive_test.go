@@ -0,0 +1,73 @@
+package keg_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestKeg_ArchiveNode(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Zero\n", old)
+	mkNode(t, root, `1`, "# One\n", old)
+	mkNode(t, root, `2`, "# Two\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	k, _ := keg.Open(root)
+
+	if err := k.ArchiveNode(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(k.Path(1)); !os.IsNotExist(err) {
+		t.Errorf("expected node directory moved: %v", err)
+	}
+	if dex, _ := k.Dex(); !reflect.DeepEqual(dex.ByID().IDs(), []int{0, 2}) {
+		t.Errorf("expected node removed from dex: %v", dex)
+	}
+	archived, err := k.Archived()
+	if err != nil || len(archived) != 1 || archived[0].N != 1 || archived[0].T != `One` {
+		t.Errorf("unexpected archived: %v (%v)", archived, err)
+	}
+	meta, _ := (&keg.Node{ID: 1, Dir: k.ArchivePath(1)}).Meta()
+	if _, err := keg.ParseTime(meta[`archived`].(string)); err != nil {
+		t.Errorf("expected archived time in meta: %v (%v)", meta, err)
+	}
+
+	var notfound keg.NodeNotFound
+	if err := k.ArchiveNode(9); !errors.As(err, &notfound) {
+		t.Errorf("expected NodeNotFound, got %v", err)
+	}
+	if err := k.ArchiveNode(0); !errors.As(err, new(keg.ZeroNodeDelete)) {
+		t.Errorf("expected ZeroNodeDelete, got %v", err)
+	}
+
+	// reused ID blocks restore
+	mkNode(t, root, `1`, "# New One\n", old)
+	var exists keg.NodeExists
+	if err := k.RestoreNode(1); !errors.As(err, &exists) {
+		t.Errorf("expected NodeExists, got %v", err)
+	}
+	if err := os.RemoveAll(k.Path(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := k.RestoreNode(1); err != nil {
+		t.Fatal(err)
+	}
+	dex, _ := k.Dex()
+	if e := dex.Get(1); e == nil || e.T != `One` {
+		t.Errorf("expected node back in dex: %v", dex)
+	}
+	if _, err := os.Stat((&keg.Node{Dir: k.Path(1)}).MetaPath()); !os.IsNotExist(err) {
+		t.Errorf("expected empty meta removed: %v", err)
+	}
+	if archived, _ := k.Archived(); len(archived) != 0 {
+		t.Errorf("expected nothing archived: %v", archived)
+	}
+}
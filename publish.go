@@ -0,0 +1,200 @@
+package keg
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rwxrob/keg/kegml"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// DefaultPageTemplate is the html/template used by PublishHTML for every
+// page unless PublishOpts.Template is set. See PageData for the fields
+// available.
+const DefaultPageTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+</head>
+<body>
+<nav><a href="{{.Home}}">{{.Keg}}</a></nav>
+<main>
+{{.Body}}</main>
+</body>
+</html>
+`
+
+// PageData is passed to the page template by PublishHTML.
+type PageData struct {
+	Keg     string        // title (or name) of the keg
+	Title   string        // title of the node (or keg for the index)
+	N       int           // node id (-1 for the index)
+	Updated string        // when the node was updated (see IsoDateFmt)
+	Home    string        // link to the index page
+	Body    template.HTML // rendered content
+}
+
+// PublishOpts contains the options for PublishHTML.
+type PublishOpts struct {
+	BaseURL  string             // prefix for links (default relative)
+	Template *template.Template // for every page (default DefaultPageTemplate)
+	Private  bool               // also publish nodes tagged #private
+}
+
+// PublishHTML renders the keg as a static web site into destDir with
+// the README.md of every indexed node (see Dex) rendered to HTML
+// (CommonMark with GitHub extensions, raw HTML omitted) as N/index.html
+// along with copies of every other file in the node directory (see
+// Node.Files). Links to other nodes (see NodeLinkExp) are rewritten to
+// ../N/ (or BaseURL/N/). The index.html page lists every node published
+// from most to least recently updated (see Dex.HTMLWith). Nodes tagged
+// #private (see kegml.ReadTags) are skipped unless opts.Private. Every
+// page is produced by executing the template with PageData.
+func (k *Keg) PublishHTML(destDir string, opts PublishOpts) error {
+	tmpl := opts.Template
+	if tmpl == nil {
+		tmpl = template.Must(template.New(`page`).Parse(DefaultPageTemplate))
+	}
+	dex, err := k.Dex()
+	if dex == nil {
+		return err
+	}
+	name := k.Local.Name
+	if k.Info != nil && k.Info.Title != "" {
+		name = k.Info.Title
+	}
+	base := strings.TrimSuffix(opts.BaseURL, `/`)
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	published := Dex{}
+	for _, e := range dex.ByID() {
+		dir := k.Path(e.N)
+		readme := filepath.Join(dir, `README.md`)
+		byt, err := os.ReadFile(readme)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if !opts.Private && hasTag(kegml.TagsIn(string(byt)), `private`) {
+			continue
+		}
+		prefix, home := `..`, `../`
+		if base != "" {
+			prefix, home = base, base+`/`
+		}
+		var body bytes.Buffer
+		if err := md.Convert([]byte(rewriteLinks(string(byt), prefix)), &body); err != nil {
+			return err
+		}
+		out := filepath.Join(destDir, strconv.Itoa(e.N))
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return err
+		}
+		page := PageData{
+			Keg: name, Title: e.T, N: e.N, Updated: e.U.UTC().Format(IsoDateFmt),
+			Home: home, Body: template.HTML(body.String()),
+		}
+		if err := writePage(filepath.Join(out, `index.html`), tmpl, page); err != nil {
+			return err
+		}
+		files, err := (&Node{ID: e.N, Dir: dir}).Files()
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if err := copyFile(filepath.Join(dir, f), filepath.Join(out, f)); err != nil {
+				return err
+			}
+		}
+		published = append(published, e)
+	}
+	prefix, home := `.`, `./`
+	if base != "" {
+		prefix, home = base, base+`/`
+	}
+	list := published.ByLatest().HTMLWith(HTMLOpts{BaseURL: prefix})
+	page := PageData{
+		Keg: name, Title: name, N: -1, Home: home,
+		Body: template.HTML("<h1>" + template.HTMLEscapeString(name) + "</h1>\n" + list),
+	}
+	if latest := published.ByLatest(); len(latest) > 0 {
+		page.Updated = latest[0].U.UTC().Format(IsoDateFmt)
+	}
+	return writePage(filepath.Join(destDir, `index.html`), tmpl, page)
+}
+
+// rewriteLinks returns the KEGML text with every link to another node
+// (see NodeLinkExp) outside of fenced code blocks pointing to prefix/N/
+// instead.
+func rewriteLinks(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	var fence string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) &&
+				strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if f := fenceOpen(trimmed); f != "" {
+			fence = f
+			continue
+		}
+		lines[i] = NodeLinkExp.ReplaceAllStringFunc(line, func(link string) string {
+			id := NodeLinkExp.FindStringSubmatch(link)[1]
+			rest := link[strings.Index(link, id)+len(id):] // keeps any title
+			return `](` + prefix + `/` + id + `/` + strings.TrimPrefix(rest, `/`)
+		})
+	}
+	return strings.Join(lines, "\n")
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func writePage(path string, tmpl *template.Template, page PageData) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, page); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.String())
+}
+
+// copyFile copies the file at from to the path to (creating any
+// directories needed).
+func copyFile(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,81 @@
+package keg_test
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rwxrob/keg"
+)
+
+// sameTree reports every difference between the files within the got
+// and want directories (copying got to want first if updateGolden).
+func sameTree(t *testing.T, got, want string) {
+	t.Helper()
+	files := func(root string) map[string]string {
+		m := map[string]string{}
+		filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				rel, _ := filepath.Rel(root, p)
+				byt, _ := os.ReadFile(p)
+				m[rel] = string(byt)
+			}
+			return nil
+		})
+		return m
+	}
+	g := files(got)
+	if *updateGolden {
+		os.RemoveAll(want)
+		for rel, content := range g {
+			os.MkdirAll(filepath.Dir(filepath.Join(want, rel)), 0755)
+			os.WriteFile(filepath.Join(want, rel), []byte(content), 0644)
+		}
+	}
+	w := files(want)
+	for rel, content := range w {
+		if g[rel] != content {
+			t.Errorf("%v: got:\n%s\nwant:\n%s", rel, g[rel], content)
+		}
+	}
+	for rel := range g {
+		if _, has := w[rel]; !has {
+			t.Errorf("unexpected file: %v", rel)
+		}
+	}
+}
+
+func TestKeg_PublishHTML(t *testing.T) {
+	k, err := keg.Open(`testdata/publishkeg`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	if err := k.PublishHTML(dest, keg.PublishOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	sameTree(t, dest, `testdata/publish`)
+}
+
+func TestKeg_PublishHTML_opts(t *testing.T) {
+	k, err := keg.Open(`testdata/publishkeg`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	tmpl := template.Must(template.New(`t`).Parse(`{{.N}} {{.Title}} {{.Home}}` + "\n{{.Body}}"))
+	opts := keg.PublishOpts{BaseURL: `https://example.com/keg/`, Template: tmpl, Private: true}
+	if err := k.PublishHTML(dest, opts); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := os.ReadFile(filepath.Join(dest, `3`, `index.html`))
+	if err != nil || !strings.HasPrefix(string(secret), "3 Secret https://example.com/keg/\n") {
+		t.Errorf("private node not published with template: %q (%v)", secret, err)
+	}
+	first, _ := os.ReadFile(filepath.Join(dest, `1`, `index.html`))
+	if !strings.Contains(string(first), `<a href="https://example.com/keg/2/">`) {
+		t.Errorf("link not rewritten with base URL:\n%s", first)
+	}
+}
@@ -0,0 +1,79 @@
+package keg
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RandomOpts contains the options for RandomWith.
+type RandomOpts struct {
+	Rand  *rand.Rand // source of randomness (default math/rand global)
+	Stale bool       // weight each entry by how long since it was updated
+	Now   time.Time  // time that Stale age is based on (default time.Now)
+}
+
+// Random returns a pointer to a copy of a single entry chosen at random
+// or nil if the Dex is empty.
+func (d Dex) Random() *DexEntry {
+	dex := d.RandomWith(1, RandomOpts{})
+	if len(dex) == 0 {
+		return nil
+	}
+	return &dex[0]
+}
+
+// RandomN returns RandomWith n and default options.
+func (d Dex) RandomN(n int) Dex { return d.RandomWith(n, RandomOpts{}) }
+
+// RandomWith returns up to n different entries chosen at random (never
+// more than the length of the Dex and an empty Dex for n <= 0). When
+// Stale is set each entry is given a weight equal to the number of
+// seconds since it was last updated (and never less than one) so that
+// the least recently updated are most likely to be chosen (for spaced
+// review, for example).
+func (d Dex) RandomWith(n int, opts RandomOpts) Dex {
+	intn := rand.Intn
+	if opts.Rand != nil {
+		intn = opts.Rand.Intn
+	}
+	if n > len(d) {
+		n = len(d)
+	}
+	if n <= 0 {
+		return Dex{}
+	}
+	pool := d.Clone()
+	if !opts.Stale {
+		for i := len(pool) - 1; i > 0; i-- {
+			j := intn(i + 1)
+			pool[i], pool[j] = pool[j], pool[i]
+		}
+		return pool[:n]
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	weights := make([]int, len(pool))
+	var total int
+	for i, e := range pool {
+		weights[i] = int(opts.Now.Sub(e.U) / time.Second)
+		if weights[i] < 1 {
+			weights[i] = 1
+		}
+		total += weights[i]
+	}
+	dex := make(Dex, 0, n)
+	for len(dex) < n {
+		pick := intn(total)
+		for i, w := range weights {
+			if pick < w {
+				dex = append(dex, pool[i])
+				total -= w
+				weights[i] = 0
+				break
+			}
+			pick -= w
+		}
+	}
+	return dex
+}
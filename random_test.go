@@ -0,0 +1,34 @@
+package keg_test
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleDex_RandomWith() {
+	now := time.Date(2023, 1, 14, 9, 12, 5, 0, time.UTC)
+	dex := keg.Dex{
+		{U: now.AddDate(-5, 0, 0), N: 1, T: `Ancient`},
+		{U: now.Add(-time.Minute), N: 2, T: `Fresh`},
+		{U: now.Add(-time.Second), N: 3, T: `Fresher`},
+	}
+	opts := keg.RandomOpts{Rand: rand.New(rand.NewSource(1)), Stale: true, Now: now}
+	stale := 0
+	for i := 0; i < 100; i++ {
+		if dex.RandomWith(1, opts)[0].N == 1 {
+			stale++
+		}
+	}
+	fmt.Println(stale)
+	fmt.Println(len(dex.RandomWith(2, opts)), len(dex.RandomWith(2, opts).Dedup()))
+	fmt.Println(len(dex.RandomN(5)), len(dex.RandomN(0)))
+	fmt.Println(keg.Dex{}.Random(), dex.Random() != nil)
+	// Output:
+	// 100
+	// 2 2
+	// 3 0
+	// <nil> true
+}
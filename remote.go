@@ -0,0 +1,307 @@
+package keg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FetchTimeout is the most time FetchDex (and the other fetches of
+// remote kegs) will wait for each response.
+var FetchTimeout = 30 * time.Second
+
+// FetchCacheDir is the directory in which fetched remote files and their
+// validators (ETag and Last-Modified) are cached along with the nodes
+// fetched by FetchNode (in a directory per remote keg). If empty keg
+// within os.UserCacheDir (usually $XDG_CACHE_HOME/keg) is used (and
+// nothing is cached if there is none).
+var FetchCacheDir string
+
+func fetchCacheDir() string {
+	if FetchCacheDir != "" {
+		return FetchCacheDir
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, `keg`)
+	}
+	return ""
+}
+
+// FetchDex fetches the index of the keg published at baseURL (see
+// Remote) from dex/nodes.tsv falling back to dex/latest.md if not found
+// and parses it leniently (see ReadDex). Any error returned with
+// a non-nil Dex is Errors of BadDexLine warnings. Responses are cached
+// (see FetchCacheDir) and requested again conditionally so that an
+// unchanged index is not downloaded again.
+func FetchDex(ctx context.Context, baseURL string) (Dex, error) {
+	var warnings Errors
+	warn := func(err error) error {
+		warnings = append(warnings, err)
+		return nil
+	}
+	for _, f := range []struct {
+		name  string
+		parse func(io.Reader, func(error) error) (Dex, error)
+	}{{`nodes.tsv`, parseDexTSV}, {`latest.md`, parseDexMD}} {
+		byt, err := fetch(ctx, remoteURL(baseURL, `dex/`+f.name))
+		if err != nil {
+			return nil, err
+		}
+		if byt == nil {
+			continue // not found
+		}
+		dex, err := f.parse(bytes.NewReader(byt), warn)
+		if err != nil {
+			return nil, err
+		}
+		if len(warnings) > 0 {
+			return dex, warnings
+		}
+		return dex, nil
+	}
+	return nil, fmt.Errorf("no dex found at %v", baseURL)
+}
+
+// FetchOpts contains the options for FetchNodeWith.
+type FetchOpts struct {
+	Attachments bool // also fetch files linked relative to the README.md
+}
+
+// FetchNode returns FetchNodeWith using the default options (only the
+// README.md).
+func FetchNode(ctx context.Context, baseURL string, id int) (*Node, error) {
+	return FetchNodeWith(ctx, baseURL, id, FetchOpts{})
+}
+
+// FetchNodeWith fetches the README.md of the node with id from the keg
+// published at baseURL into a directory of its own within FetchCacheDir
+// (named for the SHA-256 of baseURL followed by the node ID) and
+// returns it as a Node with that Dir. With opts.Attachments every file
+// linked to relative to the node by the README.md is also fetched
+// (those not found are skipped). The remote index is fetched first (see
+// FetchDex) and the cached copy used without fetching anything else if
+// it is no older than the index says the node is. If the remote keg
+// cannot be reached at all the cached copy (if any) is returned anyway
+// with Stale set. Returns NodeNotFound if the node is not in the remote
+// index.
+func FetchNodeWith(ctx context.Context, baseURL string, id int, opts FetchOpts) (*Node, error) {
+	cache := fetchCacheDir()
+	if cache == "" {
+		return nil, fmt.Errorf("no cache directory for remote nodes")
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSuffix(baseURL, `/`)))
+	dir := filepath.Join(cache, hex.EncodeToString(sum[:]), strconv.Itoa(id))
+	readme := filepath.Join(dir, `README.md`)
+
+	stale := func(err error) (*Node, error) {
+		info, serr := os.Stat(readme)
+		if serr != nil {
+			return nil, err
+		}
+		title, _ := readNodeTitle(readme)
+		return &Node{ID: id, Dir: dir, Title: title, Updated: info.ModTime(), Stale: true}, nil
+	}
+
+	dex, err := FetchDex(ctx, baseURL)
+	if dex == nil {
+		return stale(err)
+	}
+	e := dex.Get(id)
+	if e == nil {
+		return nil, NodeNotFound{id}
+	}
+	node := &Node{ID: id, Dir: dir, Title: e.T, Updated: e.U}
+	if cachedFresh(readme, e.U, opts.Attachments) {
+		return node, nil
+	}
+
+	nodeURL := remoteURL(baseURL, strconv.Itoa(id)+`/`)
+	files := map[string][]byte{}
+	res, byt, err := get(ctx, nodeURL+`README.md`, nil)
+	if err != nil {
+		return stale(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%vREADME.md: %v", nodeURL, res.Status)
+	}
+	files[`README.md`] = byt
+	if opts.Attachments {
+		for _, name := range linkedFiles(string(byt)) {
+			res, byt, err := get(ctx, nodeURL+name, nil)
+			if err != nil {
+				return stale(err)
+			}
+			switch res.StatusCode {
+			case http.StatusOK:
+				files[name] = byt
+			case http.StatusNotFound, http.StatusGone:
+			default:
+				return nil, fmt.Errorf("%v%v: %v", nodeURL, name, res.Status)
+			}
+		}
+	}
+
+	for name, byt := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(path, string(byt)); err != nil {
+			return nil, err
+		}
+	}
+	return node, os.Chtimes(readme, e.U, e.U)
+}
+
+// cachedFresh returns true if the cached README.md at readme was last
+// changed no earlier than updated (see FetchNodeWith) and, if
+// attachments, every file it links to is also cached.
+func cachedFresh(readme string, updated time.Time, attachments bool) bool {
+	info, err := os.Stat(readme)
+	if err != nil || info.ModTime().Before(updated) {
+		return false
+	}
+	if !attachments {
+		return true
+	}
+	byt, err := os.ReadFile(readme)
+	if err != nil {
+		return false
+	}
+	for _, name := range linkedFiles(string(byt)) {
+		if _, err := os.Stat(filepath.Join(filepath.Dir(readme), filepath.FromSlash(name))); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+var linkTargetExp = regexp.MustCompile(`\]\(([^\s)]+)(?:\s+"[^"]*")?\)`)
+
+// linkedFiles returns the paths (slash separated, cleaned, sorted, and
+// without duplicates) of every file linked to (or included as an image)
+// by the KEGML text relative to the node directory itself. Links with
+// a scheme, root relative links, links to other nodes, and links
+// leaving the node directory are not attachments. Any query or fragment
+// is dropped.
+func linkedFiles(text string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range linkTargetExp.FindAllStringSubmatch(text, -1) {
+		target := m[1]
+		if i := strings.IndexAny(target, `?#`); i >= 0 {
+			target = target[:i]
+		}
+		if target == "" || strings.HasPrefix(target, `/`) ||
+			strings.Contains(target, `:`) || strings.Contains(target, `\`) {
+			continue
+		}
+		name := path.Clean(target)
+		if name == `.` || name == `..` || strings.HasPrefix(name, `../`) ||
+			name == `README.md` || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// remoteURL joins base URL and the slash separated path.
+func remoteURL(base, path string) string {
+	return strings.TrimSuffix(base, `/`) + `/` + strings.TrimPrefix(path, `/`)
+}
+
+// cached contains the validators of a cached response (see fetch).
+type cached struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// fetch returns the body of url (using the cached copy if the server
+// says it has not been modified) or nil if not found. Any other status
+// is an error.
+func fetch(ctx context.Context, url string) ([]byte, error) {
+	var cache string
+	var meta cached
+	header := map[string]string{}
+	dir := fetchCacheDir()
+	if dir != "" {
+		sum := sha256.Sum256([]byte(url))
+		cache = filepath.Join(dir, hex.EncodeToString(sum[:]))
+		if byt, err := os.ReadFile(cache + `.json`); err == nil && json.Unmarshal(byt, &meta) == nil {
+			if meta.ETag != "" {
+				header[`If-None-Match`] = meta.ETag
+			}
+			if meta.LastModified != "" {
+				header[`If-Modified-Since`] = meta.LastModified
+			}
+		}
+	}
+	res, byt, err := get(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		if cache != "" {
+			if byt, err := os.ReadFile(cache); err == nil {
+				return byt, nil
+			}
+		}
+		return nil, fmt.Errorf("%v: not modified but not cached", url)
+	case http.StatusNotFound, http.StatusGone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%v: %v", url, res.Status)
+	}
+	if cache != "" {
+		meta = cached{res.Header.Get(`ETag`), res.Header.Get(`Last-Modified`)}
+		if meta != (cached{}) && os.MkdirAll(dir, 0700) == nil {
+			validators, _ := json.Marshal(meta)
+			if writeFileAtomic(cache, string(byt)) == nil {
+				writeFileAtomic(cache+`.json`, string(validators))
+			}
+		}
+	}
+	return byt, nil
+}
+
+// get requests url with the header (waiting no more than FetchTimeout)
+// and returns the response with its body already read (and closed).
+func get(ctx context.Context, url string, header map[string]string) (*http.Response, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, FetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	byt, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, byt, nil
+}
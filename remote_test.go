@@ -0,0 +1,132 @@
+package keg_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestFetchDex(t *testing.T) {
+	keg.FetchCacheDir = t.TempDir()
+	t.Cleanup(func() { keg.FetchCacheDir = `` })
+	tsv := "1\t2022-12-10 06:10:04Z\tOne\n" +
+		"3\tnot a time\tBad\n" +
+		"2\t2022-12-11 06:10:04Z\tTwo\n"
+	var full, notmod int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case `/mine/dex/nodes.tsv`:
+			if r.Header.Get(`If-None-Match`) == `"v1"` {
+				atomic.AddInt32(&notmod, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			atomic.AddInt32(&full, 1)
+			w.Header().Set(`ETag`, `"v1"`)
+			w.Write([]byte(tsv))
+		case `/other/dex/latest.md`:
+			w.Write([]byte("* 2022-12-11 06:10:04Z [Two](/2)\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	for i := 0; i < 2; i++ {
+		dex, err := keg.FetchDex(context.Background(), srv.URL+`/mine/`)
+		var bad keg.BadDexLine
+		if len(dex) != 2 || dex[1].T != `Two` || !errors.As(err, &bad) || bad.Line != 2 {
+			t.Errorf("unexpected dex: %v (%v)", dex, err)
+		}
+	}
+	if full != 1 || notmod != 1 {
+		t.Errorf("expected one full and one conditional request, got %v and %v", full, notmod)
+	}
+
+	dex, err := keg.FetchDex(context.Background(), srv.URL+`/other`)
+	if err != nil || len(dex) != 1 || dex[0].N != 2 {
+		t.Errorf("latest.md not used: %v (%v)", dex, err)
+	}
+	if _, err := keg.FetchDex(context.Background(), srv.URL+`/none`); err == nil ||
+		!strings.Contains(err.Error(), `no dex found`) {
+		t.Errorf("expected no dex found, got %v", err)
+	}
+}
+
+func TestRemotes(t *testing.T) {
+	useConf(t, "map:\n"+
+		"  local: testdata/samplekeg\n"+
+		"  rwxrob: https://rwxrob.github.io/zet\n")
+	remotes, err := keg.Remotes()
+	if err != nil || len(remotes) != 1 || remotes[0].Name != `rwxrob` {
+		t.Errorf("unexpected remotes: %v (%v)", remotes, err)
+	}
+	locals, _ := keg.Locals()
+	if len(locals) != 1 || locals[0].Name != `local` {
+		t.Errorf("remote returned by Locals: %v", locals)
+	}
+	var notfound keg.RemoteNotFound
+	if _, err := keg.LookupRemote(`local`); !errors.As(err, &notfound) {
+		t.Errorf("expected RemoteNotFound, got %v", err)
+	}
+}
+
+func TestFetchNode(t *testing.T) {
+	keg.FetchCacheDir = t.TempDir()
+	t.Cleanup(func() { keg.FetchCacheDir = `` })
+	tsv := "1\t2022-12-10 06:10:04Z\tOne\n"
+	readme := "# One\n\n![pic](pic.png) [two](/2) [site](https://example.com) [up](../x)\n"
+	var readmes int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case `/dex/nodes.tsv`:
+			w.Write([]byte(tsv))
+		case `/1/README.md`:
+			atomic.AddInt32(&readmes, 1)
+			w.Write([]byte(readme))
+		case `/1/pic.png`:
+			w.Write([]byte(`png`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	node, err := keg.FetchNodeWith(ctx, srv.URL, 1, keg.FetchOpts{Attachments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Title != `One` || node.Stale || node.Updated.Year() != 2022 {
+		t.Errorf("unexpected node: %+v", node)
+	}
+	files, _ := node.Files()
+	if len(files) != 1 || files[0] != `pic.png` {
+		t.Errorf("expected only pic.png attached, got %v", files)
+	}
+
+	if _, err := keg.FetchNode(ctx, srv.URL, 1); err != nil || readmes != 1 {
+		t.Errorf("expected cached README.md, fetched %v times (%v)", readmes, err)
+	}
+	var notfound keg.NodeNotFound
+	if _, err := keg.FetchNode(ctx, srv.URL, 9); !errors.As(err, &notfound) {
+		t.Errorf("expected NodeNotFound, got %v", err)
+	}
+
+	tsv = "1\t2022-12-12 06:10:04Z\tOne\n"
+	if _, err := keg.FetchNode(ctx, srv.URL, 1); err != nil || readmes != 2 {
+		t.Errorf("expected changed README.md fetched again, fetched %v times (%v)", readmes, err)
+	}
+
+	srv.Close()
+	node, err = keg.FetchNode(ctx, srv.URL, 1)
+	if err != nil || !node.Stale || node.Title != `One` {
+		t.Errorf("expected stale cached node, got %+v (%v)", node, err)
+	}
+}
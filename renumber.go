@@ -0,0 +1,175 @@
+package keg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Renumbered is the summary of a RenumberNodeSummary.
+type Renumbered struct {
+	Old, New int
+	Links    int   // number of links rewritten
+	Nodes    []int // nodes with links rewritten (sorted, by new ID)
+}
+
+// String fulfills the fmt.Stringer interface as a single line summary.
+func (r Renumbered) String() string {
+	return fmt.Sprintf("renumbered %v to %v (%v links rewritten in %v nodes)",
+		r.Old, r.New, r.Links, len(r.Nodes))
+}
+
+// RenumberNode calls RenumberNodeSummary and drops the summary.
+func (k *Keg) RenumberNode(old, next int) error {
+	_, err := k.RenumberNodeSummary(old, next)
+	return err
+}
+
+// RenumberNodeSummary moves the node directory for old to next, updates
+// both dex files (see WriteDex), and rewrites every link to old (see
+// NodeLinkExp) outside of fenced code blocks in the README.md of every
+// node linking to it (see Links) to link to next instead keeping the
+// same form (/N or ../N) and any title. Nodes with links rewritten are
+// updated in the dex as well. The changes (renumbered for the node
+// moved and updated for those with links rewritten) are appended to
+// the changes file (see AppendChange). Returns NodeNotFound if there is
+// no node old, NodeExists if next is already in use (by a node
+// directory or the index), BadID if next is negative, and refuses to
+// move the zero node (see ZeroNodeDelete). The dex lock is held
+// throughout (see LockDex).
+func (k *Keg) RenumberNodeSummary(old, next int) (Renumbered, error) {
+	r := Renumbered{Old: old, New: next, Nodes: []int{}}
+	switch {
+	case old == 0:
+		return r, ZeroNodeDelete{}
+	case next < 0:
+		return r, BadID{next}
+	case !isDir(k.Path(old)):
+		return r, NodeNotFound{old}
+	}
+	err := withDexLock(k.Local.Path, func() error {
+		dex, err := k.Dex()
+		if dex == nil {
+			return err
+		}
+		if _, err := os.Lstat(k.Path(next)); err == nil || dex.Get(next) != nil {
+			return NodeExists{next}
+		}
+		links, err := k.Links()
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(k.Path(old), k.Path(next)); err != nil {
+			return err
+		}
+		var changes []Change
+		if e := dex.Get(old); e != nil {
+			moved := *e
+			moved.N = next
+			dex = dex.Remove(old).Upsert(moved)
+			moved.U = time.Now().UTC().Truncate(time.Second)
+			changes = append(changes, Change{DexEntry: moved, Action: `renumbered`})
+		}
+		from := map[int]bool{}
+		for _, l := range links {
+			if l.Keg == "" && l.To == old {
+				from[l.From] = true
+			}
+		}
+		for id := range from {
+			if id == old {
+				id = next
+			}
+			n, err := rewriteReadme(k.Path(id), map[int]int{old: next})
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				continue
+			}
+			r.Links += n
+			r.Nodes = append(r.Nodes, id)
+			if e := dex.Get(id); e != nil {
+				scanned, err := scanNode(k.Path(id))
+				if err != nil {
+					return err
+				}
+				updated := DexEntry{U: scanned.U, T: e.T, N: id}
+				dex = dex.Upsert(updated)
+				changes = append(changes, Change{DexEntry: updated, Action: `updated`})
+			}
+		}
+		if err := WriteDex(k.Local.Path, dex); err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if err := appendChange(k.Local.Path, c.DexEntry, c.Action); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	sort.Ints(r.Nodes)
+	return r, err
+}
+
+// rewriteReadme rewrites the README.md in the node directory dir with
+// renumberLinks returning the number of links rewritten. The file is
+// left alone if there are none.
+func rewriteReadme(dir string, ids map[int]int) (int, error) {
+	readme := filepath.Join(dir, `README.md`)
+	byt, err := os.ReadFile(readme)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	text, n := renumberLinks(string(byt), ids)
+	if n == 0 {
+		return 0, nil
+	}
+	return n, writeFileAtomic(readme, text)
+}
+
+// renumberLinks returns the KEGML text with every link to another node
+// (see NodeLinkExp) outside of fenced code blocks with an ID in ids
+// linking to the ID it maps to instead along with how many were
+// rewritten.
+func renumberLinks(text string, ids map[int]int) (string, int) {
+	var count int
+	lines := strings.Split(text, "\n")
+	var fence string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) &&
+				strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if f := fenceOpen(trimmed); f != "" {
+			fence = f
+			continue
+		}
+		lines[i] = NodeLinkExp.ReplaceAllStringFunc(line, func(link string) string {
+			m := NodeLinkExp.FindStringSubmatchIndex(link)
+			id, err := strconv.Atoi(link[m[2]:m[3]])
+			if err != nil {
+				return link
+			}
+			to, has := ids[id]
+			if !has {
+				return link
+			}
+			count++
+			return link[:m[2]] + strconv.Itoa(to) + link[m[3]:]
+		})
+	}
+	return strings.Join(lines, "\n"), count
+}
@@ -0,0 +1,87 @@
+package keg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/rwxrob/keg"
+)
+
+// copyKeg copies the fixture keg directory at src into a temporary
+// directory and returns the path to the copy.
+func copyKeg(t *testing.T, src string) string {
+	t.Helper()
+	dst := t.TempDir()
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		to := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(to, 0700)
+		}
+		byt, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(to, byt, 0600)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dst
+}
+
+func TestKeg_RenumberNode(t *testing.T) {
+	k, err := keg.Open(copyKeg(t, `testdata/renumberkeg`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exists keg.NodeExists
+	if err := k.RenumberNode(1, 2); !errors.As(err, &exists) {
+		t.Errorf("expected NodeExists, got %v", err)
+	}
+	var notfound keg.NodeNotFound
+	if err := k.RenumberNode(9, 10); !errors.As(err, &notfound) {
+		t.Errorf("expected NodeNotFound, got %v", err)
+	}
+
+	r, err := k.RenumberNodeSummary(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != `renumbered 1 to 10 (4 links rewritten in 3 nodes)` {
+		t.Errorf("unexpected summary: %v", r)
+	}
+	for id, want := range map[int]string{
+		0:  "# Zero\n\nStart at [one](/10).\n",
+		10: "# One\n\nMe again: [one](../10/ \"self\") and [two](/2).\n",
+		2:  "# Two\n\nBack to [one](/10) and [one](/10).\n\n```\n[not a link](/1)\n```\n\nNot [ten](/10).\n",
+		3:  "# Three\n\nNo links.\n",
+	} {
+		byt, _ := os.ReadFile(filepath.Join(k.Path(id), `README.md`))
+		if string(byt) != want {
+			t.Errorf("%v: got:\n%s\nwant:\n%v", id, byt, want)
+		}
+	}
+	dex, _ := k.Dex()
+	if dex.Get(1) != nil || dex.Get(10) == nil || dex.Get(10).T != `One` {
+		t.Errorf("unexpected dex:\n%v", dex)
+	}
+	if _, err := os.Stat(k.Path(1)); !os.IsNotExist(err) {
+		t.Errorf("expected old directory gone: %v", err)
+	}
+	want := []string{`renumbered 10 One`, `updated 0 Zero`, `updated 2 Two`, `updated 10 One`}
+	got := readChanges(t, k.Local.Path)
+	sort.Strings(got[1:]) // links rewritten in map order
+	sort.Strings(want[1:])
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %v want %v", got, want)
+	}
+}
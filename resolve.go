@@ -0,0 +1,81 @@
+package keg
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// linkFormExp matches a whole node link target (see ResolveLink)
+// capturing the keg name (of either form) and the node ID.
+var linkFormExp = regexp.MustCompile(`^(?:keg:([\w.-]+)/|/@([\w.-]+)/|(?:\.\.)?/)(\d+)/?$`)
+
+// ResolveLink parses the target of a link to a node (with or without
+// the surrounding parentheses) returning the name of the keg linked to
+// and the node ID. Links to other kegs are either keg:name/N or /@name/N
+// (see KegLinkExp). Links within the same keg (/N or ../N) return an
+// empty kegName. A trailing slash is allowed on any of them.
+func ResolveLink(link string) (kegName string, id int, err error) {
+	target := strings.TrimSpace(link)
+	if strings.HasPrefix(target, `(`) && strings.HasSuffix(target, `)`) {
+		target = strings.TrimSpace(target[1 : len(target)-1])
+	}
+	m := linkFormExp.FindStringSubmatch(target)
+	if m == nil {
+		return "", 0, fmt.Errorf("invalid node link: %q", link)
+	}
+	id, err = strconv.Atoi(m[3])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid node link: %q", link)
+	}
+	return m[1] + m[2], id, nil
+}
+
+// Resolve returns the Node linked to by link (see ResolveLink). Links
+// within the same keg (or naming the keg itself) are read from the keg
+// (see Keg.Node). Otherwise the keg is looked up by name in Locals and
+// then Remotes (see FetchNode) and UnknownKeg returned if it is in
+// neither.
+func (k *Keg) Resolve(link string) (*Node, error) {
+	name, id, err := ResolveLink(link)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || name == k.Local.Name {
+		return k.Node(id)
+	}
+	locals, err := Locals()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range locals {
+		if l.Name == name {
+			other, err := Open(l.Path)
+			if err != nil {
+				return nil, err
+			}
+			return other.Node(id)
+		}
+	}
+	remotes, err := Remotes()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range remotes {
+		if r.Name == name {
+			return FetchNode(context.Background(), r.URL, id)
+		}
+	}
+	known := make([]string, 0, len(locals)+len(remotes))
+	for _, l := range locals {
+		known = append(known, l.Name)
+	}
+	for _, r := range remotes {
+		known = append(known, r.Name)
+	}
+	sort.Strings(known)
+	return nil, UnknownKeg{Name: name, Known: known}
+}
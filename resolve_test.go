@@ -0,0 +1,79 @@
+package keg_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleResolveLink() {
+	for _, link := range []string{`keg:rwxrob/1234`, `(/@rwxrob/1234/)`, `../12`, `/3`, `rwxrob/1`} {
+		name, id, err := keg.ResolveLink(link)
+		fmt.Printf("%q %v %v\n", name, id, err)
+	}
+	// Output:
+	// "rwxrob" 1234 <nil>
+	// "rwxrob" 1234 <nil>
+	// "" 12 <nil>
+	// "" 3 <nil>
+	// "" 0 invalid node link: "rwxrob/1"
+}
+
+func ExampleParseKegLinks() {
+	in := "[here](/1) [there](keg:other/2) [also](/@other/3/ \"title\")\n" +
+		"```\n[code](keg:other/4)\n```\n"
+	links, err := keg.ParseKegLinks(strings.NewReader(in))
+	fmt.Printf("%+v %v\n", links, err)
+	// Output:
+	// [{From:0 To:1 Line:1 Keg:} {From:0 To:2 Line:1 Keg:other} {From:0 To:3 Line:1 Keg:other}] <nil>
+}
+
+func TestKeg_Resolve(t *testing.T) {
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mine, other := mkKeg(t), mkKeg(t)
+	mkNode(t, mine, `1`, "# Mine\n\n[Theirs](keg:other/2) [gone](/@other/9) [who](keg:nobody/1)\n", old)
+	mkNode(t, mine, `2`, "# Mine too\n\n[remote](keg:web/5)\n", old)
+	mkNode(t, other, `2`, "# Theirs\n\nBack to [mine](/@mine/1).\n", old)
+	useConf(t, "map:\n  mine: "+mine+"\n  other: "+other+"\n  web: https://example.com/keg\n")
+	k, err := keg.Open(mine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.Local.Name = `mine`
+
+	node, err := k.Resolve(`keg:other/2`)
+	if err != nil || node.Title != `Theirs` {
+		t.Errorf("unexpected node: %+v (%v)", node, err)
+	}
+	if node, err := k.Resolve(`/@mine/1`); err != nil || node.Title != `Mine` {
+		t.Errorf("unexpected node: %+v (%v)", node, err)
+	}
+	var unknown keg.UnknownKeg
+	if _, err := k.Resolve(`keg:nobody/1`); !errors.As(err, &unknown) ||
+		!reflect.DeepEqual(unknown.Known, []string{`mine`, `other`, `web`}) {
+		t.Errorf("expected UnknownKeg listing known kegs, got %v", err)
+	}
+
+	broken, err := k.BrokenLinks()
+	want := []keg.BrokenLink{
+		{From: 1, Line: 3, To: 9, Keg: `other`},
+		{From: 1, Line: 3, To: 1, Keg: `nobody`},
+	}
+	if err != nil || !reflect.DeepEqual(broken, want) {
+		t.Errorf("got %v (%v) want %v", broken, err, want)
+	}
+	if broken[0].String() != `1:3: broken link to keg:other/9` {
+		t.Errorf("unexpected string: %v", broken[0])
+	}
+
+	back, err := k.KegBacklinks(1)
+	wantback := map[string][]keg.Link{`other`: {{From: 2, To: 1, Line: 3, Keg: `mine`}}}
+	if err != nil || !reflect.DeepEqual(back, wantback) {
+		t.Errorf("got %v (%v) want %v", back, err, wantback)
+	}
+}
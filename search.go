@@ -0,0 +1,241 @@
+package keg
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchTitleBoost is how many times more a term in the title of a node
+// counts toward its rank (see Keg.Search) than one in its README.md.
+var SearchTitleBoost = 5
+
+// searchIndexVersion is changed whenever searchIndex is so that older
+// dex/search.idx files are rebuilt rather than misread.
+const searchIndexVersion = 1
+
+// searchIndex is the inverted index of the keg saved (gob encoded) to
+// dex/search.idx (see Keg.BuildSearchIndex).
+type searchIndex struct {
+	Version int
+	Dex     Dex                    // entries as last indexed
+	Terms   map[string]map[int]int // term to node ID to count in README.md
+	Titles  map[string]map[int]int // term to node ID to count in title
+	Nodes   map[int][]string       // every term of each node (see remove)
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		Version: searchIndexVersion,
+		Dex:     Dex{},
+		Terms:   map[string]map[int]int{},
+		Titles:  map[string]map[int]int{},
+		Nodes:   map[int][]string{},
+	}
+}
+
+// searchTerms returns the lowercase words (letters and digits) of text.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// add indexes the title of the entry and the README.md of its node
+// directory (if any) within the keg at kegpath.
+func (x *searchIndex) add(kegpath string, e DexEntry) error {
+	byt, err := os.ReadFile(filepath.Join(kegpath, e.ID(), `README.md`))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	seen := map[string]bool{}
+	count := func(terms map[string]map[int]int, text string) {
+		for _, term := range searchTerms(text) {
+			if terms[term] == nil {
+				terms[term] = map[int]int{}
+			}
+			terms[term][e.N]++
+			if !seen[term] {
+				seen[term] = true
+				x.Nodes[e.N] = append(x.Nodes[e.N], term)
+			}
+		}
+	}
+	count(x.Terms, string(byt))
+	count(x.Titles, e.T)
+	return nil
+}
+
+// remove drops every term of the node with id from the index.
+func (x *searchIndex) remove(id int) {
+	for _, term := range x.Nodes[id] {
+		for _, terms := range []map[string]map[int]int{x.Terms, x.Titles} {
+			delete(terms[term], id)
+			if len(terms[term]) == 0 {
+				delete(terms, term)
+			}
+		}
+	}
+	delete(x.Nodes, id)
+}
+
+// BuildSearchIndex indexes every word of the title and README.md of
+// every node in the index (see Dex) and saves it to dex/search.idx
+// replacing any there already. See Search and UpdateSearchIndex.
+func (k *Keg) BuildSearchIndex() error {
+	_, err := k.buildSearchIndex()
+	return err
+}
+
+func (k *Keg) buildSearchIndex() (*searchIndex, error) {
+	dex, err := k.Dex()
+	if dex == nil {
+		return nil, err
+	}
+	x := newSearchIndex()
+	for _, e := range dex {
+		if err := x.add(k.Local.Path, e); err != nil {
+			return nil, err
+		}
+	}
+	x.Dex = dex
+	return x, k.saveSearchIndex(x)
+}
+
+// UpdateSearchIndex updates dex/search.idx with only the nodes that
+// have been added, removed, or changed in the index (see Dex.Diff)
+// since it was last built or updated along with any whose node
+// directory has changed since then without the index being updated
+// (see NodeChanged). The whole search index is built if there is none
+// (or it cannot be read). See BuildSearchIndex.
+func (k *Keg) UpdateSearchIndex() error {
+	_, err := k.searchIndex()
+	return err
+}
+
+// searchIndex returns the updated (see UpdateSearchIndex) search index.
+func (k *Keg) searchIndex() (*searchIndex, error) {
+	path := filepath.Join(k.Local.Path, `dex`, `search.idx`)
+	info, err := os.Stat(path)
+	if err != nil {
+		return k.buildSearchIndex()
+	}
+	x, err := readSearchIndex(path)
+	if err != nil {
+		return k.buildSearchIndex()
+	}
+	dex, err := k.Dex()
+	if dex == nil {
+		return nil, err
+	}
+	added, removed, changed := x.Dex.Diff(dex)
+	redo := append(added, changed...)
+	diffed := redo.Map()
+	for _, e := range dex {
+		if _, has := diffed[e.N]; has {
+			continue
+		}
+		latest, err := NodeChanged(k.Path(e.N))
+		if err == nil && latest.After(info.ModTime()) {
+			redo = append(redo, e)
+		}
+	}
+	if len(redo)+len(removed) == 0 {
+		return x, nil
+	}
+	for _, e := range removed {
+		x.remove(e.N)
+	}
+	for _, e := range redo {
+		x.remove(e.N)
+		if err := x.add(k.Local.Path, e); err != nil {
+			return nil, err
+		}
+	}
+	x.Dex = dex
+	return x, k.saveSearchIndex(x)
+}
+
+func readSearchIndex(path string) (*searchIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	x := new(searchIndex)
+	if err := gob.NewDecoder(f).Decode(x); err != nil {
+		return nil, err
+	}
+	if x.Version != searchIndexVersion {
+		return nil, fmt.Errorf("search index version %v not %v", x.Version, searchIndexVersion)
+	}
+	return x, nil
+}
+
+func (k *Keg) saveSearchIndex(x *searchIndex) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(x); err != nil {
+		return err
+	}
+	dexdir := filepath.Join(k.Local.Path, `dex`)
+	if err := os.MkdirAll(dexdir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dexdir, `search.idx`), buf.String())
+}
+
+// Search returns the entries of the nodes containing every word of the
+// query (case-insensitive) in their title or README.md ranked by how
+// many times they occur (with those in the title counting
+// SearchTitleBoost times as much) and then by ID. The search index
+// (dex/search.idx) is updated first if stale (see UpdateSearchIndex).
+// An empty query (no words) returns an empty Dex.
+func (k *Keg) Search(query string) (Dex, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return Dex{}, nil
+	}
+	x, err := k.searchIndex()
+	if err != nil {
+		return nil, err
+	}
+	score := map[int]int{}
+	for i, term := range terms {
+		found := map[int]int{}
+		for id, n := range x.Terms[term] {
+			found[id] += n
+		}
+		for id, n := range x.Titles[term] {
+			found[id] += n * SearchTitleBoost
+		}
+		for id := range score {
+			if _, has := found[id]; !has {
+				delete(score, id)
+			}
+		}
+		for id, n := range found {
+			if _, has := score[id]; has || i == 0 {
+				score[id] += n
+			}
+		}
+	}
+	results := Dex{}
+	for _, e := range x.Dex {
+		if _, has := score[e.N]; has {
+			results = append(results, e)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if a, b := score[results[i].N], score[results[j].N]; a != b {
+			return a > b
+		}
+		return results[i].N < results[j].N
+	})
+	return results, nil
+}
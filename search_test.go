@@ -0,0 +1,59 @@
+package keg_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestKeg_Search(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `1`, "# Go Channels\n\nChannels are how goroutines talk.\n", old)
+	mkNode(t, root, `2`, "# Notes\n\nGo channels, go channels, GO CHANNELS.\n", old)
+	mkNode(t, root, `3`, "# Rust\n\nNothing about the other language.\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	k, _ := keg.Open(root)
+	if err := k.BuildSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, `dex`, `search.idx`)); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := k.Search(`channels go`)
+	if err != nil || !reflect.DeepEqual(found.IDs(), []int{1, 2}) {
+		t.Errorf("expected title boost to rank 1 first, got %v (%v)", found.IDs(), err)
+	}
+	if found, _ := k.Search(`channels rust`); len(found) != 0 {
+		t.Errorf("expected AND of words, got %v", found.IDs())
+	}
+	if found, _ := k.Search(` ,. `); found == nil || len(found) != 0 {
+		t.Errorf("expected empty Dex for no words, got %v", found)
+	}
+
+	// added and removed in the index (see Dex.Diff)
+	mkNode(t, root, `4`, "# Four\n\nMore about goroutines.\n", old)
+	if err := os.RemoveAll(k.Path(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := k.Search(`goroutines`); !reflect.DeepEqual(found.IDs(), []int{4}) {
+		t.Errorf("expected only added node, got %v", found.IDs())
+	}
+
+	// changed but not yet indexed
+	later := time.Now().Add(time.Hour)
+	mkNode(t, root, `3`, "# Rust\n\nNow with goroutines.\n", later)
+	if found, _ := k.Search(`goroutines`); !reflect.DeepEqual(found.IDs(), []int{3, 4}) {
+		t.Errorf("expected stale node reindexed, got %v", found.IDs())
+	}
+}
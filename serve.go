@@ -0,0 +1,136 @@
+package keg
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rwxrob/keg/kegml"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Handler returns an http.Handler serving the keg as a web site
+// rendered on the fly (rather than ahead of time like PublishHTML) from
+// the same page template and options (BaseURL is ignored). The root (/)
+// lists every node from most to least recently updated (or shows the
+// dex/latest.md file as is when opts.Private), /N/ the README.md of
+// node N rendered to HTML, and /N/FILE any other file of the node (with
+// the content type from the extension). Links to other nodes (see
+// NodeLinkExp) are rewritten to /N/. The /search?q=QUERY page lists the
+// nodes found by Search. Nodes tagged #private (see kegml.ReadTags) are
+// never found unless opts.Private.
+func (k *Keg) Handler(opts PublishOpts) http.Handler {
+	tmpl := opts.Template
+	if tmpl == nil {
+		tmpl = template.Must(template.New(`page`).Parse(DefaultPageTemplate))
+	}
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	name := k.Local.Name
+	if k.Info != nil && k.Info.Title != "" {
+		name = k.Info.Title
+	}
+
+	// private returns true if the node is tagged #private (and they are
+	// not served) or has no README.md at all
+	private := func(id int) bool {
+		byt, err := os.ReadFile(filepath.Join(k.Path(id), `README.md`))
+		return err != nil || (!opts.Private && hasTag(kegml.TagsIn(string(byt)), `private`))
+	}
+
+	render := func(w http.ResponseWriter, page PageData, text string) {
+		var body bytes.Buffer
+		if err := md.Convert([]byte(rewriteLinks(text, "")), &body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page.Keg, page.Home = name, `/`
+		page.Body += template.HTML(body.String())
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, page); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(`Content-Type`, `text/html; charset=utf-8`)
+		w.Write(buf.Bytes())
+	}
+
+	list := func(w http.ResponseWriter, title string, dex Dex) {
+		shown := Dex{}
+		for _, e := range dex {
+			if !private(e.N) {
+				shown = append(shown, e)
+			}
+		}
+		render(w, PageData{Title: title, N: -1, Body: template.HTML(
+			"<h1>" + template.HTMLEscapeString(title) + "</h1>\n" + shown.HTML(),
+		)}, "")
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(`/search`, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get(`q`)
+		results, err := k.Search(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		list(w, `Search: `+q, results)
+	})
+
+	mux.HandleFunc(`/`, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == `/` {
+			if opts.Private {
+				byt, err := os.ReadFile(filepath.Join(k.Local.Path, `dex`, `latest.md`))
+				if err == nil {
+					render(w, PageData{Title: name, N: -1}, string(byt))
+					return
+				}
+			}
+			dex, err := k.Dex()
+			if dex == nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			list(w, name, dex.ByLatest())
+			return
+		}
+		parts := strings.SplitN(strings.TrimPrefix(path.Clean(r.URL.Path), `/`), `/`, 2)
+		id, err := strconv.Atoi(parts[0])
+		if err != nil || strconv.Itoa(id) != parts[0] || id < 0 || private(id) {
+			http.NotFound(w, r)
+			return
+		}
+		if len(parts) == 1 {
+			if !strings.HasSuffix(r.URL.Path, `/`) {
+				http.Redirect(w, r, `/`+parts[0]+`/`, http.StatusMovedPermanently)
+				return
+			}
+			byt, err := os.ReadFile(filepath.Join(k.Path(id), `README.md`))
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			page := PageData{N: id, Title: strconv.Itoa(id)}
+			if e, err := entryOf(k, id); err == nil {
+				page.Title, page.Updated = e.T, e.U.UTC().Format(IsoDateFmt)
+			}
+			render(w, page, string(byt))
+			return
+		}
+		file := filepath.Join(k.Path(id), filepath.FromSlash(parts[1]))
+		if info, err := os.Stat(file); err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, file)
+	})
+
+	return mux
+}
@@ -0,0 +1,86 @@
+package keg_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestKeg_Handler(t *testing.T) {
+	dir := mkKeg(t)
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, dir, `0`, "# Zero\n\nSee [one](/1).\n", old)
+	mkNode(t, dir, `1`, "# One\n\n![pic](pic.png)\n", old)
+	mkNode(t, dir, `2`, "# Secret\n\n#private\n", old)
+	if err := os.WriteFile(filepath.Join(dir, `1`, `pic.png`), []byte("\x89PNG\r\n\x1a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keg.UpdateDex(dir); err != nil {
+		t.Fatal(err)
+	}
+	k, err := keg.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(k.Handler(keg.PublishOpts{}))
+	defer srv.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	for _, test := range []struct {
+		path   string
+		status int
+		ctype  string
+		has    string
+		hasnt  string
+	}{
+		{`/`, 200, `text/html; charset=utf-8`, `<a href="/1/">One</a>`, `Secret`},
+		{`/0/`, 200, `text/html; charset=utf-8`, `<a href="/1/">one</a>`, ``},
+		{`/0`, 301, ``, ``, ``},
+		{`/1/pic.png`, 200, `image/png`, "\x89PNG", ``},
+		{`/1/nope.png`, 404, ``, ``, ``},
+		{`/2/`, 404, ``, ``, ``},
+		{`/01/`, 404, ``, ``, ``},
+		{`/search?q=one`, 200, `text/html; charset=utf-8`, `<a href="/1/">One</a>`, ``},
+		{`/search?q=secret`, 200, `text/html; charset=utf-8`, "<ul class=\"dex\">\n</ul>", `Secret`},
+	} {
+		res, err := client.Get(srv.URL + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != test.status {
+			t.Errorf("%v: expected status %v, got %v", test.path, test.status, res.StatusCode)
+			continue
+		}
+		if test.ctype != "" && res.Header.Get(`Content-Type`) != test.ctype {
+			t.Errorf("%v: expected %v, got %v", test.path, test.ctype, res.Header.Get(`Content-Type`))
+		}
+		if !strings.Contains(string(body), test.has) {
+			t.Errorf("%v: expected %q in:\n%s", test.path, test.has, body)
+		}
+		if test.hasnt != "" && strings.Contains(string(body), test.hasnt) {
+			t.Errorf("%v: unexpected %q in:\n%s", test.path, test.hasnt, body)
+		}
+	}
+
+	srv = httptest.NewServer(k.Handler(keg.PublishOpts{Private: true}))
+	defer srv.Close()
+	res, err := http.Get(srv.URL + `/2/`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("expected private node served, got %v", res.StatusCode)
+	}
+}
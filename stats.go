@@ -0,0 +1,129 @@
+package keg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DexStats is a summary of a Dex (see Dex.Stats).
+type DexStats struct {
+	Count   int       // total number of entries
+	Highest int       // highest node ID
+	Gaps    int       // missing node IDs (see Dex.Gaps)
+	Oldest  time.Time // least recent update
+	Newest  time.Time // most recent update
+	Last7   int       // entries updated within 7 days
+	Last30  int       // entries updated within 30 days
+}
+
+// String fulfills the fmt.Stringer interface as a small aligned report.
+func (s DexStats) String() string {
+	return fmt.Sprintf(
+		"nodes:    %v\nhighest:  %v\ngaps:     %v\noldest:   %v\n"+
+			"newest:   %v\nlast 7d:  %v\nlast 30d: %v\n",
+		s.Count, s.Highest, s.Gaps,
+		s.Oldest.UTC().Format(IsoDateFmt), s.Newest.UTC().Format(IsoDateFmt),
+		s.Last7, s.Last30,
+	)
+}
+
+// Stats returns StatsAt for the current time.
+func (d Dex) Stats() DexStats { return d.StatsAt(time.Now()) }
+
+// StatsAt returns a DexStats summary of the Dex with the Last7 and
+// Last30 counts based on now. Only the entries of the Dex are
+// considered so that any filter can be applied first.
+func (d Dex) StatsAt(now time.Time) DexStats {
+	s := DexStats{Count: len(d), Highest: d.Highest(), Gaps: len(d.Gaps())}
+	for _, e := range d {
+		if s.Oldest.IsZero() || e.U.Before(s.Oldest) {
+			s.Oldest = e.U
+		}
+		if e.U.After(s.Newest) {
+			s.Newest = e.U
+		}
+		if !e.U.Before(now.AddDate(0, 0, -7)) {
+			s.Last7++
+		}
+		if !e.U.Before(now.AddDate(0, 0, -30)) {
+			s.Last30++
+		}
+	}
+	return s
+}
+
+// KegStats is a summary of the whole keg (see Keg.Stats). Marshal
+// a pointer to it so that the Dex fields use Dex.MarshalJSON.
+type KegStats struct {
+	Nodes       int           `json:"nodes"`       // total number of nodes
+	Words       int           `json:"words"`       // across every README.md
+	Attachments int           `json:"attachments"` // see Node.Attachments
+	Bytes       int64         `json:"bytes"`       // total size of attachments
+	Tags        int           `json:"tags"`        // distinct tags
+	AvgAge      time.Duration `json:"avgAge"`      // mean time since updated
+	Newest      Dex           `json:"newest"`      // five most recently updated
+	Oldest      Dex           `json:"oldest"`      // five least recently updated
+}
+
+// String fulfills the fmt.Stringer interface as an aligned report like
+// DexStats with the newest and oldest nodes listed last.
+func (s KegStats) String() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf,
+		"nodes:       %v\nwords:       %v\nattachments: %v\nbytes:       %v\n"+
+			"tags:        %v\navg age:     %.1f days\n",
+		s.Nodes, s.Words, s.Attachments, s.Bytes, s.Tags, s.AvgAge.Hours()/24,
+	)
+	for _, list := range []struct {
+		label string
+		dex   Dex
+	}{{`newest`, s.Newest}, {`oldest`, s.Oldest}} {
+		buf.WriteString(list.label + ":\n")
+		for _, e := range list.dex {
+			fmt.Fprintf(&buf, "  %v %v %v\n", e.U.UTC().Format(IsoDateFmt), e.N, e.T)
+		}
+	}
+	return buf.String()
+}
+
+// Stats returns the KegStats for the keg from a single pass over every
+// node directory (the same one made by ScanDir) so that each file is
+// only read once. Nodes that cannot be read are left out (see
+// ScanDir).
+func (k *Keg) Stats() (KegStats, error) {
+	scans, err := scanDir(k.Local.Path, ScanOpts{}, true)
+	if _, is := err.(Errors); err != nil && !is {
+		return KegStats{}, err
+	}
+	now := time.Now()
+	s := KegStats{Nodes: len(scans)}
+	tags := map[string]bool{}
+	dex := make(Dex, 0, len(scans))
+	var age time.Duration
+	for _, n := range scans {
+		s.Words += n.Words
+		s.Attachments += n.Files
+		s.Bytes += n.Bytes
+		for _, t := range n.Tags {
+			tags[t] = true
+		}
+		age += now.Sub(n.U)
+		dex = append(dex, n.DexEntry)
+	}
+	s.Tags = len(tags)
+	if len(scans) > 0 {
+		s.AvgAge = age / time.Duration(len(scans))
+	}
+	latest := dex.ByLatest()
+	n := 5
+	if len(latest) < n {
+		n = len(latest)
+	}
+	s.Newest = latest[:n].Clone()
+	s.Oldest = make(Dex, 0, n)
+	for i := len(latest) - 1; i >= len(latest)-n; i-- {
+		s.Oldest = append(s.Oldest, latest[i])
+	}
+	return s, nil
+}
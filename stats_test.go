@@ -0,0 +1,76 @@
+package keg_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleDex_StatsAt() {
+	now := time.Date(2023, 1, 14, 9, 12, 5, 0, time.UTC)
+	dex := keg.Dex{
+		{U: now.AddDate(0, 0, -1), N: 0, T: `Zero`},
+		{U: now.AddDate(0, 0, -7), N: 1, T: `One`},
+		{U: now.AddDate(0, 0, -20), N: 4, T: `Four`},
+		{U: now.AddDate(0, -3, 0), N: 7, T: `Seven`},
+	}
+	fmt.Print(dex.StatsAt(now))
+	// Output:
+	// nodes:    4
+	// highest:  7
+	// gaps:     4
+	// oldest:   2022-10-14 09:12:05Z
+	// newest:   2023-01-13 09:12:05Z
+	// last 7d:  2
+	// last 30d: 3
+}
+
+func TestKeg_Stats(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < 7; i++ {
+		mod := now.AddDate(0, 0, -10*i)
+		content := fmt.Sprintf("# Node %v\n\nThree more words\n", i)
+		if i%2 == 0 {
+			content += "\n#even #all\n" // two words, tags too
+		}
+		mkNode(t, root, fmt.Sprint(i), content, mod)
+	}
+	img := filepath.Join(root, `3`, `image.png`)
+	if err := os.WriteFile(img, make([]byte, 100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := now.AddDate(0, 0, -30)
+	if err := os.Chtimes(img, old, old); err != nil {
+		t.Fatal(err)
+	}
+	k := &keg.Keg{Local: keg.Local{Path: root}}
+
+	s, err := k.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Nodes != 7 || s.Words != 7*6+4*2 || s.Attachments != 1 || s.Bytes != 100 || s.Tags != 2 {
+		t.Errorf("unexpected counts:\n%v", s)
+	}
+	if days := s.AvgAge.Hours() / 24; days < 29.9 || days > 30.1 {
+		t.Errorf("unexpected average age: %v days", days)
+	}
+	if len(s.Newest) != 5 || s.Newest[0].N != 0 || s.Newest[4].N != 4 ||
+		len(s.Oldest) != 5 || s.Oldest[0].N != 6 || s.Oldest[4].N != 2 {
+		t.Errorf("unexpected newest and oldest:\n%v", s)
+	}
+	if !strings.Contains(s.String(), "attachments: 1\nbytes:       100\n") {
+		t.Errorf("unexpected string:\n%v", s)
+	}
+	byt, err := json.Marshal(&s)
+	if err != nil || !strings.Contains(string(byt), `"newest":[{"U":"`) {
+		t.Errorf("unexpected JSON: %s (%v)", byt, err)
+	}
+}
@@ -0,0 +1,282 @@
+package keg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyncReport is the summary of a Keg.Sync.
+type SyncReport struct {
+	Added     Dex            // remote nodes new to this keg
+	Updated   Dex            // local nodes replaced by newer remote ones
+	Removed   Dex            // gone from the remote keg (kept in this one)
+	Conflicts []SyncConflict // changed on both sides (left alone)
+	Unfetched Dex            // remote nodes that could not be fetched
+}
+
+// SyncConflict is a node changed both locally and in the remote keg
+// since last synced (see Keg.Sync). The local node is left alone and the
+// remote one is left in Dir (see FetchNode) so that both are available.
+type SyncConflict struct {
+	Local  DexEntry
+	Remote DexEntry
+	Dir    string // directory of the fetched remote node
+}
+
+// String fulfills the fmt.Stringer interface as a single line.
+func (c SyncConflict) String() string {
+	return fmt.Sprintf("conflict: %v: local %v, remote %v (%v)", c.Local.N,
+		c.Local.U.UTC().Format(IsoDateFmt), c.Remote.U.UTC().Format(IsoDateFmt), c.Dir)
+}
+
+// String returns a line summarizing the counts followed by a line for
+// every conflict (see SyncConflict) and every node not fetched.
+func (r SyncReport) String() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%v updated, %v new, %v removed remotely, %v conflicts\n",
+		len(r.Updated), len(r.Added), len(r.Removed), len(r.Conflicts))
+	for _, c := range r.Conflicts {
+		buf.WriteString(c.String() + "\n")
+	}
+	for _, e := range r.Unfetched {
+		fmt.Fprintf(&buf, "not fetched: %v: remote %v\n", e.N, e.U.UTC().Format(IsoDateFmt))
+	}
+	return buf.String()
+}
+
+// SyncState is the update time of a node in both the remote and local
+// keg when last synced (see Keg.Sync) as saved in the dex/sync-state
+// file (one tab-separated ID, remote time, and local time per line).
+type SyncState struct {
+	N      int
+	Remote time.Time
+	Local  time.Time
+}
+
+// Sync pulls the changes from the keg published at baseURL (see
+// FetchDex) into this one by comparing the entries of the remote index
+// with those of the local index and the dex/sync-state file (see
+// SyncState). Remote nodes new to this keg are added and those changed
+// remotely (but not locally) since last synced replace the local ones
+// (every file fetched is written, no others are removed) keeping the
+// remote update time and title in both dex files (see WriteDex). Nodes
+// changed on both sides (or never synced but different on each side
+// when first synced) are never merged and are returned as Conflicts
+// instead (until resolved with SyncResolved). Nodes removed from the
+// remote keg are reported but kept. Nothing is ever pushed to the
+// remote keg. Remote nodes that cannot be fetched (including those
+// only available as a Stale cached copy, see FetchNodeWith) are left
+// alone (in the index and sync state as well) so that they are tried
+// again next time and returned as Unfetched along with Errors of the
+// reasons why. The dex lock is held while changing anything (see
+// LockDex).
+func (k *Keg) Sync(ctx context.Context, baseURL string) (SyncReport, error) {
+	r := SyncReport{
+		Added: Dex{}, Updated: Dex{}, Removed: Dex{},
+		Conflicts: []SyncConflict{}, Unfetched: Dex{},
+	}
+	remote, err := FetchDex(ctx, baseURL)
+	if remote == nil {
+		return r, err
+	}
+	var warnings Errors
+	err = withDexLock(k.Local.Path, func() error {
+		local, err := k.Dex()
+		if local == nil {
+			return err
+		}
+		state, err := k.syncState()
+		if err != nil {
+			return err
+		}
+		synced := map[int]SyncState{}
+		for _, s := range state {
+			synced[s.N] = s
+		}
+		index := local.Map()
+		for _, e := range remote.ByID() {
+			var cur DexEntry
+			haslocal := isDir(k.Path(e.N))
+			if l, has := index[e.N]; has {
+				cur = *l
+			} else if haslocal {
+				if cur, err = scanNode(k.Path(e.N)); err != nil {
+					return err
+				}
+			}
+			s, hasstate := synced[e.N]
+			remotechanged := !hasstate || !s.Remote.Equal(e.U)
+			localchanged := haslocal && (!hasstate || !s.Local.Equal(cur.U))
+			switch {
+			case !remotechanged:
+				continue
+			case haslocal && cur.U.Equal(e.U):
+				synced[e.N] = SyncState{N: e.N, Remote: e.U, Local: cur.U}
+				continue
+			}
+			node, err := FetchNodeWith(ctx, baseURL, e.N, FetchOpts{Attachments: true})
+			if err == nil && node.Stale {
+				err = fmt.Errorf("remote unreachable (only cached copy)")
+			}
+			if err != nil {
+				r.Unfetched = append(r.Unfetched, e)
+				warnings = append(warnings, fmt.Errorf("unable to fetch node %v: %w", e.N, err))
+				continue
+			}
+			if localchanged {
+				r.Conflicts = append(r.Conflicts, SyncConflict{Local: cur, Remote: e, Dir: node.Dir})
+				continue
+			}
+			if err := copyNode(node.Dir, k.Path(e.N), e.U); err != nil {
+				return err
+			}
+			action := `updated`
+			if haslocal {
+				r.Updated = append(r.Updated, e)
+			} else {
+				r.Added = append(r.Added, e)
+				action = `created`
+			}
+			local = local.Upsert(e)
+			if err := appendChange(k.Local.Path, e, action); err != nil {
+				return err
+			}
+			synced[e.N] = SyncState{N: e.N, Remote: e.U, Local: e.U}
+		}
+		ids := remote.Map()
+		for _, s := range state {
+			if _, has := ids[s.N]; !has {
+				delete(synced, s.N)
+				if l, has := index[s.N]; has {
+					r.Removed = append(r.Removed, *l)
+				}
+			}
+		}
+		if len(r.Added)+len(r.Updated) > 0 {
+			if err := WriteDex(k.Local.Path, local); err != nil {
+				return err
+			}
+		}
+		return k.writeSyncState(synced)
+	})
+	if err == nil && len(warnings) > 0 {
+		return r, warnings
+	}
+	return r, err
+}
+
+// SyncResolved records the node (a SyncConflict) as synced with the
+// remote keg at baseURL as it is now (whatever was done to resolve the
+// conflict) so that it is only a conflict again when changed on both
+// sides once more.
+func (k *Keg) SyncResolved(ctx context.Context, baseURL string, id int) error {
+	remote, err := FetchDex(ctx, baseURL)
+	if remote == nil {
+		return err
+	}
+	e := remote.Get(id)
+	if e == nil {
+		return NodeNotFound{id}
+	}
+	return withDexLock(k.Local.Path, func() error {
+		cur, err := entryOf(k, id)
+		if err != nil {
+			return err
+		}
+		state, err := k.syncState()
+		if err != nil {
+			return err
+		}
+		synced := map[int]SyncState{}
+		for _, s := range state {
+			synced[s.N] = s
+		}
+		synced[id] = SyncState{N: id, Remote: e.U, Local: cur.U}
+		return k.writeSyncState(synced)
+	})
+}
+
+// syncState returns the contents of the dex/sync-state file (empty if
+// there is none yet).
+func (k *Keg) syncState() ([]SyncState, error) {
+	path := filepath.Join(k.Local.Path, `dex`, `sync-state`)
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var state []SyncState
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		f := strings.Split(s.Text(), "\t")
+		if len(f) != 3 {
+			return nil, BadDexLine{`sync-state`, line, nil}
+		}
+		id, err := strconv.Atoi(f[0])
+		if err != nil {
+			return nil, BadDexLine{`sync-state`, line, err}
+		}
+		remote, err := ParseTime(f[1])
+		if err != nil {
+			return nil, BadDexLine{`sync-state`, line, err}
+		}
+		local, err := ParseTime(f[2])
+		if err != nil {
+			return nil, BadDexLine{`sync-state`, line, err}
+		}
+		state = append(state, SyncState{N: id, Remote: remote, Local: local})
+	}
+	return state, s.Err()
+}
+
+func (k *Keg) writeSyncState(synced map[int]SyncState) error {
+	ids := make([]int, 0, len(synced))
+	for id := range synced {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var buf strings.Builder
+	for _, id := range ids {
+		s := synced[id]
+		fmt.Fprintf(&buf, "%v\t%v\t%v\n", id,
+			s.Remote.UTC().Format(IsoDateFmt), s.Local.UTC().Format(IsoDateFmt))
+	}
+	dexdir := filepath.Join(k.Local.Path, `dex`)
+	if err := os.MkdirAll(dexdir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dexdir, `sync-state`), buf.String())
+}
+
+// copyNode copies every file of the node directory from into the node
+// directory to (created if needed) leaving any other files there alone
+// and sets the modification time of each to updated.
+func copyNode(from, to string, updated time.Time) error {
+	return filepath.Walk(from, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(from, p)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(to, rel)
+		if err := copyFile(p, dst); err != nil {
+			return err
+		}
+		return os.Chtimes(dst, updated, updated)
+	})
+}
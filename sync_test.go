@@ -0,0 +1,218 @@
+package keg_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestKeg_Sync(t *testing.T) {
+	keg.FetchCacheDir = t.TempDir()
+	t.Cleanup(func() { keg.FetchCacheDir = `` })
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+
+	theirs := mkKeg(t)
+	mkNode(t, theirs, `0`, "# Zero\n", old)
+	mkNode(t, theirs, `1`, "# One\n\n![pic](pic.png)\n", old)
+	pic := filepath.Join(theirs, `1`, `pic.png`)
+	if err := os.WriteFile(pic, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(pic, old, old); err != nil {
+		t.Fatal(err)
+	}
+	mkNode(t, theirs, `2`, "# Two\n", old)
+	if _, err := keg.UpdateDex(theirs); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(theirs)))
+	defer srv.Close()
+
+	mine := mkKeg(t)
+	mkNode(t, mine, `0`, "# Zero\n", old)
+	mkNode(t, mine, `5`, "# Mine Only\n", old)
+	if _, err := keg.UpdateDex(mine); err != nil {
+		t.Fatal(err)
+	}
+	k, _ := keg.Open(mine)
+	ctx := context.Background()
+
+	r, err := k.Sync(ctx, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != "0 updated, 2 new, 0 removed remotely, 0 conflicts\n" {
+		t.Errorf("unexpected first sync: %v", r)
+	}
+	if byt, err := os.ReadFile(filepath.Join(mine, `1`, `pic.png`)); err != nil || string(byt) != "png" {
+		t.Errorf("attachment not synced: %q (%v)", byt, err)
+	}
+	dex, _ := k.Dex()
+	if e := dex.Get(1); e == nil || e.T != `One` || !e.U.Equal(old) {
+		t.Errorf("unexpected entry for 1: %v", e)
+	}
+	if e := dex.Get(5); e == nil {
+		t.Error("local only node removed from index")
+	}
+	state, _ := os.ReadFile(filepath.Join(mine, `dex`, `sync-state`))
+	want := "0\t2022-12-10 06:10:04Z\t2022-12-10 06:10:04Z\n" +
+		"1\t2022-12-10 06:10:04Z\t2022-12-10 06:10:04Z\n" +
+		"2\t2022-12-10 06:10:04Z\t2022-12-10 06:10:04Z\n"
+	if string(state) != want {
+		t.Errorf("unexpected sync state:\n%s", state)
+	}
+
+	// nothing changed
+	if r, err := k.Sync(ctx, srv.URL); err != nil || r.String() != "0 updated, 0 new, 0 removed remotely, 0 conflicts\n" {
+		t.Errorf("unexpected second sync: %v (%v)", r, err)
+	}
+
+	// remote changes (one also changed locally)
+	later := old.Add(time.Hour)
+	mkNode(t, theirs, `1`, "# One Remote\n", later)
+	mkNode(t, theirs, `2`, "# Two Remote\n", later)
+	if err := os.RemoveAll(filepath.Join(theirs, `0`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keg.UpdateDex(theirs); err != nil {
+		t.Fatal(err)
+	}
+	soon := time.Now().Add(time.Minute) // not cached (see FetchDex)
+	if err := os.Chtimes(filepath.Join(theirs, `dex`, `nodes.tsv`), soon, soon); err != nil {
+		t.Fatal(err)
+	}
+	mkNode(t, mine, `2`, "# Two Local\n", later.Add(time.Minute))
+	if _, err := keg.UpdateDex(mine); err != nil {
+		t.Fatal(err)
+	}
+	r, err = k.Sync(ctx, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Updated) != 1 || r.Updated[0].N != 1 || len(r.Removed) != 1 || r.Removed[0].N != 0 ||
+		len(r.Conflicts) != 1 || r.Conflicts[0].Local.N != 2 || r.Conflicts[0].Remote.T != `Two Remote` {
+		t.Fatalf("unexpected sync: %v", r)
+	}
+	if byt, _ := os.ReadFile(filepath.Join(mine, `2`, `README.md`)); string(byt) != "# Two Local\n" {
+		t.Errorf("conflict not left alone: %q", byt)
+	}
+	if byt, _ := os.ReadFile(filepath.Join(r.Conflicts[0].Dir, `README.md`)); string(byt) != "# Two Remote\n" {
+		t.Errorf("remote version not available: %q", byt)
+	}
+	if byt, _ := os.ReadFile(filepath.Join(mine, `1`, `README.md`)); string(byt) != "# One Remote\n" {
+		t.Errorf("remote change not synced: %q", byt)
+	}
+	if !strings.Contains(r.String(), "conflict: 2: local 2022-12-10 07:11:04Z, remote 2022-12-10 07:10:04Z") {
+		t.Errorf("unexpected report: %v", r)
+	}
+
+	// still a conflict until resolved
+	if r, _ := k.Sync(ctx, srv.URL); len(r.Conflicts) != 1 {
+		t.Errorf("expected conflict again: %v", r)
+	}
+	if err := k.SyncResolved(ctx, srv.URL, 2); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := k.Sync(ctx, srv.URL); err != nil || len(r.Conflicts) != 0 || len(r.Updated) != 0 {
+		t.Errorf("expected resolved: %v (%v)", r, err)
+	}
+}
+
+func TestKeg_Sync_unfetched(t *testing.T) {
+	keg.FetchCacheDir = t.TempDir()
+	t.Cleanup(func() { keg.FetchCacheDir = `` })
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+
+	theirs := mkKeg(t)
+	mkNode(t, theirs, `0`, "# Zero\n", old)
+	mkNode(t, theirs, `1`, "# One\n", old)
+	if _, err := keg.UpdateDex(theirs); err != nil {
+		t.Fatal(err)
+	}
+	var fail int32
+	files := http.FileServer(http.Dir(theirs))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path != `/1/README.md`:
+		case atomic.LoadInt32(&fail) == 1:
+			http.Error(w, `down`, http.StatusInternalServerError)
+			return
+		case atomic.LoadInt32(&fail) == 2: // connection dropped
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	now := func() {
+		soon := time.Now().Add(time.Minute) // not cached (see FetchDex)
+		if err := os.Chtimes(filepath.Join(theirs, `dex`, `nodes.tsv`), soon, soon); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer srv.Close()
+
+	mine := mkKeg(t)
+	k, _ := keg.Open(mine)
+	ctx := context.Background()
+	if r, err := k.Sync(ctx, srv.URL); err != nil || len(r.Added) != 2 {
+		t.Fatalf("unexpected first sync: %v (%v)", r, err)
+	}
+
+	// node 1 changes remotely but its README.md cannot be fetched (after
+	// the dex was) failing outright or leaving only the old cached copy
+	// (see Node.Stale)
+	later := old.Add(time.Hour)
+	mkNode(t, theirs, `1`, "# One Remote\n", later)
+	if _, err := keg.UpdateDex(theirs); err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []int32{1, 2} {
+		now()
+		atomic.StoreInt32(&fail, mode)
+		state, _ := os.ReadFile(filepath.Join(mine, `dex`, `sync-state`))
+		r, err := k.Sync(ctx, srv.URL)
+		var warnings keg.Errors
+		if !errors.As(err, &warnings) || len(warnings) != 1 ||
+			!strings.Contains(warnings[0].Error(), `unable to fetch node 1`) {
+			t.Errorf("mode %v: expected warning for node 1, got %v", mode, err)
+		} else if mode == 2 && !strings.Contains(warnings[0].Error(), `only cached copy`) {
+			t.Errorf("expected stale cached copy, got %v", warnings[0])
+		}
+		if len(r.Updated) != 0 || len(r.Unfetched) != 1 || r.Unfetched[0].N != 1 ||
+			!strings.Contains(r.String(), "not fetched: 1: remote 2022-12-10 07:10:04Z\n") {
+			t.Errorf("mode %v: expected unfetched node 1: %v", mode, r)
+		}
+		dex, _ := k.Dex()
+		if e := dex.Get(1); e == nil || !e.U.Equal(old) || e.T != `One` {
+			t.Errorf("mode %v: index changed for unfetched node: %v", mode, e)
+		}
+		if after, _ := os.ReadFile(filepath.Join(mine, `dex`, `sync-state`)); string(after) != string(state) {
+			t.Errorf("mode %v: sync state changed:\n%s\nwas:\n%s", mode, after, state)
+		}
+		if byt, _ := os.ReadFile(filepath.Join(mine, `1`, `README.md`)); string(byt) != "# One\n" {
+			t.Errorf("mode %v: node changed: %q", mode, byt)
+		}
+	}
+
+	// fetched once the remote works again
+	atomic.StoreInt32(&fail, 0)
+	now()
+	if r, err := k.Sync(ctx, srv.URL); err != nil || len(r.Updated) != 1 || len(r.Unfetched) != 0 {
+		t.Errorf("expected update once fetched: %v (%v)", r, err)
+	}
+	if byt, _ := os.ReadFile(filepath.Join(mine, `1`, `README.md`)); string(byt) != "# One Remote\n" {
+		t.Errorf("remote change not synced: %q", byt)
+	}
+}
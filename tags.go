@@ -0,0 +1,168 @@
+package keg
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rwxrob/keg/kegml"
+)
+
+// Tags returns a Dex (sorted ByID) of the nodes carrying each tag found
+// in the tag line of every node README.md (see kegml.ReadTags). Entries
+// come from the index (see Dex) or the node directory if not yet
+// indexed. See UpdateTags to persist the result.
+func (k *Keg) Tags() (map[string]Dex, error) {
+	dex, err := k.Dex()
+	if dex == nil {
+		return nil, err
+	}
+	index := dex.Map()
+	tags := map[string]Dex{}
+	dirs, _, _ := NodePaths(k.Local.Path)
+	for _, d := range dirs {
+		name := filepath.Base(d.Path)
+		id, err := strconv.Atoi(name)
+		if err != nil || strconv.Itoa(id) != name {
+			continue
+		}
+		found, err := kegml.ReadTags(d.Path)
+		if err != nil {
+			if errors.Is(err, iofs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if len(found) == 0 {
+			continue
+		}
+		e, has := index[id]
+		if !has {
+			entry, _ := scanNode(d.Path) // not yet indexed
+			e = &entry
+		}
+		for _, tag := range found {
+			if ids := tags[tag]; len(ids) > 0 && ids[len(ids)-1].N == id {
+				continue // same tag twice on one node
+			}
+			tags[tag] = append(tags[tag], *e)
+		}
+	}
+	for tag, d := range tags {
+		tags[tag] = d.ByID()
+	}
+	return tags, nil
+}
+
+// WithTag returns the entries of the nodes carrying tag using the
+// dex/tags file if there is one (see UpdateTags) or Tags if not.
+// Entries in dex/tags no longer in the index are skipped.
+func (k *Keg) WithTag(tag string) (Dex, error) {
+	f, err := os.Open(filepath.Join(k.Local.Path, `dex`, `tags`))
+	if err != nil {
+		if !errors.Is(err, iofs.ErrNotExist) {
+			return nil, err
+		}
+		tags, err := k.Tags()
+		if err != nil {
+			return nil, err
+		}
+		if tags[tag] == nil {
+			return Dex{}, nil
+		}
+		return tags[tag], nil
+	}
+	defer f.Close()
+	ids, err := ParseTags(f)
+	if err != nil {
+		return nil, err
+	}
+	dex, err := k.Dex()
+	if dex == nil {
+		return nil, err
+	}
+	index := dex.Map()
+	with := Dex{}
+	for _, id := range ids[tag] {
+		if e, has := index[id]; has {
+			with = append(with, *e)
+		}
+	}
+	return with, nil
+}
+
+// UpdateTags writes the Tags of the keg to the dex/tags file (see
+// WriteTags) atomically and returns them.
+func (k *Keg) UpdateTags() (map[string]Dex, error) {
+	tags, err := k.Tags()
+	if err != nil {
+		return nil, err
+	}
+	var buf strings.Builder
+	if err := WriteTags(&buf, tags); err != nil {
+		return nil, err
+	}
+	dexdir := filepath.Join(k.Local.Path, `dex`)
+	if err := os.MkdirAll(dexdir, 0755); err != nil {
+		return nil, err
+	}
+	return tags, writeFileAtomic(filepath.Join(dexdir, `tags`), buf.String())
+}
+
+// WriteTags writes the dex/tags file format to w: one line per tag
+// (sorted) with the tag followed by the ID of every node carrying it
+// (sorted), all separated by tabs. See ParseTags.
+func WriteTags(w io.Writer, tags map[string]Dex) error {
+	names := make([]string, 0, len(tags))
+	for tag := range tags {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+	for _, tag := range names {
+		ids := tags[tag].ByID().IDs()
+		fields := make([]string, 0, len(ids)+1)
+		fields = append(fields, tag)
+		for _, id := range ids {
+			fields = append(fields, strconv.Itoa(id))
+		}
+		if _, err := io.WriteString(w, strings.Join(fields, "\t")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseTags parses the dex/tags file format (see WriteTags) into a map
+// of each tag to the node IDs carrying it. Blank lines are skipped.
+// Returns an error identifying the line number of the first ID that is
+// not an integer.
+func ParseTags(r io.Reader) (map[string][]int, error) {
+	tags := map[string][]int{}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSuffix(s.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		f := strings.Split(text, "\t")
+		ids := make([]int, 0, len(f)-1)
+		for _, field := range f[1:] {
+			id, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, BadDexLine{`tags`, line, err}
+			}
+			ids = append(ids, id)
+		}
+		tags[f[0]] = append(tags[f[0]], ids...)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
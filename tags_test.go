@@ -0,0 +1,61 @@
+package keg_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func ExampleParseTags() {
+	tags, err := keg.ParseTags(strings.NewReader("go\t1\t3\nkeg\t2\n\nnone\n"))
+	fmt.Println(tags, err)
+	_, err = keg.ParseTags(strings.NewReader("go\t1\nkeg\tx\n"))
+	fmt.Println(err)
+	// Output:
+	// map[go:[1 3] keg:[2] none:[]] <nil>
+	// bad line in tags: 2: strconv.Atoi: parsing "x": invalid syntax
+}
+
+func TestKeg_Tags(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `0`, "# Zero\n", old)
+	mkNode(t, root, `1`, "# One\n\nBody\n\n    #go #keg\n", old)
+	mkNode(t, root, `2`, "# Two\n\nNot a #tag line.\n", old)
+	mkNode(t, root, `3`, "# Three\n\n#go #go\n\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	k, _ := keg.Open(root)
+
+	tags, err := k.UpdateTags()
+	if err != nil || len(tags) != 2 || !reflect.DeepEqual(tags[`go`].IDs(), []int{1, 3}) ||
+		tags[`keg`][0].T != `One` {
+		t.Errorf("unexpected tags (%v): %v", err, tags)
+	}
+	file, _ := os.ReadFile(filepath.Join(root, `dex`, `tags`))
+	if string(file) != "go\t1\t3\nkeg\t1\n" {
+		t.Errorf("unexpected dex/tags: %q", file)
+	}
+
+	// dex/tags is used without a rescan
+	mkNode(t, root, `2`, "# Two\n\n#go\n", old)
+	with, err := k.WithTag(`go`)
+	if err != nil || !reflect.DeepEqual(with.IDs(), []int{1, 3}) {
+		t.Errorf("unexpected WithTag (%v): %v", err, with)
+	}
+	os.Remove(filepath.Join(root, `dex`, `tags`))
+	with, err = k.WithTag(`go`)
+	if err != nil || !reflect.DeepEqual(with.IDs(), []int{1, 2, 3}) {
+		t.Errorf("unexpected WithTag after rescan (%v): %v", err, with)
+	}
+	if with, _ := k.WithTag(`nope`); with == nil || len(with) != 0 {
+		t.Errorf("expected empty Dex, got %v", with)
+	}
+}
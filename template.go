@@ -0,0 +1,101 @@
+package keg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is passed to every node template (see MakeNodeFrom).
+type TemplateData struct {
+	ID    int    // of the new node
+	Title string // as passed to MakeNodeFrom
+	Date  string // UTC date created (2006-01-02)
+}
+
+// DefaultTemplates are the node templates available in every keg by
+// name (see MakeNodeFrom). Those in the dex/templates directory of the
+// keg with the same name are used instead.
+var DefaultTemplates = map[string]string{
+	`meeting`: "# {{.Title}}\n\n" +
+		"Date: {{.Date}}\n\n" +
+		"## Attendees\n\n## Agenda\n\n## Notes\n\n## Actions\n",
+	`journal`: "# {{.Title}}\n\n{{.Date}}\n\n",
+}
+
+// Templates returns the names (sorted) of every template that can be
+// passed to MakeNodeFrom: the DefaultTemplates and every file in the
+// dex/templates directory of the keg (named without any extension).
+func (k *Keg) Templates() ([]string, error) {
+	all, err := k.templates()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// templates returns the text of every template by name with those from
+// dex/templates replacing DefaultTemplates.
+func (k *Keg) templates() (map[string]string, error) {
+	all := map[string]string{}
+	for name, text := range DefaultTemplates {
+		all[name] = text
+	}
+	dir := filepath.Join(k.Local.Path, `dex`, `templates`)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return all, nil
+		}
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), `.`) {
+			continue
+		}
+		byt, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		all[strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))] = string(byt)
+	}
+	return all, nil
+}
+
+// MakeNodeFrom is MakeNode but with the README.md rendered from the
+// named template (see Templates) using text/template and TemplateData.
+// Templates should begin with a "# {{.Title}}" line so that the title
+// in the index matches. Returns TemplateNotFound (listing those
+// available) if there is no template by that name and the error from
+// text/template if it cannot be parsed or rendered (in which case no
+// node is made).
+func (k *Keg) MakeNodeFrom(name, title string) (*Node, error) {
+	all, err := k.templates()
+	if err != nil {
+		return nil, err
+	}
+	text, has := all[name]
+	if !has {
+		available, _ := k.Templates()
+		return nil, TemplateNotFound{Name: name, Available: available}
+	}
+	tmpl, err := template.New(name).Option(`missingkey=error`).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return k.makeNode(title, func(data TemplateData) (string, error) {
+		var buf strings.Builder
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	})
+}
@@ -0,0 +1,75 @@
+package keg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestKeg_MakeNodeFrom(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `1`, "# One\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	tmpls := filepath.Join(root, `dex`, `templates`)
+	if err := os.MkdirAll(tmpls, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{
+		`book.md`:    "# {{.Title}}\n\nNode {{.ID}} read on {{.Date}}.\n",
+		`journal.md`: "# {{.Title}}\n\nMine.\n",
+		`broken.md`:  "# {{.Nope}}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(tmpls, name), []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	k, _ := keg.Open(root)
+
+	names, err := k.Templates()
+	if want := []string{`book`, `broken`, `journal`, `meeting`}; err != nil || !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v (%v) want %v", names, err, want)
+	}
+
+	node, err := k.MakeNodeFrom(`book`, `Dune`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readme, _ := os.ReadFile(node.ReadmePath())
+	want := "# Dune\n\nNode 2 read on " + time.Now().UTC().Format(`2006-01-02`) + ".\n"
+	if node.ID != 2 || string(readme) != want {
+		t.Errorf("unexpected node %v: %q", node.ID, readme)
+	}
+	if dex, _ := k.Dex(); dex.Get(2) == nil || dex.Get(2).T != `Dune` {
+		t.Errorf("not indexed: %v", dex)
+	}
+
+	node, _ = k.MakeNodeFrom(`journal`, `Today`)
+	if readme, _ := os.ReadFile(node.ReadmePath()); string(readme) != "# Today\n\nMine.\n" {
+		t.Errorf("expected keg template to replace default, got %q", readme)
+	}
+	node, _ = k.MakeNodeFrom(`meeting`, `Standup`)
+	if readme, _ := os.ReadFile(node.ReadmePath()); !strings.Contains(string(readme), "## Agenda") {
+		t.Errorf("expected default meeting template, got %q", readme)
+	}
+
+	var notfound keg.TemplateNotFound
+	if _, err := k.MakeNodeFrom(`nope`, `Nope`); !errors.As(err, &notfound) ||
+		!strings.Contains(err.Error(), `book, broken, journal, meeting`) {
+		t.Errorf("expected TemplateNotFound listing templates, got %v", err)
+	}
+	if _, err := k.MakeNodeFrom(`broken`, `Broken`); err == nil {
+		t.Error("expected error rendering broken template")
+	}
+	if _, err := os.Stat(k.Path(5)); !os.IsNotExist(err) {
+		t.Errorf("expected no node left for broken template: %v", err)
+	}
+}
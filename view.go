@@ -0,0 +1,85 @@
+package keg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rwxrob/keg/kegml"
+	"github.com/rwxrob/term"
+)
+
+// includeLineExp matches a KEGML include list item (see
+// DexEntry.AsInclude) capturing the bullet (with any indentation), the
+// link text, the target, and the node ID.
+var includeLineExp = regexp.MustCompile(`^(\s*[*-] )\[(.*)\]\(((?:\.\.)?/(\d+))/?\)\s*$`)
+
+// TermView returns the KEGML text (of a node README.md) rendered for
+// reading in a terminal: the title (first "# " line) in bold without
+// the hashtag, include list items with the current title of the node
+// from the dex (falling back to the link text) followed by the
+// target, fenced code blocks indented (without the fences), and the tags
+// of the tag line in color. Everything else is left as is. Color is
+// omitted when the NO_COLOR environment variable is set or output is
+// not to a terminal. See TermViewPlain.
+func TermView(text string, dex Dex) string { return termView(text, dex, false) }
+
+// TermViewPlain returns TermView without any color.
+func TermViewPlain(text string, dex Dex) string { return termView(text, dex, true) }
+
+func termView(text string, dex Dex, plain bool) string {
+	bold, under, black, cyan, reset := term.Bold, term.Under, term.Black, term.Cyan, term.Reset
+	if plain || NoColor() {
+		bold, under, black, cyan, reset = "", "", "", "", ""
+	}
+	titles := dex.Map()
+	text = strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), " \t\n")
+	lines := strings.Split(text, "\n")
+	tagline := -1
+	if len(kegml.TagsIn(text)) > 0 {
+		tagline = len(lines) - 1 // the last line (see kegml.TagsIn)
+	}
+	out := make([]string, 0, len(lines))
+	var fence string
+	titled := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+				continue
+			}
+			out = append(out, black+`    `+line+reset)
+			continue
+		}
+		if f := fenceOpen(trimmed); f != "" {
+			fence = f
+			continue
+		}
+		switch {
+		case !titled && strings.HasPrefix(line, `# `):
+			titled = true
+			out = append(out, bold+strings.TrimSpace(line[2:])+reset)
+		case i == tagline:
+			tags := strings.Fields(line)
+			for n, tag := range tags {
+				tags[n] = cyan + tag + reset
+			}
+			out = append(out, strings.Join(tags, " "))
+		default:
+			m := includeLineExp.FindStringSubmatch(line)
+			if m == nil {
+				out = append(out, line)
+				continue
+			}
+			title := UnescapeLinkText(m[2])
+			if id, err := strconv.Atoi(m[4]); err == nil {
+				if e, has := titles[id]; has {
+					title = e.T
+				}
+			}
+			out = append(out, m[1]+under+title+reset+` `+black+`(`+m[3]+`)`+reset)
+		}
+	}
+	return joinLines(out)
+}
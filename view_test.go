@@ -0,0 +1,37 @@
+package keg_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rwxrob/keg"
+	"github.com/rwxrob/term"
+)
+
+const viewText = "# Some Node\n\nSee these:\n\n* [old title](/1)\n* [Gone](../99)\n\n" +
+	"```sh\necho hi\n```\n\nThe end.\n\n#one #two\n"
+
+func TestTermViewPlain(t *testing.T) {
+	dex := keg.Dex{{N: 1, T: `Current title`}}
+	want := "Some Node\n\nSee these:\n\n* Current title (/1)\n* Gone (../99)\n\n" +
+		"    echo hi\n\nThe end.\n\n#one #two\n"
+	if got := keg.TermViewPlain(viewText, dex); got != want {
+		t.Errorf("expected:\n%v\ngot:\n%v", want, got)
+	}
+	raw := "# Not\n\n# a tag line\n"
+	if got := keg.TermViewPlain(raw, nil); got != "Not\n\n# a tag line\n" {
+		t.Errorf("unexpected view: %q", got)
+	}
+}
+
+func TestTermView(t *testing.T) {
+	term.SetInteractive(true)
+	defer term.SetInteractive(term.DetectInteractive())
+	t.Setenv(`NO_COLOR`, "")
+	got := keg.TermView(viewText, nil)
+	for _, want := range []string{term.Bold + `Some Node` + term.Reset, term.Cyan + `#two` + term.Reset} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%q", want, got)
+		}
+	}
+}
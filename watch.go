@@ -0,0 +1,128 @@
+package keg
+
+import (
+	"context"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch keeps the dex files of the keg up to date while ctx is not done
+// by watching every node directory for changes (see fsnotify). Events
+// are debounced so that a burst of them (an editor save, for example)
+// causes a single update once nothing else has happened for the
+// debounce duration. Only the nodes affected are read again (see
+// ScanDir) and upserted into (or removed from) the Dex, which is then
+// written (see WriteDex) and sent on the channel returned. New node
+// directories and deletions are picked up. Editor swap and backup files
+// are ignored (see NodeChanged). The channel is closed when ctx is done.
+// An update not yet received is replaced by the next so that a slow
+// receiver always gets the latest.
+func (k *Keg) Watch(ctx context.Context, debounce time.Duration) (<-chan Dex, error) {
+	dex, err := k.Dex()
+	if dex == nil {
+		return nil, err
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	root := k.Local.Path
+	if err := watchTree(w, root, root); err != nil {
+		w.Close()
+		return nil, err
+	}
+	updates := make(chan Dex, 1)
+	go func() {
+		defer close(updates)
+		defer w.Close()
+		timer := time.NewTimer(debounce)
+		timer.Stop()
+		pending := map[int]bool{}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.Errors:
+				// dropped events are picked up by the next one
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				id, is := watchedNode(root, ev.Name)
+				if !is {
+					continue
+				}
+				if ev.Op&fsnotify.Create != 0 && isDir(ev.Name) {
+					watchTree(w, root, ev.Name)
+				}
+				pending[id] = true
+				timer.Reset(debounce)
+			case <-timer.C:
+				for id := range pending {
+					e, err := scanNode(k.Path(id))
+					if err != nil {
+						dex = dex.Remove(id) // deleted or no README.md
+						continue
+					}
+					dex = dex.Upsert(e)
+				}
+				pending = map[int]bool{}
+				dex = dex.ByID()
+				write := func() error { return WriteDex(root, dex) }
+				if err := withDexLock(root, write); err != nil {
+					continue
+				}
+				select {
+				case <-updates: // replace unreceived update
+				default:
+				}
+				updates <- dex.Clone()
+			}
+		}
+	}()
+	return updates, nil
+}
+
+// watchedNode returns the ID of the node containing path if it is
+// within a node directory (or is one) of the keg at root and is not an
+// editor temporary file (see isEditorTemp).
+func watchedNode(root, path string) (int, bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || isEditorTemp(filepath.Base(path)) {
+		return 0, false
+	}
+	name := strings.SplitN(filepath.ToSlash(rel), `/`, 2)[0]
+	id, err := strconv.Atoi(name)
+	if err != nil || strconv.Itoa(id) != name {
+		return 0, false
+	}
+	return id, true
+}
+
+// watchTree adds dir and every directory within it to w skipping
+// dotdirs and the dex directory of the keg at root (which Watch itself
+// writes to).
+func watchTree(w *fsnotify.Watcher, root, dir string) error {
+	dexdir := filepath.Join(root, `dex`)
+	return filepath.WalkDir(dir, func(p string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // already gone
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p == dexdir || (p != dir && strings.HasPrefix(d.Name(), `.`)) {
+			return filepath.SkipDir
+		}
+		return w.Add(p)
+	})
+}
@@ -0,0 +1,83 @@
+package keg_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rwxrob/keg"
+)
+
+func TestKeg_Watch(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 12, 10, 6, 10, 4, 0, time.UTC)
+	mkNode(t, root, `1`, "# One\n", old)
+	if _, err := keg.UpdateDex(root); err != nil {
+		t.Fatal(err)
+	}
+	k := &keg.Keg{Local: keg.Local{Path: root}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates, err := k.Watch(ctx, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func(what string) keg.Dex {
+		t.Helper()
+		select {
+		case dex := <-updates:
+			return dex
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no update after %v", what)
+		}
+		return nil
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// editor temp files are ignored
+	write(`1/.README.md.swp`, `junk`)
+	write(`1/README.md~`, `junk`)
+	select {
+	case dex := <-updates:
+		t.Errorf("unexpected update from temp files: %v", dex)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	write(`1/README.md`, "# One (edited)\n")
+	dex := next(`edit`)
+	if len(dex) != 1 || dex[0].T != `One (edited)` {
+		t.Errorf("unexpected dex after edit: %v", dex)
+	}
+
+	// new node directories are picked up
+	if err := os.Mkdir(filepath.Join(root, `2`), 0700); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond) // let the new directory be watched
+	write(`2/README.md`, "# Two\n")
+	for dex = next(`create`); len(dex) != 2; dex = next(`create`) {
+	}
+	tsv, _ := os.ReadFile(filepath.Join(root, `dex`, `nodes.tsv`))
+	if dex[1].T != `Two` || string(tsv) != dex.TSV() {
+		t.Errorf("unexpected dex after create: %v\n%s", dex, tsv)
+	}
+
+	// as are deletions
+	if err := os.RemoveAll(filepath.Join(root, `1`)); err != nil {
+		t.Fatal(err)
+	}
+	if dex = next(`delete`); len(dex) != 1 || dex[0].N != 2 {
+		t.Errorf("unexpected dex after delete: %v", dex)
+	}
+
+	cancel()
+	for range updates {
+	}
+}